@@ -0,0 +1,145 @@
+package modbus
+
+import (
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+// CallbackDataStore implements modbus.DataStore by delegating each operation
+// to a user-supplied function, so a server can serve live application state
+// without copying it into a DefaultDataStore. Operations whose callback is nil
+// fail with an IllegalFunction exception.
+type CallbackDataStore struct {
+	ReadCoilsFunc             func(address modbus.Address, quantity modbus.Quantity) ([]bool, error)
+	WriteCoilsFunc            func(address modbus.Address, values []bool) error
+	ReadDiscreteInputsFunc    func(address modbus.Address, quantity modbus.Quantity) ([]bool, error)
+	ReadHoldingRegistersFunc  func(address modbus.Address, quantity modbus.Quantity) ([]uint16, error)
+	WriteHoldingRegistersFunc func(address modbus.Address, values []uint16) error
+	ReadInputRegistersFunc    func(address modbus.Address, quantity modbus.Quantity) ([]uint16, error)
+	ReadFileRecordsFunc       func(records []modbus.FileRecord) ([]modbus.FileRecord, error)
+	WriteFileRecordsFunc      func(records []modbus.FileRecord) error
+	ReadFIFOQueueFunc         func(address modbus.Address) ([]uint16, error)
+	ReadExceptionStatusFunc   func() (uint8, error)
+	GetDiagnosticDataFunc     func(subFunction uint16, data []byte) ([]byte, error)
+	GetCommEventCounterFunc   func() (uint16, uint16, error)
+	GetCommEventLogFunc       func() (uint16, uint16, uint16, []byte, error)
+}
+
+// ErrPending may be returned by a callback that has accepted a request but
+// is still processing it, typically a long-running write. The server answers
+// with an Acknowledge (0x05) exception; the client is expected to poll for
+// completion. It may be wrapped with fmt.Errorf and %w.
+var ErrPending error = modbus.NewModbusError(0, modbus.ExceptionCodeAcknowledge, "request accepted, processing")
+
+// ErrBusy may be returned by a callback while an earlier request is still
+// being processed. The server answers with a Server Device Busy (0x06)
+// exception.
+var ErrBusy error = modbus.NewModbusError(0, modbus.ExceptionCodeServerDeviceBusy, "processing a previous request")
+
+// unsupported returns the IllegalFunction error used for unset callbacks
+func unsupported(fc modbus.FunctionCode) error {
+	return modbus.NewModbusError(fc, modbus.ExceptionCodeIllegalFunction, "not supported by this data store")
+}
+
+// ReadCoils implements modbus.DataStore
+func (ds *CallbackDataStore) ReadCoils(address modbus.Address, quantity modbus.Quantity) ([]bool, error) {
+	if ds.ReadCoilsFunc == nil {
+		return nil, unsupported(modbus.FuncCodeReadCoils)
+	}
+	return ds.ReadCoilsFunc(address, quantity)
+}
+
+// WriteCoils implements modbus.DataStore
+func (ds *CallbackDataStore) WriteCoils(address modbus.Address, values []bool) error {
+	if ds.WriteCoilsFunc == nil {
+		return unsupported(modbus.FuncCodeWriteMultipleCoils)
+	}
+	return ds.WriteCoilsFunc(address, values)
+}
+
+// ReadDiscreteInputs implements modbus.DataStore
+func (ds *CallbackDataStore) ReadDiscreteInputs(address modbus.Address, quantity modbus.Quantity) ([]bool, error) {
+	if ds.ReadDiscreteInputsFunc == nil {
+		return nil, unsupported(modbus.FuncCodeReadDiscreteInputs)
+	}
+	return ds.ReadDiscreteInputsFunc(address, quantity)
+}
+
+// ReadHoldingRegisters implements modbus.DataStore
+func (ds *CallbackDataStore) ReadHoldingRegisters(address modbus.Address, quantity modbus.Quantity) ([]uint16, error) {
+	if ds.ReadHoldingRegistersFunc == nil {
+		return nil, unsupported(modbus.FuncCodeReadHoldingRegisters)
+	}
+	return ds.ReadHoldingRegistersFunc(address, quantity)
+}
+
+// WriteHoldingRegisters implements modbus.DataStore
+func (ds *CallbackDataStore) WriteHoldingRegisters(address modbus.Address, values []uint16) error {
+	if ds.WriteHoldingRegistersFunc == nil {
+		return unsupported(modbus.FuncCodeWriteMultipleRegisters)
+	}
+	return ds.WriteHoldingRegistersFunc(address, values)
+}
+
+// ReadInputRegisters implements modbus.DataStore
+func (ds *CallbackDataStore) ReadInputRegisters(address modbus.Address, quantity modbus.Quantity) ([]uint16, error) {
+	if ds.ReadInputRegistersFunc == nil {
+		return nil, unsupported(modbus.FuncCodeReadInputRegisters)
+	}
+	return ds.ReadInputRegistersFunc(address, quantity)
+}
+
+// ReadFileRecords implements modbus.DataStore
+func (ds *CallbackDataStore) ReadFileRecords(records []modbus.FileRecord) ([]modbus.FileRecord, error) {
+	if ds.ReadFileRecordsFunc == nil {
+		return nil, unsupported(modbus.FuncCodeReadFileRecord)
+	}
+	return ds.ReadFileRecordsFunc(records)
+}
+
+// WriteFileRecords implements modbus.DataStore
+func (ds *CallbackDataStore) WriteFileRecords(records []modbus.FileRecord) error {
+	if ds.WriteFileRecordsFunc == nil {
+		return unsupported(modbus.FuncCodeWriteFileRecord)
+	}
+	return ds.WriteFileRecordsFunc(records)
+}
+
+// ReadFIFOQueue implements modbus.DataStore
+func (ds *CallbackDataStore) ReadFIFOQueue(address modbus.Address) ([]uint16, error) {
+	if ds.ReadFIFOQueueFunc == nil {
+		return nil, unsupported(modbus.FuncCodeReadFIFOQueue)
+	}
+	return ds.ReadFIFOQueueFunc(address)
+}
+
+// ReadExceptionStatus implements modbus.DataStore
+func (ds *CallbackDataStore) ReadExceptionStatus() (uint8, error) {
+	if ds.ReadExceptionStatusFunc == nil {
+		return 0, unsupported(modbus.FuncCodeReadExceptionStatus)
+	}
+	return ds.ReadExceptionStatusFunc()
+}
+
+// GetDiagnosticData implements modbus.DataStore
+func (ds *CallbackDataStore) GetDiagnosticData(subFunction uint16, data []byte) ([]byte, error) {
+	if ds.GetDiagnosticDataFunc == nil {
+		return nil, unsupported(modbus.FuncCodeDiagnostic)
+	}
+	return ds.GetDiagnosticDataFunc(subFunction, data)
+}
+
+// GetCommEventCounter implements modbus.DataStore
+func (ds *CallbackDataStore) GetCommEventCounter() (uint16, uint16, error) {
+	if ds.GetCommEventCounterFunc == nil {
+		return 0, 0, unsupported(modbus.FuncCodeGetCommEventCounter)
+	}
+	return ds.GetCommEventCounterFunc()
+}
+
+// GetCommEventLog implements modbus.DataStore
+func (ds *CallbackDataStore) GetCommEventLog() (uint16, uint16, uint16, []byte, error) {
+	if ds.GetCommEventLogFunc == nil {
+		return 0, 0, 0, nil, unsupported(modbus.FuncCodeGetCommEventLog)
+	}
+	return ds.GetCommEventLogFunc()
+}
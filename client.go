@@ -1,7 +1,11 @@
 package modbus
 
 import (
+	"crypto/tls"
+	"encoding/asn1"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/adibhanna/modbus-go/modbus"
@@ -9,7 +13,8 @@ import (
 	"github.com/adibhanna/modbus-go/transport"
 )
 
-// Client represents a MODBUS client
+// Client represents a MODBUS client. Its configuration may be changed from
+// any goroutine, including while requests are in flight.
 type Client struct {
 	transport      transport.Transport
 	slaveID        modbus.SlaveID
@@ -18,7 +23,84 @@ type Client struct {
 	retryDelay     time.Duration
 	connectTimeout time.Duration
 	autoReconnect  bool
+	closed         bool
 	encoding       *EncodingConfig
+
+	gatewayRetryCount int
+	gatewayRetryDelay time.Duration
+
+	floatWriteValidation bool
+	verifyWrites         bool
+
+	latencyObserver LatencyObserver
+	retryObserver   RetryObserver
+	retryClassifier RetryClassifier
+
+	reconnectObserver   ReconnectObserver
+	maxReconnectBackoff time.Duration
+	// reconnects is shared with clones, which use the same transport
+	reconnects *reconnectState
+
+	// gate is shared with clones; exclusive clients returned by Pause bypass it
+	gate      *pauseGate
+	exclusive bool
+
+	healthProbe HealthProbe
+	// health is shared with clones so every request updates one status
+	health *healthState
+
+	// supportedFunctions, if set, lists the only function codes sent; the map
+	// is replaced, never modified
+	supportedFunctions map[modbus.FunctionCode]bool
+	profileName        string
+
+	logger               transport.Logger
+	slowRequestThreshold time.Duration
+	// validateUnitID is nil until SetValidateUnitID is called, so the
+	// transport's own setting is left alone until then
+	validateUnitID *bool
+
+	// readLimits holds read quantity limits found by DiscoverMaxReadQuantity,
+	// keyed by function code. The map is replaced, never modified.
+	readLimits map[modbus.FunctionCode]int
+
+	mutex sync.RWMutex
+}
+
+// LatencyObserver receives the measured round-trip time of every request
+// attempt sent through the transport, including failed attempts
+type LatencyObserver func(slaveID modbus.SlaveID, functionCode modbus.FunctionCode, latency time.Duration, err error)
+
+// RetryObserver receives the outcome of every request once its retry loop
+// finishes. attempt is the 1-based attempt that succeeded, or the total number
+// of attempts made if err is non-nil.
+type RetryObserver func(slaveID modbus.SlaveID, functionCode modbus.FunctionCode, attempt int, err error)
+
+// ReconnectObserver is called after every auto-reconnect attempt. attempt
+// counts the attempts since the connection was last established, starting at
+// 1; err is nil if the attempt succeeded.
+type ReconnectObserver func(attempt int, err error)
+
+// ReconnectState describes a client's auto-reconnect progress
+type ReconnectState struct {
+	// ConsecutiveFailures is the number of reconnect attempts that failed
+	// since the connection was last established
+	ConsecutiveFailures int
+	// LastAttempt is when a reconnect was last tried; zero if never
+	LastAttempt time.Time
+	// LastError is the error of the last attempt, or nil if it succeeded
+	LastError error
+	// Backoff is the delay before the next reconnect attempt; zero after a
+	// successful attempt
+	Backoff time.Duration
+}
+
+// reconnectState tracks reconnect attempts
+type reconnectState struct {
+	failures    int
+	lastAttempt time.Time
+	lastErr     error
+	mutex       sync.Mutex
 }
 
 // NewClient creates a new MODBUS client with the given transport
@@ -31,6 +113,10 @@ func NewClient(t transport.Transport) *Client {
 		retryCount:     config.RetryCount,
 		retryDelay:     config.RetryDelay,
 		connectTimeout: config.ConnectTimeout,
+		encoding:       DefaultEncodingConfig(),
+		reconnects:     &reconnectState{},
+		gate:           &pauseGate{},
+		health:         &healthState{},
 	}
 }
 
@@ -39,6 +125,17 @@ func NewTCPClient(address string) *Client {
 	return NewClient(transport.NewTCPTransport(address))
 }
 
+// NewSecureTCPClient creates a MODBUS/TCP Security client. See
+// transport.NewSecureTCPTransport for the TLS defaults applied and how the
+// certificate role is checked.
+func NewSecureTCPClient(address string, tlsConfig *tls.Config, roleOID asn1.ObjectIdentifier) (*Client, error) {
+	t, err := transport.NewSecureTCPTransport(address, tlsConfig, roleOID)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(t), nil
+}
+
 // NewClientFromConfig creates a new MODBUS client from a configuration
 func NewClientFromConfig(config *modbus.ClientConfig, t transport.Transport) *Client {
 	return &Client{
@@ -48,6 +145,10 @@ func NewClientFromConfig(config *modbus.ClientConfig, t transport.Transport) *Cl
 		retryCount:     config.RetryCount,
 		retryDelay:     config.RetryDelay,
 		connectTimeout: config.ConnectTimeout,
+		encoding:       DefaultEncodingConfig(),
+		reconnects:     &reconnectState{},
+		gate:           &pauseGate{},
+		health:         &healthState{},
 	}
 }
 
@@ -74,17 +175,257 @@ func NewTCPClientFromJSONString(jsonConfig, address string) (*Client, error) {
 	return NewTCPClientFromConfig(config, address), nil
 }
 
-// Connect establishes the connection
+// clone returns a copy of the client's configuration sharing the same transport
+func (c *Client) clone() *Client {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return &Client{
+		transport:      c.transport,
+		slaveID:        c.slaveID,
+		timeout:        c.timeout,
+		retryCount:     c.retryCount,
+		retryDelay:     c.retryDelay,
+		connectTimeout: c.connectTimeout,
+		autoReconnect:  c.autoReconnect,
+		closed:         c.closed,
+		encoding:       c.encoding,
+
+		gatewayRetryCount: c.gatewayRetryCount,
+		gatewayRetryDelay: c.gatewayRetryDelay,
+
+		floatWriteValidation: c.floatWriteValidation,
+		verifyWrites:         c.verifyWrites,
+		latencyObserver:      c.latencyObserver,
+		retryObserver:        c.retryObserver,
+		retryClassifier:      c.retryClassifier,
+
+		reconnectObserver:   c.reconnectObserver,
+		maxReconnectBackoff: c.maxReconnectBackoff,
+		reconnects:          c.reconnects,
+
+		gate:      c.gate,
+		exclusive: c.exclusive,
+
+		healthProbe: c.healthProbe,
+		health:      c.health,
+
+		supportedFunctions: c.supportedFunctions,
+		profileName:        c.profileName,
+
+		logger:               c.logger,
+		slowRequestThreshold: c.slowRequestThreshold,
+		validateUnitID:       c.validateUnitID,
+
+		readLimits: c.readLimits,
+	}
+}
+
+// connectTimeoutSetter is implemented by transports with a configurable dial timeout
+type connectTimeoutSetter interface {
+	SetConnectTimeout(timeout time.Duration)
+}
+
+// Connect establishes the connection. The client's response and connect
+// timeouts, logger and unit ID validation are re-applied to the transport
+// first, so a reconnect starts from the client's current configuration.
+// Connect also re-enables auto-reconnect after an explicit Close.
 func (c *Client) Connect() error {
-	c.transport.SetTimeout(c.timeout)
+	c.mutex.Lock()
+	c.closed = false
+	c.mutex.Unlock()
+
+	return c.connect()
+}
+
+// connect applies the client's transport settings to the transport and
+// connects it
+func (c *Client) connect() error {
+	c.mutex.RLock()
+	timeout := c.timeout
+	connectTimeout := c.connectTimeout
+	logger := c.logger
+	validateUnitID := c.validateUnitID
+	c.mutex.RUnlock()
+
+	c.transport.SetTimeout(timeout)
+	if ct, ok := c.transport.(connectTimeoutSetter); ok && connectTimeout > 0 {
+		ct.SetConnectTimeout(connectTimeout)
+	}
+	if ls, ok := c.transport.(loggerSetter); ok && logger != nil {
+		ls.SetLogger(logger)
+	}
+	if v, ok := c.transport.(unitIDValidationSetter); ok && validateUnitID != nil {
+		v.SetValidateUnitID(*validateUnitID)
+	}
 	return c.transport.Connect()
 }
 
-// Close closes the connection
+// ErrClientClosed is returned by requests made after an explicit Close
+var ErrClientClosed = errors.New("client closed")
+
+// Close closes the connection. An explicitly closed client is not revived by
+// auto-reconnect; requests fail with ErrClientClosed until Connect is called.
 func (c *Client) Close() error {
+	c.mutex.Lock()
+	c.closed = true
+	c.mutex.Unlock()
+
 	return c.transport.Close()
 }
 
+// isClosed returns true if the client was closed with Close and not reconnected
+func (c *Client) isClosed() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.closed
+}
+
+// reconnect re-establishes a lost connection for auto-reconnect. It fails with
+// ErrClientClosed after an explicit Close.
+func (c *Client) reconnect() error {
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	err := c.connect()
+
+	state := c.reconnects
+	state.mutex.Lock()
+	attempt := state.failures + 1
+	if err != nil {
+		state.failures++
+	} else {
+		state.failures = 0
+	}
+	state.lastAttempt = time.Now()
+	state.lastErr = err
+	state.mutex.Unlock()
+
+	c.mutex.RLock()
+	observer := c.reconnectObserver
+	c.mutex.RUnlock()
+	if observer != nil {
+		observer(attempt, err)
+	}
+	return err
+}
+
+// SetReconnectObserver sets a callback fired after every auto-reconnect
+// attempt, so flapping links can be logged or alerted on. Pass nil to remove
+// it.
+func (c *Client) SetReconnectObserver(observer ReconnectObserver) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reconnectObserver = observer
+}
+
+// SetReconnectBackoff makes the wait after each consecutive failed reconnect
+// double, starting from the retry delay, up to max. Zero (the default) waits
+// the retry delay after every failure.
+func (c *Client) SetReconnectBackoff(max time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.maxReconnectBackoff = max
+}
+
+// GetReconnectState returns the current auto-reconnect state
+func (c *Client) GetReconnectState() ReconnectState {
+	c.mutex.RLock()
+	retryDelay, max := c.retryDelay, c.maxReconnectBackoff
+	c.mutex.RUnlock()
+
+	state := c.reconnects
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	return ReconnectState{
+		ConsecutiveFailures: state.failures,
+		LastAttempt:         state.lastAttempt,
+		LastError:           state.lastErr,
+		Backoff:             reconnectBackoff(retryDelay, max, state.failures),
+	}
+}
+
+// reconnectBackoff returns the wait after failures consecutive failed
+// reconnects: base, doubled per failure up to max if max is set
+func reconnectBackoff(base, max time.Duration, failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	if max <= 0 {
+		return base
+	}
+	delay := base
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	return min(delay, max)
+}
+
+// ErrClientPaused is returned by requests made while the client is paused
+var ErrClientPaused = errors.New("client paused")
+
+// pauseGate lets Pause wait for requests in flight and hold off new ones.
+// Requests hold gate for reading while they run.
+type pauseGate struct {
+	gate   sync.RWMutex
+	paused bool
+	mutex  sync.Mutex
+}
+
+// Pause gives the caller exclusive use of the device, for sequences such as a
+// firmware upload that must not interleave with polling. It waits for
+// requests in flight to finish, then makes every request through the client,
+// from any goroutine, fail with ErrClientPaused until Resume is called. The
+// returned client shares the connection and configuration and keeps working
+// while paused:
+//
+//	exclusive := client.Pause()
+//	defer client.Resume()
+//	err := exclusive.WriteFileRecords(records)
+//
+// Pausing a paused client waits until it is resumed.
+func (c *Client) Pause() *Client {
+	c.gate.gate.Lock()
+	c.gate.mutex.Lock()
+	c.gate.paused = true
+	c.gate.mutex.Unlock()
+
+	exclusive := c.clone()
+	exclusive.exclusive = true
+	return exclusive
+}
+
+// Resume lets requests through again after Pause. It does nothing if the
+// client is not paused.
+func (c *Client) Resume() {
+	c.gate.mutex.Lock()
+	defer c.gate.mutex.Unlock()
+	if !c.gate.paused {
+		return
+	}
+	c.gate.paused = false
+	c.gate.gate.Unlock()
+}
+
+// IsPaused returns true if the client is paused
+func (c *Client) IsPaused() bool {
+	c.gate.mutex.Lock()
+	defer c.gate.mutex.Unlock()
+	return c.gate.paused
+}
+
+// enter admits a request unless the client is paused or being paused. The
+// returned function must be called when the request finishes.
+func (c *Client) enter() (func(), error) {
+	if c.exclusive {
+		return func() {}, nil
+	}
+	if !c.gate.gate.TryRLock() {
+		return nil, ErrClientPaused
+	}
+	return c.gate.gate.RUnlock, nil
+}
+
 // IsConnected returns true if the client is connected
 func (c *Client) IsConnected() bool {
 	return c.transport.IsConnected()
@@ -92,67 +433,266 @@ func (c *Client) IsConnected() bool {
 
 // SetSlaveID sets the slave/unit ID
 func (c *Client) SetSlaveID(slaveID modbus.SlaveID) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.slaveID = slaveID
 }
 
+// UnsupportedFunctionError is returned, without sending anything, for a
+// request whose function code the client's device profile does not support
+type UnsupportedFunctionError struct {
+	FunctionCode modbus.FunctionCode
+	Profile      string
+}
+
+// Error implements the error interface
+func (e *UnsupportedFunctionError) Error() string {
+	return fmt.Sprintf("function 0x%02X not supported by profile '%s'", byte(e.FunctionCode), e.Profile)
+}
+
+// SetSupportedFunctions restricts the client to the function codes a device
+// supports, as listed by a device profile. Requests with any other function
+// code fail immediately with an UnsupportedFunctionError naming profile,
+// instead of being sent and answered with an exception. No codes removes the
+// restriction.
+func (c *Client) SetSupportedFunctions(profile string, codes ...modbus.FunctionCode) {
+	var supported map[modbus.FunctionCode]bool
+	if len(codes) > 0 {
+		supported = make(map[modbus.FunctionCode]bool, len(codes))
+		for _, fc := range codes {
+			supported[fc] = true
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.supportedFunctions = supported
+	c.profileName = profile
+}
+
+// checkSupported returns an UnsupportedFunctionError if fc is not supported
+func (c *Client) checkSupported(fc modbus.FunctionCode) error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.supportedFunctions != nil && !c.supportedFunctions[fc] {
+		return &UnsupportedFunctionError{FunctionCode: fc, Profile: c.profileName}
+	}
+	return nil
+}
+
 // GetSlaveID returns the current slave/unit ID
 func (c *Client) GetSlaveID() modbus.SlaveID {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.slaveID
 }
 
 // SetTimeout sets the response timeout
 func (c *Client) SetTimeout(timeout time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.timeout = timeout
 	c.transport.SetTimeout(timeout)
 }
 
 // GetTimeout returns the current timeout
 func (c *Client) GetTimeout() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.timeout
 }
 
-// SetRetryCount sets the number of retries on failure
+// SetRetryCount sets the number of retries on failure. A request is attempted
+// up to count+1 times; MODBUS exception responses are not retried, except
+// gateway exceptions when SetGatewayRetry is configured.
 func (c *Client) SetRetryCount(count int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.retryCount = count
 }
 
 // GetRetryCount returns the current retry count
 func (c *Client) GetRetryCount() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.retryCount
 }
 
-// SetRetryDelay sets the delay between retry attempts
+// SetRetryDelay sets the delay between retry attempts (default 100ms)
 func (c *Client) SetRetryDelay(delay time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.retryDelay = delay
 }
 
 // GetRetryDelay returns the current retry delay
 func (c *Client) GetRetryDelay() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.retryDelay
 }
 
 // SetConnectTimeout sets the connection timeout
 func (c *Client) SetConnectTimeout(timeout time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.connectTimeout = timeout
 }
 
 // GetConnectTimeout returns the current connection timeout
 func (c *Client) GetConnectTimeout() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.connectTimeout
 }
 
-// SetAutoReconnect enables or disables automatic reconnection on connection failure
+// SetAutoReconnect enables or disables automatic reconnection on connection
+// failure. Auto-reconnect does not apply after an explicit Close.
 func (c *Client) SetAutoReconnect(enabled bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.autoReconnect = enabled
 }
 
 // GetAutoReconnect returns whether automatic reconnection is enabled
 func (c *Client) GetAutoReconnect() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.autoReconnect
 }
 
+// SetGatewayRetry configures retries for gateway exceptions (Gateway Path
+// Unavailable, Gateway Target Failed). These are retried separately from
+// transport errors, typically with a longer delay to give the gateway time to
+// reach the target. A count of 0 (the default) returns them immediately.
+func (c *Client) SetGatewayRetry(count int, delay time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.gatewayRetryCount = count
+	c.gatewayRetryDelay = delay
+}
+
+// SetLatencyObserver sets a callback invoked with the round-trip time of each request.
+// Pass nil to disable latency reporting.
+func (c *Client) SetLatencyObserver(observer LatencyObserver) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.latencyObserver = observer
+}
+
+// loggerSetter is implemented by transports that accept a custom logger
+type loggerSetter interface {
+	SetLogger(logger transport.Logger)
+}
+
+// loggerGetter is implemented by transports that report their logger
+type loggerGetter interface {
+	GetLogger() transport.Logger
+}
+
+// SetLogger sets the logger used for client warnings such as slow requests.
+// It is also passed to the transport if the transport supports logging.
+func (c *Client) SetLogger(logger transport.Logger) {
+	c.mutex.Lock()
+	c.logger = logger
+	c.mutex.Unlock()
+
+	if ls, ok := c.transport.(loggerSetter); ok {
+		ls.SetLogger(logger)
+	}
+}
+
+// unitIDValidationSetter is implemented by transports that can relax the
+// response unit ID check
+type unitIDValidationSetter interface {
+	SetValidateUnitID(validate bool)
+}
+
+// SetValidateUnitID sets whether responses must carry the unit ID of the
+// request (default true), for gateways that rewrite it. It has no effect on
+// transports without the check.
+func (c *Client) SetValidateUnitID(validate bool) {
+	c.mutex.Lock()
+	c.validateUnitID = &validate
+	c.mutex.Unlock()
+
+	if v, ok := c.transport.(unitIDValidationSetter); ok {
+		v.SetValidateUnitID(validate)
+	}
+}
+
+// SetSlowRequestThreshold logs a warning through the client's logger, or the
+// transport's if the client has none, for every request attempt whose round
+// trip exceeds threshold. Zero disables the check.
+func (c *Client) SetSlowRequestThreshold(threshold time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.slowRequestThreshold = threshold
+}
+
+// GetSlowRequestThreshold returns the slow request threshold
+func (c *Client) GetSlowRequestThreshold() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.slowRequestThreshold
+}
+
+// slowRequestObserver wraps next so that attempts slower than threshold are
+// logged. It returns next unchanged if slow request logging is disabled.
+func slowRequestObserver(next LatencyObserver, threshold time.Duration, logger transport.Logger) LatencyObserver {
+	if threshold <= 0 || logger == nil {
+		return next
+	}
+	return func(slaveID modbus.SlaveID, functionCode modbus.FunctionCode, latency time.Duration, err error) {
+		if latency > threshold {
+			logger.Printf("slow MODBUS request: slave %d, %s took %v (threshold %v)",
+				slaveID, functionCode, latency, threshold)
+		}
+		if next != nil {
+			next(slaveID, functionCode, latency, err)
+		}
+	}
+}
+
+// RetryClassifier decides whether a failed attempt is retried. err is the
+// transport error, or a *modbus.ModbusError for an exception response.
+type RetryClassifier func(err error) bool
+
+// DefaultRetryClassifier retries transport errors but not exception
+// responses. It also gives up on transport.ErrCircuitOpen, which a retry
+// would only hit again.
+func DefaultRetryClassifier(err error) bool {
+	var modbusErr *modbus.ModbusError
+	if errors.As(err, &modbusErr) {
+		return false
+	}
+	return !errors.Is(err, transport.ErrCircuitOpen)
+}
+
+// SetRetryClassifier replaces DefaultRetryClassifier as the test of whether a
+// failed attempt is retried, within the retry count. An exception response
+// that is not retried, or fails on the last attempt, is returned as usual.
+// Gateway exceptions configured with SetGatewayRetry are retried before the
+// classifier is asked. Pass nil to restore the default.
+func (c *Client) SetRetryClassifier(classifier RetryClassifier) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.retryClassifier = classifier
+}
+
+// SetRetryObserver sets a callback reporting which attempt each request
+// succeeded on, so links that only work after retrying can be detected.
+// Pass nil to disable retry reporting.
+func (c *Client) SetRetryObserver(observer RetryObserver) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.retryObserver = observer
+}
+
 // GetConfig returns the current client configuration
 func (c *Client) GetConfig() *modbus.ClientConfig {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return &modbus.ClientConfig{
 		SlaveID:        c.slaveID,
 		Timeout:        c.timeout,
@@ -165,6 +705,8 @@ func (c *Client) GetConfig() *modbus.ClientConfig {
 
 // ApplyConfig applies a configuration to the client
 func (c *Client) ApplyConfig(config *modbus.ClientConfig) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.slaveID = config.SlaveID
 	c.timeout = config.Timeout
 	c.retryCount = config.RetryCount
@@ -174,18 +716,75 @@ func (c *Client) ApplyConfig(config *modbus.ClientConfig) {
 	c.transport.SetTimeout(c.timeout)
 }
 
-// sendRequest sends a request with retry logic and optional auto-reconnect
+// sendRequest sends a request with retry logic and optional auto-reconnect.
+// Failures the retry classifier accepts, by default transport errors, are
+// retried up to retryCount times with retryDelay between attempts; exception
+// responses are returned as-is for the parsers to report, after up to
+// gatewayRetryCount extra tries for gateway exceptions.
+// With auto-reconnect enabled, a failed attempt closes the connection so the
+// next attempt reconnects instead of reading stale data, and a connection found
+// dead before the request could be written is re-established and the request
+// resent immediately.
 func (c *Client) sendRequest(req *pdu.Request) (*pdu.Response, error) {
+	if err := c.checkSupported(req.FunctionCode); err != nil {
+		return nil, err
+	}
+
+	leave, err := c.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	// Snapshot the configuration so concurrent setters don't affect this request
+	c.mutex.RLock()
+	slaveID := c.slaveID
+	retryCount := c.retryCount
+	retryDelay := c.retryDelay
+	autoReconnect := c.autoReconnect
+	latencyObserver := c.latencyObserver
+	retryObserver := c.retryObserver
+	retryClassifier := c.retryClassifier
+	gatewayRetryCount := c.gatewayRetryCount
+	gatewayRetryDelay := c.gatewayRetryDelay
+	maxReconnectBackoff := c.maxReconnectBackoff
+	slowRequestThreshold := c.slowRequestThreshold
+	logger := c.logger
+	c.mutex.RUnlock()
+
+	if lg, ok := c.transport.(loggerGetter); ok && logger == nil && slowRequestThreshold > 0 {
+		logger = lg.GetLogger()
+	}
+	latencyObserver = slowRequestObserver(latencyObserver, slowRequestThreshold, logger)
+
+	if retryClassifier == nil {
+		retryClassifier = DefaultRetryClassifier
+	}
+
+	gatewayRetries := 0
+
 	var lastErr error
+	attempts := 0
+
+	for attempt := 0; attempt <= retryCount; attempt++ {
+		attempts = attempt + 1
 
-	for attempt := 0; attempt <= c.retryCount; attempt++ {
 		// Check connection and attempt reconnect if enabled
 		if !c.transport.IsConnected() {
-			if c.autoReconnect {
-				if err := c.Connect(); err != nil {
+			if c.isClosed() {
+				return nil, fmt.Errorf("transport not connected: %w", ErrClientClosed)
+			}
+			if autoReconnect {
+				if err := c.reconnect(); err != nil {
 					lastErr = fmt.Errorf("auto-reconnect failed: %w", err)
-					if attempt < c.retryCount {
-						time.Sleep(c.retryDelay)
+					if !retryClassifier(err) {
+						break
+					}
+					if attempt < retryCount {
+						c.reconnects.mutex.Lock()
+						failures := c.reconnects.failures
+						c.reconnects.mutex.Unlock()
+						time.Sleep(reconnectBackoff(retryDelay, maxReconnectBackoff, failures))
 					}
 					continue
 				}
@@ -194,19 +793,81 @@ func (c *Client) sendRequest(req *pdu.Request) (*pdu.Response, error) {
 			}
 		}
 
-		resp, err := c.transport.SendRequest(c.slaveID, req)
+		resp, err := c.exchange(slaveID, req, latencyObserver)
+		if err != nil && autoReconnect && !c.transport.IsConnected() && modbus.IsWriteError(err) {
+			// The transport found the connection dead (e.g. the device
+			// rebooted) before the request went out; reconnect and resend
+			// once without using up a retry. A request that may have reached
+			// the device is only resent as a counted retry, so writes are
+			// not applied twice.
+			if connErr := c.reconnect(); connErr == nil {
+				resp, err = c.exchange(slaveID, req, latencyObserver)
+			}
+		}
+		for err == nil && gatewayRetries < gatewayRetryCount && isGatewayResponse(resp) {
+			gatewayRetries++
+			time.Sleep(gatewayRetryDelay)
+			resp, err = c.exchange(slaveID, req, latencyObserver)
+		}
+		if err == nil && attempt < retryCount && resp.IsException() && retryClassifier(resp.Err()) {
+			time.Sleep(retryDelay)
+			continue
+		}
 		if err == nil {
+			if retryObserver != nil {
+				retryObserver(slaveID, req.FunctionCode, attempt+1, nil)
+			}
+			resp.Request = req
 			return resp, nil
 		}
 		lastErr = err
 
+		// A failed exchange may leave a late or partial response in the
+		// stream; drop the connection so the next attempt starts clean
+		if autoReconnect {
+			_ = c.transport.Close()
+		}
+
+		if !retryClassifier(err) {
+			break
+		}
+
 		// Don't retry on the last attempt
-		if attempt < c.retryCount {
-			time.Sleep(c.retryDelay) // Configurable delay between retries
+		if attempt < retryCount {
+			time.Sleep(retryDelay) // Configurable delay between retries
 		}
 	}
 
-	return nil, fmt.Errorf("request failed after %d attempts: %w", c.retryCount+1, lastErr)
+	if retryObserver != nil {
+		retryObserver(slaveID, req.FunctionCode, attempts, lastErr)
+	}
+
+	err = fmt.Errorf("request failed after %d attempts: %w", attempts, lastErr)
+	if modbus.IsTimeout(lastErr) {
+		return nil, &modbus.TimeoutError{Err: err}
+	}
+	return nil, err
+}
+
+// isGatewayResponse returns true if resp is a gateway exception response
+func isGatewayResponse(resp *pdu.Response) bool {
+	if !resp.IsException() {
+		return false
+	}
+	ec, err := resp.GetExceptionCode()
+	return err == nil && ec.IsGatewayException()
+}
+
+// exchange sends a single request through the transport and reports its latency
+func (c *Client) exchange(slaveID modbus.SlaveID, req *pdu.Request, observer LatencyObserver) (*pdu.Response, error) {
+	start := time.Now()
+	resp, err := c.transport.SendRequest(slaveID, req)
+	latency := time.Since(start)
+	c.health.record(start, latency, err)
+	if observer != nil {
+		observer(slaveID, req.FunctionCode, latency, err)
+	}
+	return resp, err
 }
 
 // ReadCoils reads coils (function code 0x01)
@@ -269,6 +930,70 @@ func (c *Client) ReadInputRegisters(address modbus.Address, quantity modbus.Quan
 	return pdu.ParseReadInputRegistersResponse(resp, quantity)
 }
 
+// ReadCoilsInto reads coils into dst, reusing its storage across polls.
+// dst must hold at least quantity values.
+func (c *Client) ReadCoilsInto(address modbus.Address, quantity modbus.Quantity, dst []bool) error {
+	req, err := pdu.ReadCoilsRequest(address, quantity)
+	if err != nil {
+		return fmt.Errorf("failed to create read coils request: %w", err)
+	}
+
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return err
+	}
+
+	return pdu.ParseReadBitsResponseInto(resp, quantity, dst)
+}
+
+// ReadDiscreteInputsInto reads discrete inputs into dst, reusing its storage
+// across polls. dst must hold at least quantity values.
+func (c *Client) ReadDiscreteInputsInto(address modbus.Address, quantity modbus.Quantity, dst []bool) error {
+	req, err := pdu.ReadDiscreteInputsRequest(address, quantity)
+	if err != nil {
+		return fmt.Errorf("failed to create read discrete inputs request: %w", err)
+	}
+
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return err
+	}
+
+	return pdu.ParseReadBitsResponseInto(resp, quantity, dst)
+}
+
+// ReadHoldingRegistersInto reads holding registers into dst, reusing its
+// storage across polls. dst must hold at least quantity values.
+func (c *Client) ReadHoldingRegistersInto(address modbus.Address, quantity modbus.Quantity, dst []uint16) error {
+	req, err := pdu.ReadHoldingRegistersRequest(address, quantity)
+	if err != nil {
+		return fmt.Errorf("failed to create read holding registers request: %w", err)
+	}
+
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return err
+	}
+
+	return pdu.ParseReadRegistersResponseInto(resp, quantity, dst)
+}
+
+// ReadInputRegistersInto reads input registers into dst, reusing its storage
+// across polls. dst must hold at least quantity values.
+func (c *Client) ReadInputRegistersInto(address modbus.Address, quantity modbus.Quantity, dst []uint16) error {
+	req, err := pdu.ReadInputRegistersRequest(address, quantity)
+	if err != nil {
+		return fmt.Errorf("failed to create read input registers request: %w", err)
+	}
+
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return err
+	}
+
+	return pdu.ParseReadRegistersResponseInto(resp, quantity, dst)
+}
+
 // WriteSingleCoil writes a single coil (function code 0x05)
 func (c *Client) WriteSingleCoil(address modbus.Address, value bool) error {
 	req, err := pdu.WriteSingleCoilRequest(address, value)
@@ -301,6 +1026,10 @@ func (c *Client) WriteSingleRegister(address modbus.Address, value uint16) error
 
 // WriteMultipleCoils writes multiple coils (function code 0x0F)
 func (c *Client) WriteMultipleCoils(address modbus.Address, values []bool) error {
+	if len(values) == 0 {
+		return fmt.Errorf("no coil values provided")
+	}
+
 	req, err := pdu.WriteMultipleCoilsRequest(address, values)
 	if err != nil {
 		return fmt.Errorf("failed to create write multiple coils request: %w", err)
@@ -316,17 +1045,80 @@ func (c *Client) WriteMultipleCoils(address modbus.Address, values []bool) error
 
 // WriteMultipleRegisters writes multiple registers (function code 0x10)
 func (c *Client) WriteMultipleRegisters(address modbus.Address, values []uint16) error {
+	if len(values) == 0 {
+		return fmt.Errorf("no register values provided")
+	}
+
 	req, err := pdu.WriteMultipleRegistersRequest(address, values)
 	if err != nil {
 		return fmt.Errorf("failed to create write multiple registers request: %w", err)
 	}
 
+	if c.GetWriteVerification() && !c.exclusive {
+		// Hold the pause gate across the write and its read back, so a Pause
+		// in between cannot fail a write that succeeded
+		leave, err := c.enter()
+		if err != nil {
+			return err
+		}
+		defer leave()
+
+		inside := c.clone()
+		inside.exclusive = true
+		return inside.WriteMultipleRegisters(address, values)
+	}
+
 	resp, err := c.sendRequest(req)
 	if err != nil {
 		return err
 	}
 
-	return pdu.ParseWriteMultipleRegistersResponse(resp, address, modbus.Quantity(len(values)))
+	if err := pdu.ParseWriteMultipleRegistersResponse(resp, address, modbus.Quantity(len(values))); err != nil {
+		return err
+	}
+
+	if c.GetWriteVerification() {
+		return c.verifyRegisters(address, values)
+	}
+	return nil
+}
+
+// ErrWriteVerification is returned when registers read back after a write
+// differ from the values written
+var ErrWriteVerification = errors.New("write verification failed")
+
+// SetWriteVerification enables reading back the range written by
+// WriteMultipleRegisters and comparing it with the values sent. This catches
+// gateways that acknowledge a full write but silently drop trailing registers.
+// It costs one extra read per write and assumes the registers read back as
+// written.
+func (c *Client) SetWriteVerification(enabled bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.verifyWrites = enabled
+}
+
+// GetWriteVerification returns whether multi-register writes are verified
+func (c *Client) GetWriteVerification() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.verifyWrites
+}
+
+// verifyRegisters reads back the registers at address and compares them with values
+func (c *Client) verifyRegisters(address modbus.Address, values []uint16) error {
+	readBack, err := c.ReadHoldingRegisters(address, modbus.Quantity(len(values)))
+	if err != nil {
+		return fmt.Errorf("%w: read back failed: %w", ErrWriteVerification, err)
+	}
+
+	for i, v := range values {
+		if readBack[i] != v {
+			return fmt.Errorf("%w: register %d wrote %d, read back %d",
+				ErrWriteVerification, int(address)+i, v, readBack[i])
+		}
+	}
+	return nil
 }
 
 // MaskWriteRegister performs a mask write on a register (function code 0x16)
@@ -405,6 +1197,24 @@ func (c *Client) Diagnostic(subFunction uint16, data []byte) (uint16, []byte, er
 	return pdu.ParseDiagnosticResponse(resp)
 }
 
+// ReadDiagnosticRegister returns the server's diagnostic register (function
+// code 0x08, sub-function 0x0002). Use DecodeDiagnosticRegister to name its bits.
+func (c *Client) ReadDiagnosticRegister() (uint16, error) {
+	subFunction, data, err := c.Diagnostic(modbus.DiagSubReturnDiagRegister, []byte{0x00, 0x00})
+	if err != nil {
+		return 0, err
+	}
+	if subFunction != modbus.DiagSubReturnDiagRegister {
+		return 0, fmt.Errorf("unexpected diagnostic sub-function: expected 0x%04X, got 0x%04X",
+			modbus.DiagSubReturnDiagRegister, subFunction)
+	}
+	if len(data) != 2 {
+		return 0, fmt.Errorf("invalid diagnostic register response: expected 2 bytes, got %d", len(data))
+	}
+
+	return pdu.DecodeUint16(data)
+}
+
 // GetCommEventCounter gets the communication event counter (function code 0x0B, Serial line only)
 func (c *Client) GetCommEventCounter() (status uint16, eventCount uint16, err error) {
 	req, err := pdu.GetCommEventCounterRequest()
@@ -450,6 +1260,19 @@ func (c *Client) ReportServerID() ([]byte, error) {
 	return pdu.ParseReportServerIDResponse(resp)
 }
 
+// ReportServerIDInfo gets the server ID and splits it into the server ID, run
+// indicator and additional data (function code 0x11, Serial line only).
+// idLen is the length of the device's server ID, which the protocol leaves
+// device specific.
+func (c *Client) ReportServerIDInfo(idLen int) (*modbus.ServerIDReport, error) {
+	serverData, err := c.ReportServerID()
+	if err != nil {
+		return nil, err
+	}
+
+	return pdu.ParseServerIDReport(serverData, idLen)
+}
+
 // ReadFileRecord reads file records (function code 0x14)
 func (c *Client) ReadFileRecord(records []modbus.FileRecord) ([]modbus.FileRecord, error) {
 	req, err := pdu.ReadFileRecordRequest(records)
@@ -497,7 +1320,43 @@ func (c *Client) ReadDeviceIdentification(readCode uint8, objectID uint8) (*modb
 
 // String returns a string representation of the client
 func (c *Client) String() string {
-	return fmt.Sprintf("ModbusClient(slave=%d, transport=%s)", c.slaveID, c.transport.String())
+	return fmt.Sprintf("ModbusClient(slave=%d, transport=%s)", c.GetSlaveID(), c.transport.String())
+}
+
+// SendRawPDU sends a request PDU built from functionCode and data exactly as
+// given, with the client's retry and reconnect handling, and returns the raw
+// response. It is an escape hatch for vendor-specific function codes.
+//
+// The response data is not interpreted. If the slave answered, the response
+// is always returned: an exception response comes with a *modbus.ModbusError
+// (the same error as resp.Err()), so callers can either treat exceptions as
+// errors or ignore the error and inspect resp.Code() and resp.Payload(). A
+// nil response means nothing was received.
+func (c *Client) SendRawPDU(functionCode modbus.FunctionCode, data []byte) (*pdu.Response, error) {
+	if functionCode == 0 || functionCode.IsException() {
+		return nil, fmt.Errorf("invalid function code 0x%02X", uint8(functionCode))
+	}
+	if 1+len(data) > modbus.MaxPDUSize {
+		return nil, fmt.Errorf("PDU too large: %d bytes, max %d", 1+len(data), modbus.MaxPDUSize)
+	}
+
+	payload := make([]byte, len(data))
+	copy(payload, data)
+
+	resp, err := c.sendRequest(pdu.NewRequest(functionCode, payload))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := resp.Err(); err != nil {
+		return resp, err
+	}
+	if resp.FunctionCode != functionCode {
+		return resp, fmt.Errorf("%w: expected 0x%02X, got 0x%02X",
+			pdu.ErrFunctionCodeMismatch, uint8(functionCode), uint8(resp.FunctionCode))
+	}
+
+	return resp, nil
 }
 
 // Broadcast methods - send to all devices (slave ID 0), no response expected
@@ -524,6 +1383,10 @@ func (c *Client) BroadcastWriteSingleRegister(address modbus.Address, value uint
 
 // BroadcastWriteMultipleCoils broadcasts a write multiple coils command to all devices
 func (c *Client) BroadcastWriteMultipleCoils(address modbus.Address, values []bool) error {
+	if len(values) == 0 {
+		return fmt.Errorf("no coil values provided")
+	}
+
 	req, err := pdu.WriteMultipleCoilsRequest(address, values)
 	if err != nil {
 		return fmt.Errorf("failed to create write multiple coils request: %w", err)
@@ -534,6 +1397,10 @@ func (c *Client) BroadcastWriteMultipleCoils(address modbus.Address, values []bo
 
 // BroadcastWriteMultipleRegisters broadcasts a write multiple registers command to all devices
 func (c *Client) BroadcastWriteMultipleRegisters(address modbus.Address, values []uint16) error {
+	if len(values) == 0 {
+		return fmt.Errorf("no register values provided")
+	}
+
 	req, err := pdu.WriteMultipleRegistersRequest(address, values)
 	if err != nil {
 		return fmt.Errorf("failed to create write multiple registers request: %w", err)
@@ -542,11 +1409,26 @@ func (c *Client) BroadcastWriteMultipleRegisters(address modbus.Address, values
 	return c.sendBroadcast(req)
 }
 
-// sendBroadcast sends a broadcast request (no response expected)
+// sendBroadcast sends a broadcast request (no response expected). Over TCP and
+// UDP it returns once the request is written; on serial lines it also waits
+// the transport's turnaround delay.
 func (c *Client) sendBroadcast(req *pdu.Request) error {
+	if err := c.checkSupported(req.FunctionCode); err != nil {
+		return err
+	}
+
+	leave, err := c.enter()
+	if err != nil {
+		return err
+	}
+	defer leave()
+
 	if !c.transport.IsConnected() {
-		if c.autoReconnect {
-			if err := c.Connect(); err != nil {
+		if c.isClosed() {
+			return fmt.Errorf("transport not connected: %w", ErrClientClosed)
+		}
+		if c.GetAutoReconnect() {
+			if err := c.reconnect(); err != nil {
 				return fmt.Errorf("auto-reconnect failed: %w", err)
 			}
 		} else {
@@ -554,14 +1436,16 @@ func (c *Client) sendBroadcast(req *pdu.Request) error {
 		}
 	}
 
-	// Send to broadcast address (0), ignore response
-	_, err := c.transport.SendRequest(modbus.BroadcastAddress, req)
-	// For broadcast, we don't care about the response (there shouldn't be one)
-	// Some transports may return a timeout error which is expected
-	if err != nil {
-		// Only return error if it's not a timeout (broadcast has no response)
-		// For TCP, this will likely timeout which is expected
-		return nil
+	// Transports that support it send without waiting for a response
+	if b, ok := c.transport.(transport.Broadcaster); ok {
+		return b.SendBroadcast(req)
+	}
+
+	// Send to broadcast address (0). The request has been transmitted unless
+	// the write itself failed; a timeout or any reply is expected here.
+	_, err = c.transport.SendRequest(modbus.BroadcastAddress, req)
+	if err != nil && modbus.IsWriteError(err) {
+		return fmt.Errorf("broadcast not sent: %w", err)
 	}
 	return nil
 }
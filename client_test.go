@@ -1,11 +1,25 @@
 package modbus
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/adibhanna/modbus-go/modbus"
+	"github.com/adibhanna/modbus-go/pdu"
+	"github.com/adibhanna/modbus-go/transport"
 )
 
 func TestTCPClient(t *testing.T) {
@@ -71,6 +85,42 @@ func TestTCPClient(t *testing.T) {
 		}
 	})
 
+	t.Run("ReadCoilStatus", func(t *testing.T) {
+		mask, err := client.ReadCoilStatus(0, 10)
+		if err != nil {
+			t.Fatalf("Failed to read coil status: %v", err)
+		}
+
+		// Even coils are ON
+		if mask != 0x0155 {
+			t.Errorf("Expected mask 0x0155, got 0x%04X", mask)
+		}
+
+		if _, err := client.ReadCoilStatus(0, 17); err == nil {
+			t.Error("Expected error for quantity above 16")
+		}
+	})
+
+	t.Run("ReadDiscreteInputsPacked", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			dataStore.SetDiscreteInput(modbus.Address(i), i%3 == 0)
+		}
+
+		client.SetEncoding(LittleEndian, HighWordFirst)
+		defer client.SetEncoding(BigEndian, HighWordFirst)
+
+		packed, err := client.ReadDiscreteInputsPacked(0, 20)
+		if err != nil {
+			t.Fatalf("Failed to read packed discrete inputs: %v", err)
+		}
+
+		// Inputs 0, 3, 6, 9, 12, 15 and 18 are set
+		expected := []uint16{0x9249, 0x0004}
+		if !reflect.DeepEqual(packed, expected) {
+			t.Errorf("Expected %04X, got %04X", expected, packed)
+		}
+	})
+
 	t.Run("WriteSingleCoil", func(t *testing.T) {
 		// Write coil 10 to ON
 		if err := client.WriteSingleCoil(10, true); err != nil {
@@ -102,6 +152,106 @@ func TestTCPClient(t *testing.T) {
 		}
 	})
 
+	t.Run("ReadIntoBuffers", func(t *testing.T) {
+		regs := make([]uint16, 5)
+		if err := client.ReadHoldingRegistersInto(0, 5, regs); err != nil {
+			t.Fatalf("Failed to read holding registers into buffer: %v", err)
+		}
+		for i, v := range regs {
+			if v != uint16(i*100) {
+				t.Errorf("Register %d: expected %d, got %d", i, i*100, v)
+			}
+		}
+
+		if err := client.ReadInputRegistersInto(0, 5, regs); err != nil {
+			t.Fatalf("Failed to read input registers into buffer: %v", err)
+		}
+
+		bits := make([]bool, 5)
+		if err := client.ReadDiscreteInputsInto(0, 5, bits); err != nil {
+			t.Fatalf("Failed to read discrete inputs into buffer: %v", err)
+		}
+		if err := client.ReadCoilsInto(0, 5, bits); err != nil {
+			t.Fatalf("Failed to read coils into buffer: %v", err)
+		}
+		if !bits[0] || bits[1] || !bits[2] {
+			t.Errorf("Expected coils [true false true ...], got %v", bits)
+		}
+
+		if err := client.ReadInputRegistersInto(0, 10, regs); err == nil {
+			t.Error("Expected error for destination smaller than quantity")
+		}
+	})
+
+	t.Run("FloatWriteValidation", func(t *testing.T) {
+		nan := float32(math.NaN())
+
+		// Without validation special values are written as-is
+		if err := client.WriteFloat32(200, nan); err != nil {
+			t.Fatalf("Failed to write NaN without validation: %v", err)
+		}
+		if _, err := client.ReadFloat32Checked(200); !errors.Is(err, ErrSpecialFloat) {
+			t.Errorf("Expected ErrSpecialFloat when reading NaN, got %v", err)
+		}
+
+		client.SetFloatWriteValidation(true)
+		defer client.SetFloatWriteValidation(false)
+
+		if err := client.WriteFloat32(200, nan); !errors.Is(err, ErrSpecialFloat) {
+			t.Errorf("Expected ErrSpecialFloat writing NaN, got %v", err)
+		}
+		if err := client.WriteFloat64s(200, []float64{1.5, math.Inf(1)}); !errors.Is(err, ErrSpecialFloat) {
+			t.Errorf("Expected ErrSpecialFloat writing Inf, got %v", err)
+		}
+		if err := client.WriteFloat32(200, 1.5); err != nil {
+			t.Errorf("Failed to write finite float with validation: %v", err)
+		}
+	})
+
+	t.Run("ConventionalAddresses", func(t *testing.T) {
+		values, err := client.ReadConventionalRegisters(40003, 2)
+		if err != nil {
+			t.Fatalf("Failed to read conventional registers: %v", err)
+		}
+		if values[0] != 200 || values[1] != 300 {
+			t.Errorf("Expected [200 300], got %v", values)
+		}
+
+		if err := client.WriteConventionalRegister(30001, 1); err == nil {
+			t.Error("Expected error writing an input register reference")
+		}
+	})
+
+	t.Run("StreamHoldingRegisters", func(t *testing.T) {
+		var chunks []int
+		err := client.StreamHoldingRegisters(0, 299, 125, func(address modbus.Address, values []uint16) error {
+			if address == 0 && values[5] != 500 {
+				t.Errorf("Register 5: expected 500, got %d", values[5])
+			}
+			chunks = append(chunks, len(values))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to stream holding registers: %v", err)
+		}
+		if len(chunks) != 3 || chunks[0] != 125 || chunks[1] != 125 || chunks[2] != 50 {
+			t.Errorf("Expected chunks [125 125 50], got %v", chunks)
+		}
+
+		stop := errors.New("stop")
+		calls := 0
+		err = client.StreamHoldingRegisters(0, 299, 100, func(address modbus.Address, values []uint16) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) {
+			t.Errorf("Expected callback error, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("Expected streaming to stop after 1 chunk, got %d", calls)
+		}
+	})
+
 	t.Run("WriteSingleRegister", func(t *testing.T) {
 		// Write register 20 to 12345
 		if err := client.WriteSingleRegister(20, 12345); err != nil {
@@ -269,54 +419,2387 @@ func TestClientTimeout(t *testing.T) {
 	// This should timeout
 	_, err = client.ReadCoils(0, 10)
 	if err == nil {
-		t.Error("Expected timeout error")
+		t.Fatal("Expected timeout error")
+	}
+
+	netErr, ok := err.(net.Error)
+	if !ok || !netErr.Timeout() {
+		t.Errorf("Expected net.Error reporting a timeout, got %T: %v", err, err)
 	}
 }
 
-// Benchmark client operations
-func BenchmarkClientReadHoldingRegisters(b *testing.B) {
-	// Start server
-	dataStore := NewDefaultDataStore(1000, 1000, 1000, 1000)
-	server, _ := NewTCPServer("localhost:15504", dataStore)
-	server.Start()
+func TestClientConfigJSONDefaults(t *testing.T) {
+	config, err := modbus.LoadClientConfigFromJSONString(`{"slave_id": 7, "retry_count": 0}`)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	defaults := DefaultClientConfig()
+	if config.SlaveID != 7 {
+		t.Errorf("Expected slave ID 7, got %d", config.SlaveID)
+	}
+	if config.RetryCount != 0 {
+		t.Errorf("Expected explicit retry count 0, got %d", config.RetryCount)
+	}
+	if config.RetryDelay != defaults.RetryDelay {
+		t.Errorf("Expected default retry delay %v, got %v", defaults.RetryDelay, config.RetryDelay)
+	}
+	if config.ConnectTimeout != defaults.ConnectTimeout {
+		t.Errorf("Expected default connect timeout %v, got %v", defaults.ConnectTimeout, config.ConnectTimeout)
+	}
+
+	// Round trip through a client
+	client := NewClientFromConfig(config, transport.NewTCPTransport("localhost:502"))
+	jsonStr, err := client.GetConfig().ToJSONString()
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	roundTrip, err := modbus.LoadClientConfigFromJSONString(jsonStr)
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if *roundTrip != *config {
+		t.Errorf("Config did not round trip: expected %+v, got %+v", *config, *roundTrip)
+	}
+}
+
+// unitFilterHandler only answers requests addressed to the given unit IDs
+type unitFilterHandler struct {
+	handler *ServerRequestHandler
+	units   map[modbus.SlaveID]bool
+}
+
+func (h *unitFilterHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	if !h.units[slaveID] {
+		return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeGatewayTargetFail)
+	}
+	return h.handler.HandleRequest(slaveID, req)
+}
+
+func TestScanSlaves(t *testing.T) {
+	handler := &unitFilterHandler{
+		handler: NewServerRequestHandler(NewDefaultDataStore(10, 10, 10, 10)),
+		units:   map[modbus.SlaveID]bool{2: true, 5: true},
+	}
+	server := transport.NewTCPServer("localhost:15506", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
 	defer server.Stop()
 
 	time.Sleep(100 * time.Millisecond)
 
-	// Create client
-	client := NewTCPClient("localhost:15504")
-	client.SetSlaveID(1)
-	client.Connect()
+	client := NewTCPClient("localhost:15506")
+	client.SetSlaveID(9)
+	client.SetRetryCount(3)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
 	defer client.Close()
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		client.ReadHoldingRegisters(0, 100)
+	t.Run("ReportServerID", func(t *testing.T) {
+		found := client.ScanSlaves(0, 6, nil)
+		if len(found) != 2 || found[0] != 2 || found[1] != 5 {
+			t.Errorf("Expected slaves [2 5], got %v", found)
+		}
+	})
+
+	t.Run("ReadHoldingRegister", func(t *testing.T) {
+		found := client.ScanSlaves(1, 3, ProbeReadHoldingRegister(0))
+		if len(found) != 1 || found[0] != 2 {
+			t.Errorf("Expected slaves [2], got %v", found)
+		}
+	})
+
+	if client.GetSlaveID() != 9 {
+		t.Errorf("Expected slave ID to be restored to 9, got %d", client.GetSlaveID())
+	}
+	if client.GetRetryCount() != 3 {
+		t.Errorf("Expected retry count to be restored to 3, got %d", client.GetRetryCount())
 	}
 }
 
-func BenchmarkClientWriteMultipleRegisters(b *testing.B) {
-	// Start server
-	dataStore := NewDefaultDataStore(1000, 1000, 1000, 1000)
-	server, _ := NewTCPServer("localhost:15505", dataStore)
-	server.Start()
+// fakeSerialLine is an RTU transport whose slave only answers at the line
+// settings in answer. Opening the port at failBaud fails.
+type fakeSerialLine struct {
+	config    transport.SerialConfig
+	answer    transport.SerialConfig
+	failBaud  int
+	connected bool
+	handler   *ServerRequestHandler
+}
+
+func (l *fakeSerialLine) Connect() error {
+	if l.config.BaudRate == l.failBaud {
+		return fmt.Errorf("failed to open serial port at %d baud", l.failBaud)
+	}
+	l.connected = true
+	return nil
+}
+
+func (l *fakeSerialLine) Close() error {
+	l.connected = false
+	return nil
+}
+
+func (l *fakeSerialLine) IsConnected() bool { return l.connected }
+
+func (l *fakeSerialLine) SendRequest(slaveID modbus.SlaveID, request *pdu.Request) (*pdu.Response, error) {
+	if !l.connected {
+		return nil, fmt.Errorf("transport not connected")
+	}
+	if l.config.BaudRate != l.answer.BaudRate || l.config.Parity != l.answer.Parity {
+		return nil, &modbus.TimeoutError{Err: errors.New("response timeout")}
+	}
+	return l.handler.HandleRequest(slaveID, request), nil
+}
+
+func (l *fakeSerialLine) SetTimeout(timeout time.Duration)       {}
+func (l *fakeSerialLine) GetTimeout() time.Duration              { return time.Second }
+func (l *fakeSerialLine) GetTransportType() modbus.TransportType { return modbus.TransportRTU }
+func (l *fakeSerialLine) String() string                         { return "fakeSerialLine" }
+func (l *fakeSerialLine) GetConfig() *transport.SerialConfig     { config := l.config; return &config }
+
+func (l *fakeSerialLine) Reconfigure(config *transport.SerialConfig) error {
+	wasConnected := l.connected
+	l.connected = false
+	l.config = *config
+	if !wasConnected {
+		return nil
+	}
+	return l.Connect()
+}
+
+func TestDetectSerialSettings(t *testing.T) {
+	original, _ := transport.NewSerialConfig("/dev/ttyUSB0", 9600, 8, 1, "N")
+	newLine := func(answerBaud int, answerParity string, failBaud int) *fakeSerialLine {
+		answer, _ := transport.NewSerialConfig("/dev/ttyUSB0", answerBaud, 8, 1, answerParity)
+		return &fakeSerialLine{
+			config:    *original,
+			answer:    *answer,
+			failBaud:  failBaud,
+			connected: true,
+			handler:   NewServerRequestHandler(NewDefaultDataStore(10, 10, 10, 10)),
+		}
+	}
+	bauds := []int{9600, 19200, 38400}
+	parities := []string{"N", "E"}
+
+	t.Run("Match", func(t *testing.T) {
+		line := newLine(19200, "E", 0)
+		client := NewClient(line)
+		found, err := client.DetectSerialSettings(bauds, parities, ProbeReadHoldingRegister(0))
+		if err != nil {
+			t.Fatalf("Expected settings to be found, got %v", err)
+		}
+		if found.BaudRate != 19200 || found.Parity != line.answer.Parity {
+			t.Errorf("Expected 19200 baud with even parity, got %d baud with %v", found.BaudRate, found.Parity)
+		}
+		if line.config.BaudRate != 19200 || line.config.Parity != line.answer.Parity || !line.connected {
+			t.Errorf("Expected the found settings to stay applied, got %+v", line.config)
+		}
+	})
+
+	t.Run("NoResponse", func(t *testing.T) {
+		line := newLine(115200, "O", 0)
+		client := NewClient(line)
+		if _, err := client.DetectSerialSettings(bauds, parities, ProbeReadHoldingRegister(0)); err == nil {
+			t.Fatal("Expected an error when nothing answers")
+		}
+		if line.config.BaudRate != original.BaudRate || line.config.Parity != original.Parity || !line.connected {
+			t.Errorf("Expected the original settings to be restored and connected, got %+v", line.config)
+		}
+	})
+
+	t.Run("RestoreOnError", func(t *testing.T) {
+		line := newLine(38400, "E", 19200)
+		client := NewClient(line)
+		_, err := client.DetectSerialSettings(bauds, parities, ProbeReadHoldingRegister(0))
+		if err == nil || !strings.Contains(err.Error(), "19200 baud") {
+			t.Fatalf("Expected the open failure at 19200 baud, got %v", err)
+		}
+		if line.config.BaudRate != original.BaudRate || line.config.Parity != original.Parity || !line.connected {
+			t.Errorf("Expected the original settings to be restored and connected, got %+v", line.config)
+		}
+	})
+}
+
+func TestClientConcurrentConfig(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, err := NewTCPServer("localhost:15509", dataStore)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
 	defer server.Stop()
 
 	time.Sleep(100 * time.Millisecond)
 
-	// Create client
-	client := NewTCPClient("localhost:15505")
-	client.SetSlaveID(1)
-	client.Connect()
+	client := NewTCPClient("localhost:15509")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
 	defer client.Close()
 
-	values := make([]uint16, 100)
-	for i := range values {
-		values[i] = uint16(i)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			client.SetSlaveID(1)
+			client.SetTimeout(time.Second)
+			client.SetRetryCount(i % 3)
+			client.SetEncoding(BigEndian, HighWordFirst)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if _, err := client.ReadHoldingRegisters(0, 2); err != nil {
+				t.Errorf("Failed to read holding registers: %v", err)
+				return
+			}
+			_ = client.GetConfig()
+		}
+	}()
+	wg.Wait()
+
+	if client.GetSlaveID() != 1 {
+		t.Errorf("Expected slave ID 1, got %d", client.GetSlaveID())
 	}
+}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		client.WriteMultipleRegisters(0, values)
+// slowFirstHandler delays its first response beyond the client timeout
+type slowFirstHandler struct {
+	handler *ServerRequestHandler
+	delay   time.Duration
+	calls   int32
+}
+
+func (h *slowFirstHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	if atomic.AddInt32(&h.calls, 1) == 1 {
+		time.Sleep(h.delay)
+	}
+	return h.handler.HandleRequest(slaveID, req)
+}
+
+func TestAutoReconnectDiscardsStaleResponse(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	dataStore.SetHoldingRegister(0, 1234)
+	handler := &slowFirstHandler{handler: NewServerRequestHandler(dataStore), delay: 300 * time.Millisecond}
+	server := transport.NewTCPServer("localhost:15510", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15510")
+	client.SetTimeout(100 * time.Millisecond)
+	client.SetRetryCount(1)
+	client.SetRetryDelay(400 * time.Millisecond)
+	client.SetAutoReconnect(true)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// The first attempt times out and its late response must not be read
+	// as the answer to the retry
+	values, err := client.ReadHoldingRegisters(0, 1)
+	if err != nil {
+		t.Fatalf("Expected retry after reconnect to succeed, got %v", err)
+	}
+	if values[0] != 1234 {
+		t.Errorf("Expected 1234, got %d", values[0])
+	}
+}
+
+func TestAutoReconnectAfterServerRestart(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, err := NewTCPServer("localhost:15511", dataStore)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15511")
+	// The request after the restart is sent on the dead connection, so the
+	// reconnect and resend use up the retry
+	client.SetRetryCount(1)
+	client.SetAutoReconnect(true)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("Failed to read holding registers: %v", err)
+	}
+
+	// Restarting the server leaves the client with a half-open connection
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Failed to stop server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to restart server: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Errorf("Expected first request after restart to succeed, got %v", err)
+	}
+}
+
+func TestAutoReconnectDoesNotResendUncounted(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:15553")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	// The device receives each request and drops the connection without
+	// answering, as if it rebooted after applying a write
+	var received atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 260)
+				if n, _ := conn.Read(buf); n > 0 {
+					received.Add(1)
+				}
+			}()
+		}
+	}()
+
+	client := NewTCPClient("localhost:15553")
+	client.SetRetryCount(0)
+	client.SetAutoReconnect(true)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.WriteSingleRegister(0, 1); err == nil {
+		t.Fatal("Expected write to fail when the device drops the connection")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := received.Load(); n != 1 {
+		t.Errorf("Expected the write to reach the device once with no retries, got %d", n)
+	}
+}
+
+func TestCircuitBreakerWithAutoReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:15556")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	// The device accepts connections but drops every request unanswered
+	var received atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 260)
+				if n, _ := conn.Read(buf); n > 0 {
+					received.Add(1)
+				}
+			}()
+		}
+	}()
+
+	breaker := transport.NewCircuitBreakerTransport(transport.NewTCPTransport("localhost:15556"), 3, time.Minute)
+	client := NewClient(breaker)
+	client.SetRetryCount(0)
+	client.SetAutoReconnect(true)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// Each failure drops the connection, and reconnecting succeeds
+	for i := 0; i < 3; i++ {
+		if _, err := client.ReadHoldingRegisters(0, 1); err == nil || errors.Is(err, transport.ErrCircuitOpen) {
+			t.Fatalf("Read %d: expected the device to fail the request, got %v", i, err)
+		}
+	}
+	if breaker.State() != transport.CircuitOpen {
+		t.Fatalf("Expected open circuit after 3 failures, got %s", breaker.State())
+	}
+
+	if _, err := client.ReadHoldingRegisters(0, 1); !errors.Is(err, transport.ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := received.Load(); n != 3 {
+		t.Errorf("Expected 3 requests to reach the device, got %d", n)
+	}
+}
+
+func TestRetryObserver(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	handler := &slowFirstHandler{handler: NewServerRequestHandler(dataStore), delay: 300 * time.Millisecond}
+	server := transport.NewTCPServer("localhost:15512", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15512")
+	client.SetTimeout(100 * time.Millisecond)
+	client.SetRetryCount(2)
+	client.SetRetryDelay(400 * time.Millisecond)
+	client.SetAutoReconnect(true)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	var attempts []int
+	client.SetRetryObserver(func(slaveID modbus.SlaveID, fc modbus.FunctionCode, attempt int, err error) {
+		if err != nil {
+			t.Errorf("Unexpected request error: %v", err)
+		}
+		attempts = append(attempts, attempt)
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+			t.Fatalf("Failed to read holding registers: %v", err)
+		}
+	}
+
+	if len(attempts) != 2 || attempts[0] != 2 || attempts[1] != 1 {
+		t.Errorf("Expected attempts [2 1], got %v", attempts)
+	}
+}
+
+// gatewayFailHandler answers with a gateway exception for the first failures requests
+type gatewayFailHandler struct {
+	handler  *ServerRequestHandler
+	failures int32
+	calls    int32
+}
+
+func (h *gatewayFailHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	if atomic.AddInt32(&h.calls, 1) <= h.failures {
+		return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeGatewayTargetFail)
+	}
+	return h.handler.HandleRequest(slaveID, req)
+}
+
+func TestGatewayRetry(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	handler := &gatewayFailHandler{handler: NewServerRequestHandler(dataStore), failures: 2}
+	server := transport.NewTCPServer("localhost:15513", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15513")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// Without gateway retries the exception is returned immediately
+	_, err := client.ReadHoldingRegisters(0, 1)
+	if !IsGatewayError(err) {
+		t.Fatalf("Expected gateway error, got %v", err)
+	}
+
+	client.SetGatewayRetry(2, 10*time.Millisecond)
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Errorf("Expected gateway retry to succeed, got %v", err)
+	}
+	if calls := atomic.LoadInt32(&handler.calls); calls != 3 {
+		t.Errorf("Expected 3 requests, got %d", calls)
+	}
+}
+
+func TestLatencyObserver(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, err := NewTCPServer("localhost:15507", dataStore)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15507")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	var calls int
+	var lastFC modbus.FunctionCode
+	var lastLatency time.Duration
+	client.SetLatencyObserver(func(slaveID modbus.SlaveID, fc modbus.FunctionCode, latency time.Duration, err error) {
+		calls++
+		lastFC = fc
+		lastLatency = latency
+		if err != nil {
+			t.Errorf("Unexpected request error: %v", err)
+		}
+	})
+
+	if _, err := client.ReadHoldingRegisters(0, 2); err != nil {
+		t.Fatalf("Failed to read holding registers: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected 1 observer call, got %d", calls)
+	}
+	if lastFC != modbus.FuncCodeReadHoldingRegisters {
+		t.Errorf("Expected function code %s, got %s", modbus.FunctionCode(modbus.FuncCodeReadHoldingRegisters), lastFC)
+	}
+	if lastLatency <= 0 {
+		t.Errorf("Expected positive latency, got %v", lastLatency)
+	}
+}
+
+// Benchmark client operations
+func BenchmarkClientReadHoldingRegisters(b *testing.B) {
+	// Start server
+	dataStore := NewDefaultDataStore(1000, 1000, 1000, 1000)
+	server, _ := NewTCPServer("localhost:15504", dataStore)
+	server.Start()
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Create client
+	client := NewTCPClient("localhost:15504")
+	client.SetSlaveID(1)
+	client.Connect()
+	defer client.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client.ReadHoldingRegisters(0, 100)
+	}
+}
+
+func BenchmarkClientWriteMultipleRegisters(b *testing.B) {
+	// Start server
+	dataStore := NewDefaultDataStore(1000, 1000, 1000, 1000)
+	server, _ := NewTCPServer("localhost:15505", dataStore)
+	server.Start()
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Create client
+	client := NewTCPClient("localhost:15505")
+	client.SetSlaveID(1)
+	client.Connect()
+	defer client.Close()
+
+	values := make([]uint16, 100)
+	for i := range values {
+		values[i] = uint16(i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client.WriteMultipleRegisters(0, values)
+	}
+}
+
+func TestGetEncodingReturnsCopy(t *testing.T) {
+	client := NewTCPClient("localhost:502")
+	client.SetEncoding(LittleEndian, LowWordFirst)
+
+	enc := client.GetEncoding()
+	enc.ByteOrder = BigEndian
+	enc.WordOrder = HighWordFirst
+
+	current := client.GetEncoding()
+	if current.ByteOrder != LittleEndian {
+		t.Errorf("Expected byte order LittleEndian, got %v", current.ByteOrder)
+	}
+	if current.WordOrder != LowWordFirst {
+		t.Errorf("Expected word order LowWordFirst, got %v", current.WordOrder)
+	}
+}
+
+func TestWriteMultipleRejectsEmptyValues(t *testing.T) {
+	// The client is never connected; empty writes fail before any I/O
+	client := NewTCPClient("localhost:502")
+
+	if err := client.WriteMultipleCoils(0, nil); err == nil || err.Error() != "no coil values provided" {
+		t.Errorf("Expected no coil values error, got %v", err)
+	}
+	if err := client.WriteMultipleRegisters(0, []uint16{}); err == nil || err.Error() != "no register values provided" {
+		t.Errorf("Expected no register values error, got %v", err)
+	}
+}
+
+func TestConventionalAddressing(t *testing.T) {
+	tests := []struct {
+		ref     uint32
+		fc      modbus.FunctionCode
+		address modbus.Address
+	}{
+		{1, modbus.FuncCodeReadCoils, 0},
+		{10001, modbus.FuncCodeReadDiscreteInputs, 0},
+		{30010, modbus.FuncCodeReadInputRegisters, 9},
+		{40001, modbus.FuncCodeReadHoldingRegisters, 0},
+		{49999, modbus.FuncCodeReadHoldingRegisters, 9998},
+		{410000, modbus.FuncCodeReadHoldingRegisters, 9999},
+		{465536, modbus.FuncCodeReadHoldingRegisters, 65535},
+	}
+
+	for _, tt := range tests {
+		fc, address, err := FromConventional(tt.ref)
+		if err != nil {
+			t.Errorf("FromConventional(%d): unexpected error: %v", tt.ref, err)
+			continue
+		}
+		if fc != tt.fc || address != tt.address {
+			t.Errorf("FromConventional(%d): expected (%s, %d), got (%s, %d)", tt.ref, tt.fc, tt.address, fc, address)
+		}
+
+		ref, err := ToConventional(tt.fc, tt.address)
+		if err != nil {
+			t.Errorf("ToConventional(%s, %d): unexpected error: %v", tt.fc, tt.address, err)
+			continue
+		}
+		if ref != tt.ref {
+			t.Errorf("ToConventional(%s, %d): expected %d, got %d", tt.fc, tt.address, tt.ref, ref)
+		}
+	}
+
+	for _, ref := range []uint32{0, 40000, 20001, 465537} {
+		if _, _, err := FromConventional(ref); err == nil {
+			t.Errorf("FromConventional(%d): expected error", ref)
+		}
+	}
+}
+
+func TestDetectFloatEncoding(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	server, _ := NewTCPServer("localhost:15518", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15518")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// Store 123.456 word-swapped, as many Schneider devices do
+	bits := math.Float32bits(123.456)
+	_ = dataStore.SetHoldingRegister(10, uint16(bits))
+	_ = dataStore.SetHoldingRegister(11, uint16(bits>>16))
+
+	enc, err := client.DetectFloatEncoding(10, 123.456)
+	if err != nil {
+		t.Fatalf("Failed to detect encoding: %v", err)
+	}
+	if enc.ByteOrder != BigEndian || enc.WordOrder != LowWordFirst {
+		t.Errorf("Expected BigEndian/LowWordFirst, got %v/%v", enc.ByteOrder, enc.WordOrder)
+	}
+	if current := client.GetEncoding(); current.WordOrder != HighWordFirst {
+		t.Error("Expected DetectFloatEncoding to leave the client encoding unchanged")
+	}
+
+	if _, err := client.DetectFloatEncoding(10, 99.5); err == nil {
+		t.Error("Expected error when no encoding matches")
+	}
+}
+
+func TestExceptionErrorIncludesRequest(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	server, _ := NewTCPServer("localhost:15519", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15519")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	_, err := client.ReadCoils(200, 5)
+	var modbusErr *ModbusError
+	if !errors.As(err, &modbusErr) {
+		t.Fatalf("Expected ModbusError, got %v", err)
+	}
+	if modbusErr.ExceptionCode != modbus.ExceptionCodeIllegalDataAddress {
+		t.Errorf("Expected IllegalDataAddress, got %v", modbusErr.ExceptionCode)
+	}
+	if modbusErr.Message != "address 200, quantity 5" {
+		t.Errorf("Expected request context in message, got %q", modbusErr.Message)
+	}
+}
+
+func TestReadLayout(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	server, _ := NewTCPServer("localhost:15520", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15520")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.WriteFloat32(0, 21.5); err != nil {
+		t.Fatalf("Failed to write float: %v", err)
+	}
+	if err := client.WriteSingleRegister(2, 7); err != nil {
+		t.Fatalf("Failed to write status: %v", err)
+	}
+	if err := client.WriteInt32(4, -42); err != nil {
+		t.Fatalf("Failed to write count: %v", err)
+	}
+
+	layout := Layout{}.AddFloat32("temp").AddUint16("status").AddSkip(1).AddInt32("count")
+	if layout.Registers() != 6 {
+		t.Fatalf("Expected 6 registers, got %d", layout.Registers())
+	}
+
+	values, err := client.ReadLayout(0, layout)
+	if err != nil {
+		t.Fatalf("Failed to read layout: %v", err)
+	}
+	if v, _ := values.Float32("temp"); v != 21.5 {
+		t.Errorf("Expected temp 21.5, got %v", v)
+	}
+	if v, _ := values.Uint16("status"); v != 7 {
+		t.Errorf("Expected status 7, got %v", v)
+	}
+	if v, _ := values.Int32("count"); v != -42 {
+		t.Errorf("Expected count -42, got %v", v)
+	}
+	if _, ok := values.Uint16("temp"); ok {
+		t.Error("Expected type mismatch to report not ok")
+	}
+
+	if _, err := client.ReadLayout(0, Layout{}); err == nil {
+		t.Error("Expected error for empty layout")
+	}
+
+	// A skip below 1 register is rejected instead of misaligning the fields
+	for _, n := range []int{0, -1} {
+		bad := Layout{}.AddUint16("a").AddSkip(n).AddUint16("b")
+		if _, err := client.ReadLayout(0, bad); err == nil {
+			t.Errorf("Expected ReadLayout error for AddSkip(%d)", n)
+		}
+		if _, err := client.DecodeLayout(bad, []uint16{1, 2, 3}); err == nil {
+			t.Errorf("Expected DecodeLayout error for AddSkip(%d)", n)
+		}
+	}
+}
+
+// recordingLogger collects formatted log lines
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestSlowRequestThreshold(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	handler := &slowFirstHandler{handler: NewServerRequestHandler(dataStore), delay: 100 * time.Millisecond}
+	server := transport.NewTCPServer("localhost:15521", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15521")
+	logger := &recordingLogger{}
+	client.SetLogger(logger)
+	client.SetSlowRequestThreshold(50 * time.Millisecond)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+			t.Fatalf("Read %d failed: %v", i, err)
+		}
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	var warnings []string
+	for _, line := range logger.lines {
+		if strings.HasPrefix(line, "slow MODBUS request") {
+			warnings = append(warnings, line)
+		}
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 slow request warning, got %d: %v", len(warnings), warnings)
+	}
+	if !strings.Contains(warnings[0], "slave 1") {
+		t.Errorf("Expected warning to name the slave, got %q", warnings[0])
+	}
+}
+
+func TestSlowRequestThresholdUsesTransportLogger(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	handler := &slowFirstHandler{handler: NewServerRequestHandler(dataStore), delay: 100 * time.Millisecond}
+	server := transport.NewTCPServer("localhost:15554", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The logger is only given to the transport
+	logger := &recordingLogger{}
+	client := NewClient(transport.NewTCPTransportWithConfig(transport.TCPTransportConfig{
+		Address: "localhost:15554",
+		Logger:  logger,
+	}))
+	client.SetSlowRequestThreshold(50 * time.Millisecond)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	found := false
+	for _, line := range logger.lines {
+		if strings.HasPrefix(line, "slow MODBUS request") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a slow request warning through the transport logger, got %v", logger.lines)
+	}
+}
+
+func TestPerCallEncoding(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	server, _ := NewTCPServer("localhost:15522", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15522")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// Big-endian block at 0, word-swapped block at 10
+	_ = dataStore.SetInputRegister(0, 0x1234)
+	_ = dataStore.SetInputRegister(1, 0x5678)
+	_ = dataStore.SetInputRegister(10, 0x5678)
+	_ = dataStore.SetInputRegister(11, 0x1234)
+
+	if v, err := client.ReadInputUint32(0); err != nil || v != 0x12345678 {
+		t.Errorf("Expected 0x12345678 with client encoding, got 0x%08X (%v)", v, err)
+	}
+
+	swapped := &EncodingConfig{ByteOrder: BigEndian, WordOrder: LowWordFirst}
+	if v, err := client.ReadInputUint32Enc(10, swapped); err != nil || v != 0x12345678 {
+		t.Errorf("Expected 0x12345678 with per-call encoding, got 0x%08X (%v)", v, err)
+	}
+	if enc := client.GetEncoding(); enc.WordOrder != HighWordFirst {
+		t.Error("Expected per-call encoding to leave the client encoding unchanged")
+	}
+}
+
+// truncatingWriteHandler acknowledges multi-register writes in full but only
+// applies the first register, like a misbehaving gateway
+type truncatingWriteHandler struct {
+	handler *ServerRequestHandler
+}
+
+func (h *truncatingWriteHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	if req.FunctionCode != modbus.FuncCodeWriteMultipleRegisters {
+		return h.handler.HandleRequest(slaveID, req)
+	}
+	address, _ := pdu.DecodeUint16(req.Data[0:2])
+	value, _ := pdu.DecodeUint16(req.Data[5:7])
+	single, _ := pdu.WriteSingleRegisterRequest(modbus.Address(address), value)
+	h.handler.HandleRequest(slaveID, single)
+	return pdu.NewResponse(req.FunctionCode, req.Data[0:4])
+}
+
+func TestWriteVerification(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	handler := &truncatingWriteHandler{handler: NewServerRequestHandler(dataStore)}
+	server := transport.NewTCPServer("localhost:15523", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15523")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.WriteMultipleRegisters(0, []uint16{1, 2, 3}); err != nil {
+		t.Fatalf("Expected unverified write to succeed, got %v", err)
+	}
+
+	client.SetWriteVerification(true)
+	if err := client.WriteMultipleRegisters(0, []uint16{1, 2, 3}); !errors.Is(err, ErrWriteVerification) {
+		t.Errorf("Expected ErrWriteVerification, got %v", err)
+	}
+	if err := client.WriteMultipleRegisters(10, []uint16{7}); err != nil {
+		t.Errorf("Expected single register write to verify, got %v", err)
+	}
+}
+
+// pauseOnWriteHandler starts pausing the client while it answers a write
+type pauseOnWriteHandler struct {
+	handler *ServerRequestHandler
+	client  *Client
+	paused  chan *Client
+}
+
+func (h *pauseOnWriteHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	if req.FunctionCode == modbus.FuncCodeWriteMultipleRegisters {
+		go func() { h.paused <- h.client.Pause() }()
+		time.Sleep(50 * time.Millisecond) // let Pause start waiting
+	}
+	return h.handler.HandleRequest(slaveID, req)
+}
+
+func TestWriteVerificationHoldsPauseGate(t *testing.T) {
+	handler := &pauseOnWriteHandler{handler: NewServerRequestHandler(NewDefaultDataStore(10, 10, 10, 10)), paused: make(chan *Client, 1)}
+	server := transport.NewTCPServer("localhost:15559", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15559")
+	client.SetWriteVerification(true)
+	handler.client = client
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// The read back runs before the pending Pause takes effect
+	if err := client.WriteMultipleRegisters(0, []uint16{1, 2}); err != nil {
+		t.Errorf("Expected the verified write to succeed, got %v", err)
+	}
+	<-handler.paused
+	client.Resume()
+}
+
+func TestCloseDisablesAutoReconnect(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, _ := NewTCPServer("localhost:15524", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15524")
+	client.SetAutoReconnect(true)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("Expected second Close to be a no-op, got %v", err)
+	}
+
+	if _, err := client.ReadHoldingRegisters(0, 1); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed after Close, got %v", err)
+	}
+	if client.IsConnected() {
+		t.Error("Expected closed client not to reconnect")
+	}
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Errorf("Expected request to succeed after Connect, got %v", err)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	_ = dataStore.SetHoldingRegister(3, 4321)
+	server, _ := NewTCPServer("localhost:15525", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	var recording bytes.Buffer
+	recorder := NewClient(transport.NewRecordingTransport(transport.NewTCPTransport("localhost:15525"), &recording))
+	if err := recorder.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if _, err := recorder.ReadHoldingRegisters(3, 1); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	_, _ = recorder.ReadHoldingRegisters(50, 1) // recorded exception
+	recorder.Close()
+
+	// A device slow enough to time out
+	slowHandler := &slowFirstHandler{handler: NewServerRequestHandler(dataStore), delay: 300 * time.Millisecond}
+	slowServer := transport.NewTCPServer("localhost:15557", slowHandler)
+	if err := slowServer.Start(); err != nil {
+		t.Fatalf("Failed to start slow server: %v", err)
+	}
+	defer func() { _ = slowServer.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	slowRecorder := NewClient(transport.NewRecordingTransport(transport.NewTCPTransport("localhost:15557"), &recording))
+	slowRecorder.SetRetryCount(0)
+	slowRecorder.SetTimeout(100 * time.Millisecond)
+	if err := slowRecorder.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	_, recordedErr := slowRecorder.ReadHoldingRegisters(3, 1) // recorded timeout
+	slowRecorder.Close()
+	if !IsTimeout(recordedErr) {
+		t.Fatalf("Expected a timeout to record, got %v", recordedErr)
+	}
+
+	replay, err := transport.NewReplayTransport(&recording)
+	if err != nil {
+		t.Fatalf("Failed to load recording: %v", err)
+	}
+	client := NewClient(replay)
+	client.SetRetryCount(0)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect replay: %v", err)
+	}
+
+	values, err := client.ReadHoldingRegisters(3, 1)
+	if err != nil || values[0] != 4321 {
+		t.Errorf("Expected replayed value 4321, got %v (%v)", values, err)
+	}
+	var modbusErr *ModbusError
+	if _, err := client.ReadHoldingRegisters(50, 1); !errors.As(err, &modbusErr) {
+		t.Errorf("Expected replayed exception, got %v", err)
+	}
+	if _, err := client.ReadHoldingRegisters(3, 1); !IsTimeout(err) || err.Error() != recordedErr.Error() {
+		t.Errorf("Expected replayed timeout %q, got %v", recordedErr, err)
+	}
+	if replay.Remaining() != 0 {
+		t.Errorf("Expected all exchanges replayed, %d left", replay.Remaining())
+	}
+	_ = client.Connect()
+	if _, err := client.ReadHoldingRegisters(3, 1); !errors.Is(err, transport.ErrReplayExhausted) {
+		t.Errorf("Expected ErrReplayExhausted, got %v", err)
+	}
+}
+
+func TestBroadcastDoesNotWaitForResponse(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, _ := NewTCPServer("localhost:15526", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15526")
+	client.SetTimeout(500 * time.Millisecond)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	start := time.Now()
+	if err := client.BroadcastWriteSingleRegister(2, 77); err != nil {
+		t.Fatalf("Broadcast failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("Expected broadcast to return without waiting, took %v", elapsed)
+	}
+
+	// This server answers broadcasts; the late response must not be mistaken
+	// for the response to the next request
+	values, err := client.ReadHoldingRegisters(2, 1)
+	if err != nil {
+		t.Fatalf("Read after broadcast failed: %v", err)
+	}
+	if values[0] != 77 {
+		t.Errorf("Expected broadcast value 77, got %d", values[0])
+	}
+}
+
+// writeFailTransport fails every request write
+type writeFailTransport struct {
+	transport.Transport
+}
+
+func (t *writeFailTransport) SendRequest(slaveID modbus.SlaveID, req *pdu.Request) (*pdu.Response, error) {
+	return nil, &modbus.WriteError{Err: net.ErrClosed}
+}
+
+func TestBroadcastReportsWriteFailure(t *testing.T) {
+	replay, _ := transport.NewReplayTransport(bytes.NewReader(nil))
+	client := NewClient(&writeFailTransport{Transport: replay})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	err := client.BroadcastWriteSingleRegister(0, 1)
+	if !IsWriteError(err) {
+		t.Errorf("Expected write error from broadcast, got %v", err)
+	}
+}
+
+func TestSnapshotAll(t *testing.T) {
+	dataStore := NewDefaultDataStore(50, 50, 300, 20)
+	server, _ := NewTCPServer("localhost:15527", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 300; i++ {
+		_ = dataStore.SetHoldingRegister(modbus.Address(i), uint16(i))
+	}
+	_ = dataStore.SetCoil(3, true)
+	_ = dataStore.SetInputRegister(5, 55)
+
+	client := NewTCPClient("localhost:15527")
+	client.SetRetryCount(0)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	snapshot, err := client.SnapshotAll(SnapshotSpec{
+		Coils:            []SnapshotRange{{Address: 0, Quantity: 10}},
+		HoldingRegisters: []SnapshotRange{{Address: 0, Quantity: 150}, {Address: 100, Quantity: 100}},
+		InputRegisters:   []SnapshotRange{{Address: 0, Quantity: 10}, {Address: 15, Quantity: 10}},
+	})
+
+	// Input registers 20-24 do not exist; everything else is still returned
+	var modbusErr *modbus.ModbusError
+	if !errors.As(err, &modbusErr) || modbusErr.ExceptionCode != modbus.ExceptionCodeIllegalDataAddress {
+		t.Fatalf("Expected illegal data address error, got %v", err)
+	}
+	if len(snapshot.HoldingRegisters) != 200 || snapshot.HoldingRegisters[199] != 199 {
+		t.Errorf("Expected holding registers 0-199, got %d values", len(snapshot.HoldingRegisters))
+	}
+	if !snapshot.Coils[3] || len(snapshot.Coils) != 10 {
+		t.Errorf("Unexpected coils %v", snapshot.Coils)
+	}
+	if len(snapshot.DiscreteInputs) != 0 {
+		t.Errorf("Expected no discrete inputs, got %d", len(snapshot.DiscreteInputs))
+	}
+	if snapshot.InputRegisters[5] != 55 || len(snapshot.InputRegisters) != 10 {
+		t.Errorf("Unexpected input registers %v", snapshot.InputRegisters)
+	}
+}
+
+// quantityLimitHandler rejects register reads above limit with IllegalDataValue
+type quantityLimitHandler struct {
+	handler *ServerRequestHandler
+	limit   uint16
+}
+
+func (h *quantityLimitHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	if req.FunctionCode == modbus.FuncCodeReadHoldingRegisters && len(req.Data) == 4 {
+		if quantity, _ := pdu.DecodeUint16(req.Data[2:4]); quantity > h.limit {
+			return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeIllegalDataValue)
+		}
+	}
+	return h.handler.HandleRequest(slaveID, req)
+}
+
+func TestDiscoverMaxReadQuantity(t *testing.T) {
+	handler := &quantityLimitHandler{handler: NewServerRequestHandler(NewDefaultDataStore(10, 10, 300, 10)), limit: 37}
+	server := transport.NewTCPServer("localhost:15529", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15529")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	limit, err := client.DiscoverMaxReadQuantity(modbus.FuncCodeReadHoldingRegisters, 0)
+	if err != nil {
+		t.Fatalf("Discovery failed: %v", err)
+	}
+	if limit != 37 {
+		t.Errorf("Expected limit 37, got %d", limit)
+	}
+
+	// Chunked reads now stay within the discovered limit
+	snapshot, err := client.SnapshotAll(SnapshotSpec{HoldingRegisters: []SnapshotRange{{Address: 0, Quantity: 100}}})
+	if err != nil || len(snapshot.HoldingRegisters) != 100 {
+		t.Errorf("Expected 100 registers read in chunks, got %d (%v)", len(snapshot.HoldingRegisters), err)
+	}
+
+	if _, err := client.DiscoverMaxReadQuantity(modbus.FuncCodeWriteSingleCoil, 0); err == nil {
+		t.Error("Expected error for non-read function code")
+	}
+}
+
+func TestSendRawPDU(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, _ := NewTCPServer("localhost:15530", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	_ = dataStore.SetHoldingRegister(1, 0xBEEF)
+
+	client := NewTCPClient("localhost:15530")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	resp, err := client.SendRawPDU(modbus.FuncCodeReadHoldingRegisters, []byte{0x00, 0x01, 0x00, 0x01})
+	if err != nil {
+		t.Fatalf("SendRawPDU failed: %v", err)
+	}
+	if resp.Code() != modbus.FuncCodeReadHoldingRegisters || !bytes.Equal(resp.Payload(), []byte{0x02, 0xBE, 0xEF}) {
+		t.Errorf("Unexpected response % X", resp.Bytes())
+	}
+	if resp.Err() != nil {
+		t.Errorf("Expected no error for normal response, got %v", resp.Err())
+	}
+
+	// Function codes the server does not implement come back as exceptions
+	resp, err = client.SendRawPDU(0x41, []byte{0x01})
+	var modbusErr *modbus.ModbusError
+	if !errors.As(err, &modbusErr) || modbusErr.ExceptionCode != modbus.ExceptionCodeIllegalFunction {
+		t.Errorf("Expected IllegalFunction error, got %v", err)
+	}
+	if resp == nil || !resp.IsException() || resp.Code() != 0xC1 {
+		t.Fatal("Expected the exception response to be returned")
+	}
+	if !errors.As(resp.Err(), &modbusErr) || modbusErr.FunctionCode != 0x41 {
+		t.Errorf("Expected Err to report function code 0x41, got %v", resp.Err())
+	}
+
+	if _, err := client.SendRawPDU(0x83, nil); err == nil {
+		t.Error("Expected error for exception function code")
+	}
+
+	// A response for another function code
+	replay, _ := transport.NewReplayTransport(strings.NewReader(`{"slave_id":1,"request":"4101","response":"4201"}`))
+	mismatched := NewClient(replay)
+	_ = mismatched.Connect()
+	if _, err := mismatched.SendRawPDU(0x41, []byte{0x01}); !errors.Is(err, pdu.ErrFunctionCodeMismatch) {
+		t.Errorf("Expected ErrFunctionCodeMismatch, got %v", err)
+	}
+}
+
+func TestReadScaledIntegers(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, _ := NewTCPServer("localhost:15531", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// -12345 as a big-endian int32, scale factor -2
+	_ = dataStore.SetHoldingRegister(0, 0xFFFF)
+	_ = dataStore.SetHoldingRegister(1, 0xCFC7)
+	_ = dataStore.SetHoldingRegister(2, 0xFFFE)
+	// 2301 with scale factor 1
+	_ = dataStore.SetHoldingRegister(3, 2301)
+	_ = dataStore.SetHoldingRegister(4, 1)
+
+	client := NewTCPClient("localhost:15531")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	v, err := client.ReadScaledInt32(0, 2)
+	if err != nil {
+		t.Fatalf("ReadScaledInt32 failed: %v", err)
+	}
+	if math.Abs(v-(-123.45)) > 1e-9 {
+		t.Errorf("Expected -123.45, got %v", v)
+	}
+
+	v, err = client.ReadScaledUint16(3, 4)
+	if err != nil {
+		t.Fatalf("ReadScaledUint16 failed: %v", err)
+	}
+	if v != 23010 {
+		t.Errorf("Expected 23010, got %v", v)
+	}
+
+	if _, err := client.ReadScaledUint16(3, 20); err == nil {
+		t.Error("Expected error for out of range scale factor address")
+	}
+
+	// SunSpec "not implemented" markers are not scaled as readings
+	_ = dataStore.SetHoldingRegister(5, 0x8000)
+	_ = dataStore.SetHoldingRegister(6, 0x0000)
+	_ = dataStore.SetHoldingRegister(7, 0xFFFF)
+	_ = dataStore.SetHoldingRegister(8, 0x8000)
+	if _, err := client.ReadScaledInt32(5, 2); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Expected ErrNotImplemented for int32 0x80000000, got %v", err)
+	}
+	if _, err := client.ReadScaledUint16(7, 4); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Expected ErrNotImplemented for uint16 0xFFFF, got %v", err)
+	}
+	if _, err := client.ReadScaledUint16(3, 8); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Expected ErrNotImplemented for scale factor 0x8000, got %v", err)
+	}
+	if _, err := client.ReadScaledInt32(0, 8); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Expected ErrNotImplemented for int32 with scale factor 0x8000, got %v", err)
+	}
+}
+
+func TestDiscoverSunSpec(t *testing.T) {
+	dataStore := NewDefaultDataStore(0, 0, 50100, 0)
+	server, _ := NewTCPServer("localhost:15532", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15532")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := DiscoverSunSpec(client, 0); !errors.Is(err, ErrSunSpecNotFound) {
+		t.Fatalf("Expected ErrSunSpecNotFound, got %v", err)
+	}
+
+	// "SunS", common model (1, length 66), meter model (203, length 20), end
+	_ = dataStore.WriteHoldingRegisters(50000, []uint16{0x5375, 0x6E53, 1, 66})
+	_ = dataStore.WriteHoldingRegisters(50070, []uint16{203, 20})
+	_ = dataStore.WriteHoldingRegisters(50092, []uint16{0xFFFF, 0})
+
+	for _, base := range []modbus.Address{0, 50000} {
+		device, err := DiscoverSunSpec(client, base)
+		if err != nil {
+			t.Fatalf("DiscoverSunSpec(%d) failed: %v", base, err)
+		}
+		want := []SunSpecModel{{ID: 1, Address: 50002, Length: 66}, {ID: 203, Address: 50070, Length: 20}}
+		if device.BaseAddress != 50000 || !reflect.DeepEqual(device.Models, want) {
+			t.Errorf("Unexpected device %+v", device)
+		}
+		if m, ok := device.Model(203); !ok || m.Address != 50070 {
+			t.Errorf("Expected meter model at 50070, got %+v", m)
+		}
+	}
+
+	if _, err := DiscoverSunSpec(client, 40000); !errors.Is(err, ErrSunSpecNotFound) {
+		t.Errorf("Expected ErrSunSpecNotFound at 40000, got %v", err)
+	}
+}
+
+func TestPollInputs(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, _ := NewTCPServer("localhost:15534", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	_ = dataStore.SetDiscreteInput(2, true)
+	_ = dataStore.SetInputRegister(5, 230)
+
+	client := NewTCPClient("localhost:15534")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	poll, err := client.PollInputs(0, 4, 5, 2)
+	if err != nil {
+		t.Fatalf("PollInputs failed: %v", err)
+	}
+	if !reflect.DeepEqual(poll.DiscreteInputs, []bool{false, false, true, false}) {
+		t.Errorf("Unexpected discrete inputs %v", poll.DiscreteInputs)
+	}
+	if !reflect.DeepEqual(poll.InputRegisters, []uint16{230, 0}) {
+		t.Errorf("Unexpected input registers %v", poll.InputRegisters)
+	}
+
+	// A failed read does not discard the other
+	poll, err = client.PollInputs(0, 4, 9, 5)
+	if err == nil {
+		t.Error("Expected error for out of range input registers")
+	}
+	if len(poll.DiscreteInputs) != 4 || poll.InputRegisters != nil {
+		t.Errorf("Expected only discrete inputs, got %+v", poll)
+	}
+}
+
+func TestReconnectObserverAndBackoff(t *testing.T) {
+	client := NewTCPClient("localhost:15535")
+	client.SetConnectTimeout(200 * time.Millisecond)
+	client.SetRetryCount(3)
+	client.SetRetryDelay(10 * time.Millisecond)
+	client.SetReconnectBackoff(40 * time.Millisecond)
+	client.SetAutoReconnect(true)
+
+	var mu sync.Mutex
+	var attempts []int
+	var errs []error
+	client.SetReconnectObserver(func(attempt int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, attempt)
+		errs = append(errs, err)
+	})
+
+	// No server is listening, so every reconnect fails
+	if _, err := client.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("Expected read to fail without a server")
+	}
+	mu.Lock()
+	if !reflect.DeepEqual(attempts, []int{1, 2, 3, 4}) || errs[3] == nil {
+		t.Errorf("Expected 4 failed attempts, got %v %v", attempts, errs)
+	}
+	mu.Unlock()
+
+	state := client.GetReconnectState()
+	if state.ConsecutiveFailures != 4 || state.LastError == nil || state.LastAttempt.IsZero() {
+		t.Errorf("Unexpected reconnect state %+v", state)
+	}
+	if state.Backoff != 40*time.Millisecond {
+		t.Errorf("Expected backoff capped at 40ms, got %v", state.Backoff)
+	}
+
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, _ := NewTCPServer("localhost:15535", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+	time.Sleep(100 * time.Millisecond)
+	defer client.Close()
+
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("Expected read to succeed after reconnecting, got %v", err)
+	}
+	mu.Lock()
+	if last := len(attempts) - 1; attempts[last] != 5 || errs[last] != nil {
+		t.Errorf("Expected successful 5th attempt, got %v %v", attempts, errs)
+	}
+	mu.Unlock()
+	if state := client.GetReconnectState(); state.ConsecutiveFailures != 0 || state.LastError != nil || state.Backoff != 0 {
+		t.Errorf("Expected reset reconnect state, got %+v", state)
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	tests := []struct {
+		max      time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{0, 0, 0},
+		{0, 5, base},
+		{time.Second, 1, base},
+		{time.Second, 3, 400 * time.Millisecond},
+		{time.Second, 10, time.Second},
+	}
+	for _, tt := range tests {
+		if got := reconnectBackoff(base, tt.max, tt.failures); got != tt.want {
+			t.Errorf("reconnectBackoff(%v, %v, %d) = %v, want %v", base, tt.max, tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestCoilsNonByteAligned(t *testing.T) {
+	dataStore := NewDefaultDataStore(64, 64, 10, 10)
+	server, _ := NewTCPServer("localhost:15536", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15536")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	for _, quantity := range []int{1, 7, 9, 15, 17} {
+		t.Run(strconv.Itoa(quantity), func(t *testing.T) {
+			// Fill the tables so the bits after the range are set and would
+			// leak into the padding if the packing were off by one
+			all := make([]bool, 64)
+			for i := range all {
+				all[i] = true
+			}
+			if err := dataStore.WriteCoils(0, all); err != nil {
+				t.Fatalf("Failed to seed coils: %v", err)
+			}
+
+			values := make([]bool, quantity)
+			for i := range values {
+				values[i] = i%3 == 0
+			}
+			if err := client.WriteMultipleCoils(8, values); err != nil {
+				t.Fatalf("WriteMultipleCoils failed: %v", err)
+			}
+			got, err := client.ReadCoils(8, modbus.Quantity(quantity))
+			if err != nil {
+				t.Fatalf("ReadCoils failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, values) {
+				t.Errorf("Expected %v, got %v", values, got)
+			}
+
+			// Coils beside the written range are untouched
+			if around, _ := dataStore.ReadCoils(7, 1); !around[0] {
+				t.Error("Coil before the range was changed")
+			}
+			if around, _ := dataStore.ReadCoils(modbus.Address(8+quantity), 1); !around[0] {
+				t.Error("Coil after the range was changed")
+			}
+
+			for i := range values {
+				_ = dataStore.SetDiscreteInput(modbus.Address(i), values[i])
+			}
+			_ = dataStore.SetDiscreteInput(modbus.Address(quantity), true)
+			inputs, err := client.ReadDiscreteInputs(0, modbus.Quantity(quantity))
+			if err != nil {
+				t.Fatalf("ReadDiscreteInputs failed: %v", err)
+			}
+			if !reflect.DeepEqual(inputs, values) {
+				t.Errorf("Expected discrete inputs %v, got %v", values, inputs)
+			}
+		})
+	}
+}
+
+func TestClientPauseResume(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	server, _ := NewTCPServer("localhost:15537", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15537")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	exclusive := client.Pause()
+	if !client.IsPaused() {
+		t.Error("Expected client to be paused")
+	}
+	if _, err := client.ReadHoldingRegisters(0, 1); !errors.Is(err, ErrClientPaused) {
+		t.Errorf("Expected ErrClientPaused, got %v", err)
+	}
+	if err := exclusive.WriteSingleRegister(0, 42); err != nil {
+		t.Errorf("Expected exclusive write to succeed, got %v", err)
+	}
+
+	client.Resume()
+	client.Resume()
+	if client.IsPaused() {
+		t.Error("Expected client to be resumed")
+	}
+	values, err := client.ReadHoldingRegisters(0, 1)
+	if err != nil || values[0] != 42 {
+		t.Errorf("Expected 42 after resume, got %v %v", values, err)
+	}
+}
+
+func TestClientHealthCheck(t *testing.T) {
+	client := NewTCPClient("localhost:15538")
+	client.SetConnectTimeout(200 * time.Millisecond)
+
+	// Not connected and nothing listening
+	status := client.HealthCheck()
+	if status.Healthy() || status.Connected || status.LastError == nil || !status.LastSuccess.IsZero() {
+		t.Errorf("Expected unhealthy status, got %+v", status)
+	}
+
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	server, _ := NewTCPServer("localhost:15538", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	status = client.HealthCheck()
+	if !status.Healthy() || status.LastLatency <= 0 || status.LastSuccess.IsZero() {
+		t.Errorf("Expected healthy status, got %+v", status)
+	}
+
+	// An exception response still shows the device is reachable
+	client.SetHealthProbe(func(c *Client) error {
+		_, err := c.ReadHoldingRegisters(500, 1)
+		return err
+	})
+	before := status.LastSuccess
+	status = client.HealthCheck()
+	if !status.Healthy() || !status.LastSuccess.After(before) {
+		t.Errorf("Expected healthy status after exception response, got %+v", status)
+	}
+
+	// Regular requests update the status too
+	client.SetHealthProbe(nil)
+	_ = client.Close()
+	if _, err := client.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("Expected read on closed client to fail")
+	}
+	if status := client.GetHealthStatus(); status.Connected || status.Healthy() {
+		t.Errorf("Expected disconnected status, got %+v", status)
+	}
+}
+
+func TestReadDateTime(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 20, 10)
+	server, _ := NewTCPServer("localhost:15539", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	want := time.Date(2024, time.February, 29, 13, 45, 7, 0, time.UTC)
+	// Unix time 1709214307, big-endian
+	_ = dataStore.SetHoldingRegister(0, uint16(want.Unix()>>16))
+	_ = dataStore.SetHoldingRegister(1, uint16(want.Unix()))
+	// BCD 24-02-29 13:45:07
+	_ = dataStore.SetHoldingRegister(2, 0x2402)
+	_ = dataStore.SetHoldingRegister(3, 0x2913)
+	_ = dataStore.SetHoldingRegister(4, 0x4507)
+	for i, v := range []uint16{2024, 2, 29, 13, 45, 7} {
+		_ = dataStore.SetHoldingRegister(modbus.Address(5+i), v)
+	}
+	// Invalid BCD digit and an invalid date
+	_ = dataStore.SetHoldingRegister(11, 0x240A)
+	_ = dataStore.SetHoldingRegister(12, 0x2913)
+	_ = dataStore.SetHoldingRegister(13, 0x4507)
+	for i, v := range []uint16{2023, 2, 29, 0, 0, 0} {
+		_ = dataStore.SetHoldingRegister(modbus.Address(14+i), v)
+	}
+
+	client := NewTCPClient("localhost:15539")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if got, err := client.ReadUnixTime32(0); err != nil || !got.Equal(want) {
+		t.Errorf("ReadUnixTime32: expected %v, got %v %v", want, got, err)
+	}
+	if got, err := client.ReadDateTime(2, DateTimeBCD3); err != nil || !got.Equal(want) {
+		t.Errorf("DateTimeBCD3: expected %v, got %v %v", want, got, err)
+	}
+	if got, err := client.ReadDateTime(5, DateTimeRegisters6); err != nil || !got.Equal(want) {
+		t.Errorf("DateTimeRegisters6: expected %v, got %v %v", want, got, err)
+	}
+	if _, err := client.ReadDateTime(11, DateTimeBCD3); err == nil {
+		t.Error("Expected error for invalid BCD digit")
+	}
+	if _, err := client.ReadDateTime(14, DateTimeRegisters6); err == nil {
+		t.Error("Expected error for February 29 in a non-leap year")
+	}
+}
+
+func TestReadWriteSchema(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	server, _ := NewTCPServer("localhost:15542", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15542")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	schema := []LayoutFieldType{LayoutUint16, LayoutFloat32, LayoutUint16, LayoutInt32, LayoutFloat64}
+	want := []interface{}{uint16(7), float32(21.5), uint16(0xBEEF), int32(-42), float64(-1.25)}
+	if err := client.WriteSchema(10, schema, want); err != nil {
+		t.Fatalf("WriteSchema failed: %v", err)
+	}
+
+	// The float is stored in the client's encoding, as WriteFloat32 would
+	if v, err := client.ReadFloat32(11); err != nil || v != 21.5 {
+		t.Errorf("Expected float 21.5 at register 11, got %v %v", v, err)
+	}
+
+	got, err := client.ReadSchema(10, schema)
+	if err != nil {
+		t.Fatalf("ReadSchema failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	got, err = client.ReadSchema(10, []LayoutFieldType{LayoutUint16, LayoutSkip, LayoutSkip, LayoutUint16})
+	if err != nil || !reflect.DeepEqual(got, []interface{}{uint16(7), nil, nil, uint16(0xBEEF)}) {
+		t.Errorf("Unexpected values with skipped registers: %v %v", got, err)
+	}
+
+	if err := client.WriteSchema(10, schema[:1], []interface{}{7}); err == nil {
+		t.Error("Expected error writing int as Uint16")
+	}
+	if err := client.WriteSchema(10, []LayoutFieldType{LayoutSkip}, []interface{}{nil}); err == nil {
+		t.Error("Expected error writing a skipped register")
+	}
+	if _, err := client.ReadSchema(10, nil); err == nil {
+		t.Error("Expected error for empty schema")
+	}
+}
+
+func TestClientSupportedFunctions(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, _ := NewTCPServer("localhost:15543", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15543")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	var requests int
+	client.SetLatencyObserver(func(modbus.SlaveID, modbus.FunctionCode, time.Duration, error) { requests++ })
+	client.SetSupportedFunctions("meter", modbus.FuncCodeReadHoldingRegisters, modbus.FuncCodeWriteSingleRegister)
+
+	if err := client.WriteSingleRegister(0, 1); err != nil {
+		t.Errorf("Expected supported write to succeed, got %v", err)
+	}
+
+	err := client.WriteMultipleRegisters(0, []uint16{1, 2})
+	var unsupported *UnsupportedFunctionError
+	if !errors.As(err, &unsupported) || unsupported.FunctionCode != modbus.FuncCodeWriteMultipleRegisters {
+		t.Fatalf("Expected UnsupportedFunctionError, got %v", err)
+	}
+	if err.Error() != "function 0x10 not supported by profile 'meter'" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+	if err := client.BroadcastWriteSingleCoil(0, true); !errors.As(err, &unsupported) {
+		t.Errorf("Expected UnsupportedFunctionError for broadcast, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected only the supported request to be sent, got %d", requests)
+	}
+
+	client.SetSupportedFunctions("")
+	if err := client.WriteMultipleRegisters(0, []uint16{1, 2}); err != nil {
+		t.Errorf("Expected write after removing restriction to succeed, got %v", err)
+	}
+}
+
+// slaveRecordingHandler records the unit ID of the last request
+type slaveRecordingHandler struct {
+	handler *ServerRequestHandler
+	slaveID atomic.Int32
+}
+
+func (h *slaveRecordingHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	h.slaveID.Store(int32(slaveID))
+	return h.handler.HandleRequest(slaveID, req)
+}
+
+func TestAutoReconnectPreservesSettings(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	handler := &slaveRecordingHandler{handler: NewServerRequestHandler(dataStore)}
+	server := transport.NewTCPServer("localhost:15544", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15544")
+	client.SetRetryCount(1)
+	client.SetAutoReconnect(true)
+	client.SetSlaveID(7)
+	client.SetEncoding(LittleEndian, LowWordFirst)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("Failed to read holding registers: %v", err)
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("Failed to restart server: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := client.WriteUint32(0, 0x11223344); err != nil {
+		t.Fatalf("Expected write after reconnect to succeed, got %v", err)
+	}
+	if state := client.GetReconnectState(); state.LastAttempt.IsZero() {
+		t.Error("Expected the client to have reconnected")
+	}
+	if id := handler.slaveID.Load(); id != 7 {
+		t.Errorf("Expected slave ID 7 after reconnect, got %d", id)
+	}
+
+	// Little endian bytes with the low word first
+	regs, _ := dataStore.ReadHoldingRegisters(0, 2)
+	if regs[0] != 0x4433 || regs[1] != 0x2211 {
+		t.Errorf("Expected registers [0x4433 0x2211], got [0x%04X 0x%04X]", regs[0], regs[1])
+	}
+	if v, err := client.ReadUint32(0); err != nil || v != 0x11223344 {
+		t.Errorf("Expected 0x11223344, got 0x%08X, %v", v, err)
+	}
+}
+
+func TestReadFormatted(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	neg := int16(-215)
+	_ = dataStore.SetHoldingRegister(0, uint16(neg))
+	_ = dataStore.SetHoldingRegister(2, 0x0001) // 70000 as a big endian uint32
+	_ = dataStore.SetHoldingRegister(3, 0x1170)
+	_ = dataStore.SetHoldingRegister(4, 50)
+	server, _ := NewTCPServer("localhost:15547", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15547")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	layout := Layout{}.
+		AddInt16("temp").WithUnit("°C", 0.1, 0).
+		AddSkip(1).
+		AddUint32("energy").WithUnit("kWh", 0.001, 0).
+		AddUint16("level").WithUnit("%", 0.5, 10)
+	got, err := client.ReadFormatted(0, layout)
+	if err != nil {
+		t.Fatalf("ReadFormatted failed: %v", err)
+	}
+
+	want := map[string]FormattedValue{
+		"temp":   {Value: -21.5, Unit: "°C"},
+		"energy": {Value: 70, Unit: "kWh"},
+		"level":  {Value: 35, Unit: "%"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d values, got %v", len(want), got)
+	}
+	for name, w := range want {
+		g := got[name]
+		if math.Abs(g.Value-w.Value) > 1e-9 || g.Unit != w.Unit {
+			t.Errorf("%s: expected %v, got %v", name, w, g)
+		}
+	}
+	if s := got["level"].String(); s != "35 %" {
+		t.Errorf("Expected \"35 %%\", got %q", s)
+	}
+
+	// Fields without metadata are returned unscaled
+	got, err = client.ReadFormatted(4, Layout{}.AddUint16("raw"))
+	if err != nil || got["raw"] != (FormattedValue{Value: 50}) {
+		t.Errorf("Expected raw 50, got %v, %v", got, err)
+	}
+}
+
+func TestWriteString(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	server, _ := NewTCPServer("localhost:15548", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15548")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		name    string
+		value   string
+		length  uint16
+		padding StringPadding
+		want    []byte
+	}{
+		{"exact fit", "ABCD", 4, StringPaddingNull, []byte("ABCD")},
+		{"shorter", "AB", 4, StringPaddingNull, []byte{'A', 'B', 0, 0}},
+		{"space padded", "AB", 4, StringPaddingSpace, []byte("AB  ")},
+		{"odd length", "ABC", 3, StringPaddingSpace, []byte("ABC ")},
+	}
+	for _, tt := range tests {
+		// Fill the field so stale bytes show up
+		if err := client.WriteBytes(0, []byte("xxxxxx")); err != nil {
+			t.Fatalf("WriteBytes failed: %v", err)
+		}
+		if err := client.WriteStringPadded(0, tt.value, tt.length, tt.padding); err != nil {
+			t.Errorf("%s: WriteStringPadded failed: %v", tt.name, err)
+			continue
+		}
+		got, err := client.ReadBytes(0, uint16(len(tt.want)))
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: expected %q, got %q, %v", tt.name, tt.want, got, err)
+		}
+	}
+
+	if s, err := client.ReadString(0, 4); err != nil || s != "ABC " {
+		t.Errorf("Expected \"ABC \", got %q, %v", s, err)
+	}
+
+	// Longer strings are rejected and nothing is written
+	if err := client.WriteString(0, "ABCDE", 4); !errors.Is(err, ErrStringTooLong) {
+		t.Errorf("Expected ErrStringTooLong, got %v", err)
+	}
+	if s, _ := client.ReadString(0, 4); s != "ABC " {
+		t.Errorf("Expected field to be unchanged, got %q", s)
+	}
+}
+
+func TestPlanReads(t *testing.T) {
+	tests := []struct {
+		name        string
+		addresses   []modbus.Address
+		maxGap      int
+		maxQuantity int
+		want        []ReadRange
+	}{
+		{"empty", nil, 5, 125, nil},
+		{"adjacent only", []modbus.Address{3, 1, 2, 5}, 0, 125, []ReadRange{{1, 3}, {5, 1}}},
+		{"gap merged", []modbus.Address{1, 2, 5, 20}, 2, 125, []ReadRange{{1, 5}, {20, 1}}},
+		{"duplicates", []modbus.Address{7, 7, 8}, 0, 125, []ReadRange{{7, 2}}},
+		{"max quantity", []modbus.Address{0, 2, 4, 6}, 10, 5, []ReadRange{{0, 5}, {6, 1}}},
+		{"top of address space", []modbus.Address{65534, 65535}, 0, 125, []ReadRange{{65534, 2}}},
+	}
+	for _, tt := range tests {
+		if got := PlanReads(tt.addresses, tt.maxGap, tt.maxQuantity); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestReadPoints(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	for i := 0; i < 100; i++ {
+		_ = dataStore.SetHoldingRegister(modbus.Address(i), uint16(i*10))
+		_ = dataStore.SetCoil(modbus.Address(i), i%3 == 0)
+	}
+	server, _ := NewTCPServer("localhost:15549", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15549")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	var requests int
+	client.SetLatencyObserver(func(modbus.SlaveID, modbus.FunctionCode, time.Duration, error) { requests++ })
+
+	addresses := []modbus.Address{90, 3, 5, 10, 11}
+	values, err := client.ReadPoints(addresses, 4)
+	if err != nil {
+		t.Fatalf("ReadPoints failed: %v", err)
+	}
+	want := map[modbus.Address]uint16{3: 30, 5: 50, 10: 100, 11: 110, 90: 900}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Expected %v, got %v", want, values)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+
+	coils, err := client.ReadCoilPoints([]modbus.Address{3, 4, 50}, 0)
+	if err != nil {
+		t.Fatalf("ReadCoilPoints failed: %v", err)
+	}
+	if !reflect.DeepEqual(coils, map[modbus.Address]bool{3: true, 4: false, 50: false}) {
+		t.Errorf("Unexpected coils %v", coils)
+	}
+
+	if _, err := client.ReadPoints([]modbus.Address{5, 150}, 0); err == nil {
+		t.Error("Expected error reading past the end of the data store")
+	}
+}
+
+func TestReadAllPointSchema(t *testing.T) {
+	dataStore := NewDefaultDataStore(200, 200, 200, 200)
+	_ = dataStore.SetCoil(0, true)
+	_ = dataStore.SetDiscreteInput(5, true)
+	neg := int16(-215)
+	_ = dataStore.SetInputRegister(10, uint16(neg))
+	_ = dataStore.SetHoldingRegister(100, 0x1170) // 70000 with the low word first
+	_ = dataStore.SetHoldingRegister(101, 0x0001)
+	_ = dataStore.SetHoldingRegister(104, 0x41AC) // 21.5 as a float32
+	_ = dataStore.SetHoldingRegister(105, 0x0000)
+	_ = dataStore.SetHoldingRegister(150, 7)
+	server, _ := NewTCPServer("localhost:15550", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15550")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	path := filepath.Join(t.TempDir(), "points.json")
+	err := os.WriteFile(path, []byte(`{
+		"max_gap": 4,
+		"points": [
+			{"name": "running", "table": "coils", "address": 0},
+			{"name": "alarm", "table": "discrete_inputs", "address": 5, "type": "bool"},
+			{"name": "temp", "table": "input_registers", "address": 10, "type": "int16", "scale": 0.1},
+			{"name": "energy", "table": "holding_registers", "address": 100, "type": "uint32", "word_order": "low"},
+			{"name": "flow", "table": "holding_registers", "address": 104, "type": "Float32"},
+			{"name": "mode", "table": "holding_registers", "address": 150}
+		]
+	}`), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+	schema, err := LoadPointSchema(path)
+	if err != nil {
+		t.Fatalf("LoadPointSchema failed: %v", err)
+	}
+
+	var requests int
+	client.SetLatencyObserver(func(modbus.SlaveID, modbus.FunctionCode, time.Duration, error) { requests++ })
+
+	values, err := client.ReadAll(schema)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	want := LayoutValues{
+		"running": true,
+		"alarm":   true,
+		"temp":    -21.5,
+		"energy":  uint32(70000),
+		"flow":    float32(21.5),
+		"mode":    uint16(7),
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Expected %v, got %v", want, values)
+	}
+	// energy and flow share a read; mode is too far away
+	if requests != 5 {
+		t.Errorf("Expected 5 requests, got %d", requests)
+	}
+
+	// A multi-register point is never split by the read limit
+	requests = 0
+	client.readLimits = map[modbus.FunctionCode]int{modbus.FuncCodeReadHoldingRegisters: 3}
+	values, err = client.ReadAll(&PointSchema{Points: []SchemaPoint{
+		{Name: "a", Table: TableHoldingRegisters, Address: 99},
+		{Name: "energy", Table: TableHoldingRegisters, Address: 100, Type: "uint32", WordOrder: "low"},
+		{Name: "flow", Table: TableHoldingRegisters, Address: 102, Type: "float32"},
+	}})
+	if err != nil || values["energy"] != uint32(70000) || requests != 2 {
+		t.Errorf("Expected energy 70000 in 2 requests, got %v, %d requests, %v", values, requests, err)
+	}
+}
+
+func TestPointSchemaValidate(t *testing.T) {
+	if _, err := ParsePointSchema([]byte(`{"points": [`)); err == nil {
+		t.Error("Expected error for malformed JSON")
+	}
+
+	_, err := ParsePointSchema([]byte(`{"points": [
+		{"name": "a", "table": "coils", "address": 0, "type": "int16"},
+		{"name": "a", "table": "holding_registers", "address": 0},
+		{"name": "b", "table": "registers", "address": 0},
+		{"name": "c", "table": "holding_registers", "address": 0, "type": "float16"},
+		{"name": "d", "table": "input_registers", "address": 65535, "type": "uint32"},
+		{"name": "e", "table": "input_registers", "address": 0, "byte_order": "middle"}
+	]}`))
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, msg := range []string{"not allowed for coils", "duplicate name", "unknown table", "unknown type", "past the end", "unknown byte_order"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected error to mention %q, got %v", msg, err)
+		}
+	}
+
+	if err := (&PointSchema{}).Validate(); err == nil {
+		t.Error("Expected error for empty schema")
+	}
+}
+
+// busyHandler answers the first busy requests with a Server Device Busy exception
+type busyHandler struct {
+	handler *ServerRequestHandler
+	busy    int32
+	calls   int32
+}
+
+func (h *busyHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	if atomic.AddInt32(&h.calls, 1) <= atomic.LoadInt32(&h.busy) {
+		return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeServerDeviceBusy)
+	}
+	return h.handler.HandleRequest(slaveID, req)
+}
+
+func TestRetryClassifier(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	handler := &busyHandler{handler: NewServerRequestHandler(dataStore), busy: 2}
+	server := transport.NewTCPServer("localhost:15551", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15551")
+	client.SetRetryCount(3)
+	client.SetRetryDelay(time.Millisecond)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// By default exceptions are not retried
+	_, err := client.ReadHoldingRegisters(0, 1)
+	var modbusErr *modbus.ModbusError
+	if !errors.As(err, &modbusErr) || modbusErr.ExceptionCode != modbus.ExceptionCodeServerDeviceBusy {
+		t.Fatalf("Expected busy exception, got %v", err)
+	}
+	if calls := atomic.LoadInt32(&handler.calls); calls != 1 {
+		t.Errorf("Expected 1 request, got %d", calls)
+	}
+
+	// A classifier retrying busy devices rides out the remaining busy answer
+	client.SetRetryClassifier(func(err error) bool {
+		var modbusErr *modbus.ModbusError
+		if errors.As(err, &modbusErr) {
+			return modbusErr.ExceptionCode == modbus.ExceptionCodeServerDeviceBusy
+		}
+		return true
+	})
+	var attempts int
+	client.SetRetryObserver(func(_ modbus.SlaveID, _ modbus.FunctionCode, attempt int, _ error) { attempts = attempt })
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("Expected retried read to succeed, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected success on attempt 2, got %d", attempts)
+	}
+
+	// Exceptions still arrive as errors once the retries run out
+	atomic.StoreInt32(&handler.calls, 0)
+	atomic.StoreInt32(&handler.busy, 10)
+	if _, err := client.ReadHoldingRegisters(0, 1); !errors.As(err, &modbusErr) {
+		t.Errorf("Expected busy exception after retries, got %v", err)
+	}
+	if calls := atomic.LoadInt32(&handler.calls); calls != 4 {
+		t.Errorf("Expected 4 requests, got %d", calls)
+	}
+
+	// Transport errors the classifier rejects are not retried
+	client.SetRetryClassifier(func(error) bool { return false })
+	client.SetTimeout(50 * time.Millisecond)
+	server.Stop()
+	_, err = client.ReadHoldingRegisters(0, 1)
+	if err == nil || !strings.Contains(err.Error(), "after 1 attempts") || attempts != 1 {
+		t.Errorf("Expected a single attempt, got %v (observer %d)", err, attempts)
+	}
+}
+
+func TestWriteRegistersFromBytes(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, _ := NewTCPServer("localhost:15552", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15552")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+	client.SetEncoding(LittleEndian, HighWordFirst)
+
+	data := []byte{0x01, 0x02, 0x03}
+	if err := client.WriteRegistersFromBytes(2, data); err != nil {
+		t.Fatalf("WriteRegistersFromBytes failed: %v", err)
+	}
+
+	// Little endian puts the first byte of each pair in the low byte, and the
+	// odd final byte is padded with zero
+	regs, _ := dataStore.ReadHoldingRegisters(2, 2)
+	if regs[0] != 0x0201 || regs[1] != 0x0003 {
+		t.Errorf("Expected registers [0x0201 0x0003], got [0x%04X 0x%04X]", regs[0], regs[1])
+	}
+	got, err := client.ReadBytes(2, uint16(len(data)))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Expected % X, got % X, %v", data, got, err)
+	}
+
+	if err := client.WriteRegistersFromBytes(2, nil); err == nil {
+		t.Error("Expected error for empty data")
+	}
+}
+
+func TestCountSetBits(t *testing.T) {
+	dataStore := NewDefaultDataStore(20, 20, 10, 10)
+	// Bits 10-15 share the last byte of a 10 bit read and must not be counted
+	for _, address := range []modbus.Address{1, 3, 9, 10, 11, 15} {
+		_ = dataStore.SetCoil(address, true)
+		_ = dataStore.SetDiscreteInput(address, true)
+	}
+	_ = dataStore.SetDiscreteInput(0, true)
+
+	server, _ := NewTCPServer("localhost:15555", dataStore)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15555")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if n, err := client.CountSetCoils(0, 10); err != nil || n != 3 {
+		t.Errorf("Expected 3 coils set, got %d, %v", n, err)
+	}
+	if n, err := client.CountSetDiscreteInputs(0, 10); err != nil || n != 4 {
+		t.Errorf("Expected 4 discrete inputs set, got %d, %v", n, err)
 	}
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -147,6 +148,16 @@ type DeviceProfile struct {
 	Notes                 string `json:"notes,omitempty"`
 }
 
+// FunctionCodes returns the profile's supported functions as function codes,
+// for Client.SetSupportedFunctions. An empty list means no restriction.
+func (p *DeviceProfile) FunctionCodes() []modbus.FunctionCode {
+	codes := make([]modbus.FunctionCode, 0, len(p.SupportedFunctions))
+	for _, fc := range p.SupportedFunctions {
+		codes = append(codes, modbus.FunctionCode(fc))
+	}
+	return codes
+}
+
 // Config holds the complete configuration
 type Config struct {
 	Connection     ConnectionConfig         `json:"connection"`
@@ -204,6 +215,106 @@ func (c *Config) ApplyProfile() error {
 	return nil
 }
 
+// testAddressKeys maps each test that reads or writes a table to the
+// test_addresses entry it uses
+var testAddressKeys = map[string]string{
+	"read_holding_registers":        "holding_registers",
+	"write_single_register":         "holding_registers",
+	"write_multiple_registers":      "holding_registers",
+	"read_input_registers":          "input_registers",
+	"read_coils":                    "coils",
+	"write_single_coil":             "coils",
+	"write_multiple_coils":          "coils",
+	"read_discrete_inputs":          "discrete_inputs",
+	"read_write_multiple_registers": "holding_registers",
+}
+
+// logLevels are the accepted logging levels; empty means the default
+var logLevels = map[string]bool{"": true, "debug": true, "info": true, "warn": true, "warning": true, "error": true}
+
+// Validate checks the configuration for values that would fail or misbehave
+// at runtime, such as an out of range port, a negative timeout, a slave ID
+// above 247 or an enabled test without addresses. All problems found are
+// returned together, joined with errors.Join.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	conn := c.Connection
+	check(conn.Address != "", "connection.address is empty")
+	check(conn.Port >= 1 && conn.Port <= 65535, "connection.port %d out of range 1-65535", conn.Port)
+	check(conn.TimeoutMs > 0, "connection.timeout_ms %d must be positive", conn.TimeoutMs)
+	check(conn.ConnectTimeoutMs >= 0, "connection.connect_timeout_ms %d must not be negative", conn.ConnectTimeoutMs)
+	check(conn.RetryCount >= 0, "connection.retry_count %d must not be negative", conn.RetryCount)
+
+	check(c.Modbus.SlaveID >= 0 && c.Modbus.SlaveID <= modbus.MaxSlaveID,
+		"modbus.slave_id %d out of range 0-%d", c.Modbus.SlaveID, modbus.MaxSlaveID)
+	check(c.Modbus.UnitID >= 0 && c.Modbus.UnitID <= 255, "modbus.unit_id %d out of range 0-255", c.Modbus.UnitID)
+	check(c.Modbus.ProtocolID == modbus.MBAPProtocolID, "modbus.protocol_id %d must be %d", c.Modbus.ProtocolID, modbus.MBAPProtocolID)
+
+	for name, r := range c.Testing.TestAddresses {
+		check(r.StartAddress >= 0 && r.StartAddress <= 65535,
+			"testing.test_addresses.%s.start_address %d out of range 0-65535", name, r.StartAddress)
+		check(r.Quantity >= 0 && r.StartAddress+r.Quantity <= 65536,
+			"testing.test_addresses.%s.quantity %d out of range for start address %d", name, r.Quantity, r.StartAddress)
+	}
+	for _, test := range c.Testing.EnabledTests {
+		key, ok := testAddressKeys[test]
+		if !ok {
+			continue
+		}
+		r, exists := c.Testing.TestAddresses[key]
+		check(exists && r.Quantity > 0, "testing.enabled_tests: %s needs testing.test_addresses.%s with a positive quantity", test, key)
+	}
+
+	adv := c.Advanced
+	check(isUint16(adv.MaskWrite.Address), "advanced.mask_write.address %d out of range 0-65535", adv.MaskWrite.Address)
+	check(isUint16(adv.MaskWrite.AndMask), "advanced.mask_write.and_mask %d out of range 0-65535", adv.MaskWrite.AndMask)
+	check(isUint16(adv.MaskWrite.OrMask), "advanced.mask_write.or_mask %d out of range 0-65535", adv.MaskWrite.OrMask)
+	check(isUint16(adv.ReadWriteMultiple.ReadAddress),
+		"advanced.read_write_multiple.read_address %d out of range 0-65535", adv.ReadWriteMultiple.ReadAddress)
+	check(adv.ReadWriteMultiple.ReadQuantity >= 0 && adv.ReadWriteMultiple.ReadQuantity <= modbus.MaxReadWriteRegs,
+		"advanced.read_write_multiple.read_quantity %d out of range 0-%d", adv.ReadWriteMultiple.ReadQuantity, modbus.MaxReadWriteRegs)
+	check(isUint16(adv.ReadWriteMultiple.WriteAddress),
+		"advanced.read_write_multiple.write_address %d out of range 0-65535", adv.ReadWriteMultiple.WriteAddress)
+	check(len(adv.ReadWriteMultiple.WriteValues) <= modbus.MaxWriteReadWriteRegs,
+		"advanced.read_write_multiple.write_values has %d values, max %d", len(adv.ReadWriteMultiple.WriteValues), modbus.MaxWriteReadWriteRegs)
+	check(isUint16(adv.FIFOQueue.Address), "advanced.fifo_queue.address %d out of range 0-65535", adv.FIFOQueue.Address)
+
+	check(logLevels[c.Logging.Level], "logging.level %q is not one of debug, info, warn, error", c.Logging.Level)
+
+	for name, p := range c.DeviceProfiles {
+		check(p.SlaveID >= 0 && p.SlaveID <= modbus.MaxSlaveID,
+			"device_profiles.%s.slave_id %d out of range 0-%d", name, p.SlaveID, modbus.MaxSlaveID)
+		for field, start := range map[string]int{
+			"holding_registers_start": p.HoldingRegistersStart,
+			"input_registers_start":   p.InputRegistersStart,
+			"coils_start":             p.CoilsStart,
+			"discrete_inputs_start":   p.DiscreteInputsStart,
+		} {
+			check(isUint16(start), "device_profiles.%s.%s %d out of range 0-65535", name, field, start)
+		}
+		for _, fc := range p.SupportedFunctions {
+			check(fc >= 1 && fc <= 127, "device_profiles.%s.supported_functions: %d is not a function code", name, fc)
+		}
+	}
+	if len(c.DeviceProfiles) > 0 {
+		_, exists := c.DeviceProfiles[c.CurrentProfile]
+		check(exists, "current_profile %q not found in device_profiles", c.CurrentProfile)
+	}
+
+	return errors.Join(errs...)
+}
+
+// isUint16 reports whether v fits in a register or address
+func isUint16(v int) bool {
+	return v >= 0 && v <= 65535
+}
+
 // LoadConfig loads configuration from a JSON file
 func LoadConfig(configPath string) (*Config, error) {
 	// If no path provided, look for config.json in current directory and parent directories
@@ -226,6 +337,10 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to apply device profile: %w", err)
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", configPath, err)
+	}
+
 	return &config, nil
 }
 
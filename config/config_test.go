@@ -0,0 +1,57 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("Expected default config to be valid, got %v", err)
+	}
+
+	examples, _ := filepath.Glob("../config-examples/*.json")
+	for _, path := range examples {
+		if _, err := LoadConfig(path); err != nil {
+			t.Errorf("Expected %s to load, got %v", path, err)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Connection.Port = 70000
+	cfg.Connection.TimeoutMs = -1
+	cfg.Modbus.SlaveID = 300
+	cfg.Testing.EnabledTests = append(cfg.Testing.EnabledTests, "read_coils")
+	cfg.Advanced.MaskWrite.OrMask = 0x10000
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{
+		"connection.port 70000",
+		"connection.timeout_ms -1",
+		"modbus.slave_id 300",
+		"read_coils needs testing.test_addresses.coils",
+		"advanced.mask_write.or_mask 65536",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error mentioning %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadConfigRejectsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	cfg := DefaultConfig()
+	cfg.Modbus.SlaveID = 300
+	cfg.DeviceProfiles = map[string]DeviceProfile{"generic": {SlaveID: 300}}
+	if err := cfg.SaveConfig(path); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "slave_id 300") {
+		t.Errorf("Expected slave ID error, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package modbus
+
+import (
+	"fmt"
+
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+// ReadConventionalRegisters reads registers using a conventional reference,
+// e.g. 40001 reads holding registers from address 0 and 30001 reads input
+// registers from address 0
+func (c *Client) ReadConventionalRegisters(ref uint32, quantity modbus.Quantity) ([]uint16, error) {
+	fc, address, err := modbus.FromConventional(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	switch fc {
+	case modbus.FuncCodeReadHoldingRegisters:
+		return c.ReadHoldingRegisters(address, quantity)
+	case modbus.FuncCodeReadInputRegisters:
+		return c.ReadInputRegisters(address, quantity)
+	default:
+		return nil, fmt.Errorf("reference %d is not a register reference (3xxxx or 4xxxx)", ref)
+	}
+}
+
+// ReadConventionalBits reads coils or discrete inputs using a conventional
+// reference, e.g. 00001 reads coils from address 0 and 10001 reads discrete
+// inputs from address 0
+func (c *Client) ReadConventionalBits(ref uint32, quantity modbus.Quantity) ([]bool, error) {
+	fc, address, err := modbus.FromConventional(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	switch fc {
+	case modbus.FuncCodeReadCoils:
+		return c.ReadCoils(address, quantity)
+	case modbus.FuncCodeReadDiscreteInputs:
+		return c.ReadDiscreteInputs(address, quantity)
+	default:
+		return nil, fmt.Errorf("reference %d is not a bit reference (0xxxx or 1xxxx)", ref)
+	}
+}
+
+// WriteConventionalRegister writes a holding register using a 4xxxx reference
+func (c *Client) WriteConventionalRegister(ref uint32, value uint16) error {
+	fc, address, err := modbus.FromConventional(ref)
+	if err != nil {
+		return err
+	}
+	if fc != modbus.FuncCodeReadHoldingRegisters {
+		return fmt.Errorf("reference %d is not a writable register reference (4xxxx)", ref)
+	}
+	return c.WriteSingleRegister(address, value)
+}
+
+// WriteConventionalCoil writes a coil using a 0xxxx reference
+func (c *Client) WriteConventionalCoil(ref uint32, value bool) error {
+	fc, address, err := modbus.FromConventional(ref)
+	if err != nil {
+		return err
+	}
+	if fc != modbus.FuncCodeReadCoils {
+		return fmt.Errorf("reference %d is not a writable coil reference (0xxxx)", ref)
+	}
+	return c.WriteSingleCoil(address, value)
+}
@@ -0,0 +1,117 @@
+package modbus
+
+import "fmt"
+
+// dataTable holds the values of one data table. Addresses below len(values)
+// are allocated up front; addresses from there up to size are stored sparsely
+// and read as the zero value until written.
+type dataTable[T comparable] struct {
+	values   []T
+	size     int
+	sparse   map[int]T
+	readOnly [][2]int // [first, last] address ranges rejected by client writes
+}
+
+// newDataTable creates a table with count allocated addresses
+func newDataTable[T comparable](count int) dataTable[T] {
+	return dataTable[T]{values: make([]T, count), size: count}
+}
+
+// setSize extends the logical size of the table. Sizes at or below the
+// allocation are ignored.
+func (t *dataTable[T]) setSize(size int) {
+	if size > len(t.values) {
+		t.size = size
+	}
+}
+
+// inRange reports whether quantity addresses starting at start exist
+func (t *dataTable[T]) inRange(start, quantity int) bool {
+	return start >= 0 && quantity >= 0 && start+quantity <= t.size
+}
+
+// read returns quantity values starting at start, or false if the range
+// exceeds the table
+func (t *dataTable[T]) read(start, quantity int) ([]T, bool) {
+	if !t.inRange(start, quantity) {
+		return nil, false
+	}
+
+	result := make([]T, quantity)
+	for i := range result {
+		address := start + i
+		if address < len(t.values) {
+			result[i] = t.values[address]
+		} else {
+			result[i] = t.sparse[address]
+		}
+	}
+	return result, true
+}
+
+// write stores values starting at start, or returns false if the range
+// exceeds the table
+func (t *dataTable[T]) write(start int, values []T) bool {
+	if !t.inRange(start, len(values)) {
+		return false
+	}
+
+	var zero T
+	for i, v := range values {
+		address := start + i
+		switch {
+		case address < len(t.values):
+			t.values[address] = v
+		case v == zero:
+			delete(t.sparse, address)
+		default:
+			if t.sparse == nil {
+				t.sparse = make(map[int]T)
+			}
+			t.sparse[address] = v
+		}
+	}
+	return true
+}
+
+// setReadOnly marks quantity addresses starting at start read-only, or
+// returns false if the range exceeds the table
+func (t *dataTable[T]) setReadOnly(start, quantity int) bool {
+	if quantity < 1 || !t.inRange(start, quantity) {
+		return false
+	}
+	t.readOnly = append(t.readOnly, [2]int{start, start + quantity - 1})
+	return true
+}
+
+// firstReadOnly returns the lowest read-only address among quantity
+// addresses starting at start
+func (t *dataTable[T]) firstReadOnly(start, quantity int) (int, bool) {
+	end := start + quantity - 1
+	first, found := 0, false
+	for _, r := range t.readOnly {
+		if r[0] > end || r[1] < start {
+			continue
+		}
+		if address := max(r[0], start); !found || address < first {
+			first, found = address, true
+		}
+	}
+	return first, found
+}
+
+// reset sets every address back to the zero value, keeping the size
+func (t *dataTable[T]) reset() {
+	clear(t.values)
+	t.sparse = nil
+}
+
+// rangeMessage describes why quantity addresses starting at start are out of
+// range, distinguishing the allocated store size from a logical address space
+func (t *dataTable[T]) rangeMessage(start, quantity int) string {
+	end := start + quantity - 1
+	if t.size > len(t.values) {
+		return fmt.Sprintf("address range %d-%d beyond logical address space (0-%d)", start, end, t.size-1)
+	}
+	return fmt.Sprintf("address range %d-%d beyond configured store size (0-%d)", start, end, t.size-1)
+}
@@ -0,0 +1,128 @@
+package modbus
+
+import (
+	"log"
+
+	"github.com/adibhanna/modbus-go/modbus"
+	"github.com/adibhanna/modbus-go/transport"
+)
+
+// EchoDataStore implements modbus.DataStore with no stored state, as an
+// instant target for client smoke tests. Reads return a pattern derived from
+// the address: registers hold their own address, and coils and discrete
+// inputs are set at odd addresses. Writes are accepted, logged and discarded.
+type EchoDataStore struct {
+	// Logger receives one line per write; nil discards them
+	Logger transport.Logger
+}
+
+// NewEchoHandler returns a request handler serving an EchoDataStore that logs
+// writes through the standard log package. It works with any server:
+//
+//	server := transport.NewTCPServer(":5020", modbus.NewEchoHandler())
+func NewEchoHandler() *ServerRequestHandler {
+	return NewServerRequestHandler(&EchoDataStore{Logger: log.Default()})
+}
+
+// logf logs a write if a logger is set
+func (ds *EchoDataStore) logf(format string, v ...interface{}) {
+	if ds.Logger != nil {
+		ds.Logger.Printf(format, v...)
+	}
+}
+
+// echoBits returns the bit pattern for quantity bits starting at address
+func echoBits(address modbus.Address, quantity modbus.Quantity) []bool {
+	values := make([]bool, quantity)
+	for i := range values {
+		values[i] = (int(address)+i)%2 == 1
+	}
+	return values
+}
+
+// echoRegisters returns the register pattern for quantity registers starting
+// at address
+func echoRegisters(address modbus.Address, quantity modbus.Quantity) []uint16 {
+	values := make([]uint16, quantity)
+	for i := range values {
+		values[i] = uint16(int(address) + i)
+	}
+	return values
+}
+
+// ReadCoils implements modbus.DataStore
+func (ds *EchoDataStore) ReadCoils(address modbus.Address, quantity modbus.Quantity) ([]bool, error) {
+	return echoBits(address, quantity), nil
+}
+
+// WriteCoils implements modbus.DataStore
+func (ds *EchoDataStore) WriteCoils(address modbus.Address, values []bool) error {
+	ds.logf("echo: write coils at %d: %v", address, values)
+	return nil
+}
+
+// ReadDiscreteInputs implements modbus.DataStore
+func (ds *EchoDataStore) ReadDiscreteInputs(address modbus.Address, quantity modbus.Quantity) ([]bool, error) {
+	return echoBits(address, quantity), nil
+}
+
+// ReadHoldingRegisters implements modbus.DataStore
+func (ds *EchoDataStore) ReadHoldingRegisters(address modbus.Address, quantity modbus.Quantity) ([]uint16, error) {
+	return echoRegisters(address, quantity), nil
+}
+
+// WriteHoldingRegisters implements modbus.DataStore
+func (ds *EchoDataStore) WriteHoldingRegisters(address modbus.Address, values []uint16) error {
+	ds.logf("echo: write holding registers at %d: %v", address, values)
+	return nil
+}
+
+// ReadInputRegisters implements modbus.DataStore
+func (ds *EchoDataStore) ReadInputRegisters(address modbus.Address, quantity modbus.Quantity) ([]uint16, error) {
+	return echoRegisters(address, quantity), nil
+}
+
+// ReadFileRecords implements modbus.DataStore. Each record holds its record
+// number plus the register offset within it.
+func (ds *EchoDataStore) ReadFileRecords(records []modbus.FileRecord) ([]modbus.FileRecord, error) {
+	result := make([]modbus.FileRecord, len(records))
+	for i, record := range records {
+		record.RecordData = echoRegisters(modbus.Address(record.RecordNumber), modbus.Quantity(record.RecordLength))
+		result[i] = record
+	}
+	return result, nil
+}
+
+// WriteFileRecords implements modbus.DataStore
+func (ds *EchoDataStore) WriteFileRecords(records []modbus.FileRecord) error {
+	for _, record := range records {
+		ds.logf("echo: write file %d record %d: %v", record.FileNumber, record.RecordNumber, record.RecordData)
+	}
+	return nil
+}
+
+// ReadFIFOQueue implements modbus.DataStore. The queue holds its own address.
+func (ds *EchoDataStore) ReadFIFOQueue(address modbus.Address) ([]uint16, error) {
+	return []uint16{uint16(address)}, nil
+}
+
+// ReadExceptionStatus implements modbus.DataStore
+func (ds *EchoDataStore) ReadExceptionStatus() (uint8, error) {
+	return 0, nil
+}
+
+// GetDiagnosticData implements modbus.DataStore by echoing the request data
+// for every sub-function
+func (ds *EchoDataStore) GetDiagnosticData(subFunction uint16, data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// GetCommEventCounter implements modbus.DataStore
+func (ds *EchoDataStore) GetCommEventCounter() (uint16, uint16, error) {
+	return 0, 0, nil
+}
+
+// GetCommEventLog implements modbus.DataStore
+func (ds *EchoDataStore) GetCommEventLog() (uint16, uint16, uint16, []byte, error) {
+	return 0xFFFF, 0, 0, nil, nil
+}
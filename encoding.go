@@ -2,10 +2,13 @@ package modbus
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/adibhanna/modbus-go/modbus"
+	"github.com/adibhanna/modbus-go/pdu"
 )
 
 // Endianness represents the byte order for multi-byte values
@@ -44,18 +47,24 @@ func DefaultEncodingConfig() *EncodingConfig {
 
 // SetEncoding configures the byte and word order for multi-byte values
 func (c *Client) SetEncoding(byteOrder Endianness, wordOrder WordOrder) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.encoding = &EncodingConfig{
 		ByteOrder: byteOrder,
 		WordOrder: wordOrder,
 	}
 }
 
-// GetEncoding returns the current encoding configuration
+// GetEncoding returns a copy of the current encoding configuration.
+// Modifying the returned value does not affect the client; use SetEncoding instead.
 func (c *Client) GetEncoding() *EncodingConfig {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	if c.encoding == nil {
-		c.encoding = DefaultEncodingConfig()
+		return DefaultEncodingConfig()
 	}
-	return c.encoding
+	enc := *c.encoding
+	return &enc
 }
 
 // --- Single Value Read Helpers ---
@@ -108,33 +117,132 @@ func (c *Client) ReadInputRegister(address modbus.Address) (uint16, error) {
 	return values[0], nil
 }
 
+// --- Bitmask Helpers ---
+
+// ReadCoilStatus reads up to 16 coils and returns them packed into a bitmask,
+// with the coil at address in bit 0
+func (c *Client) ReadCoilStatus(address modbus.Address, quantity modbus.Quantity) (uint16, error) {
+	if quantity == 0 || quantity > 16 {
+		return 0, fmt.Errorf("quantity must be between 1 and 16, got %d", quantity)
+	}
+	values, err := c.ReadCoils(address, quantity)
+	if err != nil {
+		return 0, err
+	}
+	return packBitmask(values), nil
+}
+
+// ReadInputStatus reads up to 16 discrete inputs and returns them packed into
+// a bitmask, with the input at address in bit 0
+func (c *Client) ReadInputStatus(address modbus.Address, quantity modbus.Quantity) (uint16, error) {
+	if quantity == 0 || quantity > 16 {
+		return 0, fmt.Errorf("quantity must be between 1 and 16, got %d", quantity)
+	}
+	values, err := c.ReadDiscreteInputs(address, quantity)
+	if err != nil {
+		return 0, err
+	}
+	return packBitmask(values), nil
+}
+
+// ReadDiscreteInputsPacked reads discrete inputs and packs them 16 per register.
+// The inputs are packed eight per byte as on the wire (first input in the least
+// significant bit) and the bytes are combined into registers using the client's
+// byte order: with LittleEndian the input at address+16*n+i is bit i of register n,
+// with BigEndian the first eight inputs of each group occupy the high byte.
+func (c *Client) ReadDiscreteInputsPacked(address modbus.Address, quantity modbus.Quantity) ([]uint16, error) {
+	values, err := c.ReadDiscreteInputs(address, quantity)
+	if err != nil {
+		return nil, err
+	}
+	return c.BytesToRegisters(pdu.EncodeBoolSlice(values)), nil
+}
+
+// CountSetCoils reads coils and returns how many of them are on
+func (c *Client) CountSetCoils(address modbus.Address, quantity modbus.Quantity) (int, error) {
+	values, err := c.ReadCoils(address, quantity)
+	if err != nil {
+		return 0, err
+	}
+	return countSet(values), nil
+}
+
+// CountSetDiscreteInputs reads discrete inputs and returns how many of them are on
+func (c *Client) CountSetDiscreteInputs(address modbus.Address, quantity modbus.Quantity) (int, error) {
+	values, err := c.ReadDiscreteInputs(address, quantity)
+	if err != nil {
+		return 0, err
+	}
+	return countSet(values), nil
+}
+
+// countSet returns the number of true values
+func countSet(values []bool) int {
+	count := 0
+	for _, v := range values {
+		if v {
+			count++
+		}
+	}
+	return count
+}
+
+// packBitmask packs up to 16 bits into a uint16, LSB first
+func packBitmask(values []bool) uint16 {
+	var mask uint16
+	for i, v := range values {
+		if i >= 16 {
+			break
+		}
+		if v {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
 // --- 32-bit Integer Operations ---
 
 // ReadUint32 reads a 32-bit unsigned integer from two consecutive holding registers
 func (c *Client) ReadUint32(address modbus.Address) (uint32, error) {
+	return c.ReadUint32Enc(address, nil)
+}
+
+// ReadUint32Enc is like ReadUint32 but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadUint32Enc(address modbus.Address, enc *EncodingConfig) (uint32, error) {
 	values, err := c.ReadHoldingRegisters(address, 2)
 	if err != nil {
 		return 0, err
 	}
-	return c.decodeUint32(values), nil
+	return c.decodeUint32Enc(enc, values), nil
 }
 
 // ReadUint32s reads multiple 32-bit unsigned integers from holding registers
 func (c *Client) ReadUint32s(address modbus.Address, quantity uint16) ([]uint32, error) {
+	return c.ReadUint32sEnc(address, quantity, nil)
+}
+
+// ReadUint32sEnc is like ReadUint32s but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadUint32sEnc(address modbus.Address, quantity uint16, enc *EncodingConfig) ([]uint32, error) {
 	values, err := c.ReadHoldingRegisters(address, modbus.Quantity(quantity*2))
 	if err != nil {
 		return nil, err
 	}
 	result := make([]uint32, quantity)
 	for i := uint16(0); i < quantity; i++ {
-		result[i] = c.decodeUint32(values[i*2 : i*2+2])
+		result[i] = c.decodeUint32Enc(enc, values[i*2:i*2+2])
 	}
 	return result, nil
 }
 
 // ReadInt32 reads a 32-bit signed integer from two consecutive holding registers
 func (c *Client) ReadInt32(address modbus.Address) (int32, error) {
-	val, err := c.ReadUint32(address)
+	return c.ReadInt32Enc(address, nil)
+}
+
+// ReadInt32Enc is like ReadInt32 but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadInt32Enc(address modbus.Address, enc *EncodingConfig) (int32, error) {
+	val, err := c.ReadUint32Enc(address, enc)
 	if err != nil {
 		return 0, err
 	}
@@ -143,7 +251,12 @@ func (c *Client) ReadInt32(address modbus.Address) (int32, error) {
 
 // ReadInt32s reads multiple 32-bit signed integers from holding registers
 func (c *Client) ReadInt32s(address modbus.Address, quantity uint16) ([]int32, error) {
-	values, err := c.ReadUint32s(address, quantity)
+	return c.ReadInt32sEnc(address, quantity, nil)
+}
+
+// ReadInt32sEnc is like ReadInt32s but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadInt32sEnc(address modbus.Address, quantity uint16, enc *EncodingConfig) ([]int32, error) {
+	values, err := c.ReadUint32sEnc(address, quantity, enc)
 	if err != nil {
 		return nil, err
 	}
@@ -187,22 +300,32 @@ func (c *Client) WriteInt32s(address modbus.Address, values []int32) error {
 
 // ReadInputUint32 reads a 32-bit unsigned integer from two consecutive input registers
 func (c *Client) ReadInputUint32(address modbus.Address) (uint32, error) {
+	return c.ReadInputUint32Enc(address, nil)
+}
+
+// ReadInputUint32Enc is like ReadInputUint32 but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadInputUint32Enc(address modbus.Address, enc *EncodingConfig) (uint32, error) {
 	values, err := c.ReadInputRegisters(address, 2)
 	if err != nil {
 		return 0, err
 	}
-	return c.decodeUint32(values), nil
+	return c.decodeUint32Enc(enc, values), nil
 }
 
 // ReadInputUint32s reads multiple 32-bit unsigned integers from input registers
 func (c *Client) ReadInputUint32s(address modbus.Address, quantity uint16) ([]uint32, error) {
+	return c.ReadInputUint32sEnc(address, quantity, nil)
+}
+
+// ReadInputUint32sEnc is like ReadInputUint32s but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadInputUint32sEnc(address modbus.Address, quantity uint16, enc *EncodingConfig) ([]uint32, error) {
 	values, err := c.ReadInputRegisters(address, modbus.Quantity(quantity*2))
 	if err != nil {
 		return nil, err
 	}
 	result := make([]uint32, quantity)
 	for i := uint16(0); i < quantity; i++ {
-		result[i] = c.decodeUint32(values[i*2 : i*2+2])
+		result[i] = c.decodeUint32Enc(enc, values[i*2:i*2+2])
 	}
 	return result, nil
 }
@@ -211,29 +334,44 @@ func (c *Client) ReadInputUint32s(address modbus.Address, quantity uint16) ([]ui
 
 // ReadUint64 reads a 64-bit unsigned integer from four consecutive holding registers
 func (c *Client) ReadUint64(address modbus.Address) (uint64, error) {
+	return c.ReadUint64Enc(address, nil)
+}
+
+// ReadUint64Enc is like ReadUint64 but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadUint64Enc(address modbus.Address, enc *EncodingConfig) (uint64, error) {
 	values, err := c.ReadHoldingRegisters(address, 4)
 	if err != nil {
 		return 0, err
 	}
-	return c.decodeUint64(values), nil
+	return c.decodeUint64Enc(enc, values), nil
 }
 
 // ReadUint64s reads multiple 64-bit unsigned integers from holding registers
 func (c *Client) ReadUint64s(address modbus.Address, quantity uint16) ([]uint64, error) {
+	return c.ReadUint64sEnc(address, quantity, nil)
+}
+
+// ReadUint64sEnc is like ReadUint64s but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadUint64sEnc(address modbus.Address, quantity uint16, enc *EncodingConfig) ([]uint64, error) {
 	values, err := c.ReadHoldingRegisters(address, modbus.Quantity(quantity*4))
 	if err != nil {
 		return nil, err
 	}
 	result := make([]uint64, quantity)
 	for i := uint16(0); i < quantity; i++ {
-		result[i] = c.decodeUint64(values[i*4 : i*4+4])
+		result[i] = c.decodeUint64Enc(enc, values[i*4:i*4+4])
 	}
 	return result, nil
 }
 
 // ReadInt64 reads a 64-bit signed integer from four consecutive holding registers
 func (c *Client) ReadInt64(address modbus.Address) (int64, error) {
-	val, err := c.ReadUint64(address)
+	return c.ReadInt64Enc(address, nil)
+}
+
+// ReadInt64Enc is like ReadInt64 but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadInt64Enc(address modbus.Address, enc *EncodingConfig) (int64, error) {
+	val, err := c.ReadUint64Enc(address, enc)
 	if err != nil {
 		return 0, err
 	}
@@ -242,7 +380,12 @@ func (c *Client) ReadInt64(address modbus.Address) (int64, error) {
 
 // ReadInt64s reads multiple 64-bit signed integers from holding registers
 func (c *Client) ReadInt64s(address modbus.Address, quantity uint16) ([]int64, error) {
-	values, err := c.ReadUint64s(address, quantity)
+	return c.ReadInt64sEnc(address, quantity, nil)
+}
+
+// ReadInt64sEnc is like ReadInt64s but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadInt64sEnc(address modbus.Address, quantity uint16, enc *EncodingConfig) ([]int64, error) {
+	values, err := c.ReadUint64sEnc(address, quantity, enc)
 	if err != nil {
 		return nil, err
 	}
@@ -285,20 +428,156 @@ func (c *Client) WriteInt64s(address modbus.Address, values []int64) error {
 	return c.WriteUint64s(address, uvals)
 }
 
+// --- Scaled Integer Operations ---
+
+// ErrNotImplemented is returned by the scaled reads when the value or the
+// scale factor holds the SunSpec "not implemented" marker: 0x80000000 for an
+// int32, 0xFFFF for a uint16 and 0x8000 for a scale factor
+var ErrNotImplemented = errors.New("value not implemented")
+
+// ReadScaledInt32 reads a 32-bit signed integer at valueAddr and a 16-bit
+// signed scale factor at scaleFactorAddr, both from holding registers, and
+// returns value * 10^scale. This is the layout used by SunSpec and many power
+// meters.
+func (c *Client) ReadScaledInt32(valueAddr, scaleFactorAddr modbus.Address) (float64, error) {
+	value, err := c.ReadInt32(valueAddr)
+	if err != nil {
+		return 0, err
+	}
+	if value == math.MinInt32 {
+		return 0, fmt.Errorf("value at address %d: %w", valueAddr, ErrNotImplemented)
+	}
+	return c.applyScaleFactor(float64(value), scaleFactorAddr)
+}
+
+// ReadScaledUint16 reads a 16-bit unsigned integer at valueAddr and a 16-bit
+// signed scale factor at scaleFactorAddr, both from holding registers, and
+// returns value * 10^scale
+func (c *Client) ReadScaledUint16(valueAddr, scaleFactorAddr modbus.Address) (float64, error) {
+	value, err := c.ReadHoldingRegister(valueAddr)
+	if err != nil {
+		return 0, err
+	}
+	if value == math.MaxUint16 {
+		return 0, fmt.Errorf("value at address %d: %w", valueAddr, ErrNotImplemented)
+	}
+	return c.applyScaleFactor(float64(value), scaleFactorAddr)
+}
+
+// applyScaleFactor reads the scale factor register and scales value by it
+func (c *Client) applyScaleFactor(value float64, scaleFactorAddr modbus.Address) (float64, error) {
+	sf, err := c.ReadHoldingRegister(scaleFactorAddr)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read scale factor: %w", err)
+	}
+	if int16(sf) == math.MinInt16 {
+		return 0, fmt.Errorf("scale factor at address %d: %w", scaleFactorAddr, ErrNotImplemented)
+	}
+	return value * math.Pow10(int(int16(sf))), nil
+}
+
 // --- Float32 Operations ---
 
+// ErrSpecialFloat is returned when a NaN or infinite float value is rejected
+// on write or detected by a checked read
+var ErrSpecialFloat = errors.New("special float value")
+
+// IsSpecialFloat returns true if v is NaN or positive/negative infinity
+func IsSpecialFloat(v float64) bool {
+	return math.IsNaN(v) || math.IsInf(v, 0)
+}
+
+// SetFloatWriteValidation enables or disables rejection of NaN and infinite
+// values by the float write helpers. Disabled by default.
+func (c *Client) SetFloatWriteValidation(enabled bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.floatWriteValidation = enabled
+}
+
+// validateFloatWrite returns an error for special values when validation is enabled
+func (c *Client) validateFloatWrite(address modbus.Address, values ...float64) error {
+	c.mutex.RLock()
+	enabled := c.floatWriteValidation
+	c.mutex.RUnlock()
+	if !enabled {
+		return nil
+	}
+	for i, v := range values {
+		if IsSpecialFloat(v) {
+			return fmt.Errorf("%w: refusing to write %v (value %d) at address %d", ErrSpecialFloat, v, i, address)
+		}
+	}
+	return nil
+}
+
+// ReadFloat32Checked reads a 32-bit float like ReadFloat32, but returns an
+// error wrapping ErrSpecialFloat alongside the value if the device returned NaN or infinity
+func (c *Client) ReadFloat32Checked(address modbus.Address) (float32, error) {
+	v, err := c.ReadFloat32(address)
+	if err != nil {
+		return 0, err
+	}
+	if IsSpecialFloat(float64(v)) {
+		return v, fmt.Errorf("%w: read %v at address %d", ErrSpecialFloat, v, address)
+	}
+	return v, nil
+}
+
 // ReadFloat32 reads a 32-bit float from two consecutive holding registers
 func (c *Client) ReadFloat32(address modbus.Address) (float32, error) {
-	val, err := c.ReadUint32(address)
+	return c.ReadFloat32Enc(address, nil)
+}
+
+// ReadFloat32Enc is like ReadFloat32 but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadFloat32Enc(address modbus.Address, enc *EncodingConfig) (float32, error) {
+	val, err := c.ReadUint32Enc(address, enc)
 	if err != nil {
 		return 0, err
 	}
 	return math.Float32frombits(val), nil
 }
 
+// floatEncodings lists the four byte/word order combinations in the order
+// DetectFloatEncoding tries them, starting with the MODBUS default
+var floatEncodings = []EncodingConfig{
+	{ByteOrder: BigEndian, WordOrder: HighWordFirst},
+	{ByteOrder: BigEndian, WordOrder: LowWordFirst},
+	{ByteOrder: LittleEndian, WordOrder: HighWordFirst},
+	{ByteOrder: LittleEndian, WordOrder: LowWordFirst},
+}
+
+// DetectFloatEncoding reads the holding register pair at address, which must
+// hold knownValue, and returns the first byte/word order combination that
+// decodes it to that value. A relative difference of up to 1e-6 is accepted so
+// values rounded by the device still match. The client's encoding is not
+// changed; pass the result to SetEncoding to apply it.
+func (c *Client) DetectFloatEncoding(address modbus.Address, knownValue float32) (*EncodingConfig, error) {
+	regs, err := c.ReadHoldingRegisters(address, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	want := float64(knownValue)
+	for i := range floatEncodings {
+		enc := floatEncodings[i]
+		got := float64(math.Float32frombits(decodeUint32With(&enc, regs)))
+		if got == want || math.Abs(got-want) <= 1e-6*math.Abs(want) {
+			return &enc, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no encoding decodes registers %04X %04X at address %d to %v", regs[0], regs[1], address, knownValue)
+}
+
 // ReadFloat32s reads multiple 32-bit floats from holding registers
 func (c *Client) ReadFloat32s(address modbus.Address, quantity uint16) ([]float32, error) {
-	values, err := c.ReadUint32s(address, quantity)
+	return c.ReadFloat32sEnc(address, quantity, nil)
+}
+
+// ReadFloat32sEnc is like ReadFloat32s but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadFloat32sEnc(address modbus.Address, quantity uint16, enc *EncodingConfig) ([]float32, error) {
+	values, err := c.ReadUint32sEnc(address, quantity, enc)
 	if err != nil {
 		return nil, err
 	}
@@ -311,21 +590,34 @@ func (c *Client) ReadFloat32s(address modbus.Address, quantity uint16) ([]float3
 
 // WriteFloat32 writes a 32-bit float to two consecutive holding registers
 func (c *Client) WriteFloat32(address modbus.Address, value float32) error {
+	if err := c.validateFloatWrite(address, float64(value)); err != nil {
+		return err
+	}
 	return c.WriteUint32(address, math.Float32bits(value))
 }
 
 // WriteFloat32s writes multiple 32-bit floats to holding registers
 func (c *Client) WriteFloat32s(address modbus.Address, values []float32) error {
 	uvals := make([]uint32, len(values))
+	fvals := make([]float64, len(values))
 	for i, v := range values {
 		uvals[i] = math.Float32bits(v)
+		fvals[i] = float64(v)
+	}
+	if err := c.validateFloatWrite(address, fvals...); err != nil {
+		return err
 	}
 	return c.WriteUint32s(address, uvals)
 }
 
 // ReadInputFloat32 reads a 32-bit float from two consecutive input registers
 func (c *Client) ReadInputFloat32(address modbus.Address) (float32, error) {
-	val, err := c.ReadInputUint32(address)
+	return c.ReadInputFloat32Enc(address, nil)
+}
+
+// ReadInputFloat32Enc is like ReadInputFloat32 but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadInputFloat32Enc(address modbus.Address, enc *EncodingConfig) (float32, error) {
+	val, err := c.ReadInputUint32Enc(address, enc)
 	if err != nil {
 		return 0, err
 	}
@@ -334,7 +626,12 @@ func (c *Client) ReadInputFloat32(address modbus.Address) (float32, error) {
 
 // ReadInputFloat32s reads multiple 32-bit floats from input registers
 func (c *Client) ReadInputFloat32s(address modbus.Address, quantity uint16) ([]float32, error) {
-	values, err := c.ReadInputUint32s(address, quantity)
+	return c.ReadInputFloat32sEnc(address, quantity, nil)
+}
+
+// ReadInputFloat32sEnc is like ReadInputFloat32s but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadInputFloat32sEnc(address modbus.Address, quantity uint16, enc *EncodingConfig) ([]float32, error) {
+	values, err := c.ReadInputUint32sEnc(address, quantity, enc)
 	if err != nil {
 		return nil, err
 	}
@@ -349,7 +646,12 @@ func (c *Client) ReadInputFloat32s(address modbus.Address, quantity uint16) ([]f
 
 // ReadFloat64 reads a 64-bit float from four consecutive holding registers
 func (c *Client) ReadFloat64(address modbus.Address) (float64, error) {
-	val, err := c.ReadUint64(address)
+	return c.ReadFloat64Enc(address, nil)
+}
+
+// ReadFloat64Enc is like ReadFloat64 but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadFloat64Enc(address modbus.Address, enc *EncodingConfig) (float64, error) {
+	val, err := c.ReadUint64Enc(address, enc)
 	if err != nil {
 		return 0, err
 	}
@@ -358,7 +660,12 @@ func (c *Client) ReadFloat64(address modbus.Address) (float64, error) {
 
 // ReadFloat64s reads multiple 64-bit floats from holding registers
 func (c *Client) ReadFloat64s(address modbus.Address, quantity uint16) ([]float64, error) {
-	values, err := c.ReadUint64s(address, quantity)
+	return c.ReadFloat64sEnc(address, quantity, nil)
+}
+
+// ReadFloat64sEnc is like ReadFloat64s but decodes using enc; a nil enc uses the client's encoding
+func (c *Client) ReadFloat64sEnc(address modbus.Address, quantity uint16, enc *EncodingConfig) ([]float64, error) {
+	values, err := c.ReadUint64sEnc(address, quantity, enc)
 	if err != nil {
 		return nil, err
 	}
@@ -369,13 +676,32 @@ func (c *Client) ReadFloat64s(address modbus.Address, quantity uint16) ([]float6
 	return result, nil
 }
 
+// ReadFloat64Checked reads a 64-bit float like ReadFloat64, but returns an
+// error wrapping ErrSpecialFloat alongside the value if the device returned NaN or infinity
+func (c *Client) ReadFloat64Checked(address modbus.Address) (float64, error) {
+	v, err := c.ReadFloat64(address)
+	if err != nil {
+		return 0, err
+	}
+	if IsSpecialFloat(v) {
+		return v, fmt.Errorf("%w: read %v at address %d", ErrSpecialFloat, v, address)
+	}
+	return v, nil
+}
+
 // WriteFloat64 writes a 64-bit float to four consecutive holding registers
 func (c *Client) WriteFloat64(address modbus.Address, value float64) error {
+	if err := c.validateFloatWrite(address, value); err != nil {
+		return err
+	}
 	return c.WriteUint64(address, math.Float64bits(value))
 }
 
 // WriteFloat64s writes multiple 64-bit floats to holding registers
 func (c *Client) WriteFloat64s(address modbus.Address, values []float64) error {
+	if err := c.validateFloatWrite(address, values...); err != nil {
+		return err
+	}
 	uvals := make([]uint64, len(values))
 	for i, v := range values {
 		uvals[i] = math.Float64bits(v)
@@ -446,6 +772,20 @@ func (c *Client) WriteBytes(address modbus.Address, data []byte) error {
 	return c.WriteMultipleRegisters(address, regs)
 }
 
+// WriteRegistersFromBytes converts a packed byte buffer to registers using the
+// client's encoding and writes them starting at address. Odd-length data is
+// padded with a zero byte to fill the final register. It is the write side of
+// RegistersToBytes and writes the same registers WriteBytes would; use it for
+// buffers built with RegistersToBytes or ReadBytes, which round-trip
+// unchanged, and WriteBytes for fixed-width fields. Unlike WriteBytes it
+// rejects empty data.
+func (c *Client) WriteRegistersFromBytes(address modbus.Address, data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("no data to write")
+	}
+	return c.WriteMultipleRegisters(address, c.BytesToRegisters(data))
+}
+
 // ReadInputBytes reads raw bytes from input registers
 func (c *Client) ReadInputBytes(address modbus.Address, byteCount uint16) ([]byte, error) {
 	regCount := (byteCount + 1) / 2
@@ -500,21 +840,171 @@ func (c *Client) ReadString(address modbus.Address, maxLength uint16) (string, e
 	return string(data[:end]), nil
 }
 
-// WriteString writes a string to holding registers
+// ErrStringTooLong is returned when a string does not fit in maxLength bytes
+var ErrStringTooLong = errors.New("string too long")
+
+// StringPadding selects the byte that fills a string field after the value
+type StringPadding int
+
+const (
+	// StringPaddingNull pads with zero bytes, which ReadString stops at
+	StringPaddingNull StringPadding = iota
+	// StringPaddingSpace pads with ASCII spaces, as many devices expect for
+	// fixed-width names and tags
+	StringPaddingSpace
+)
+
+// WriteString writes a string to holding registers, padding it with zero
+// bytes to maxLength. A value longer than maxLength bytes is not written and
+// an error wrapping ErrStringTooLong is returned.
 func (c *Client) WriteString(address modbus.Address, value string, maxLength uint16) error {
-	data := make([]byte, maxLength)
-	copy(data, value)
+	return c.WriteStringPadded(address, value, maxLength, StringPaddingNull)
+}
+
+// WriteStringPadded writes a string like WriteString, padding it with the
+// given padding. An odd maxLength is padded with one more byte to fill the
+// final register.
+func (c *Client) WriteStringPadded(address modbus.Address, value string, maxLength uint16, padding StringPadding) error {
+	if len(value) > int(maxLength) {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrStringTooLong, len(value), maxLength)
+	}
+
+	pad := byte(0)
+	if padding == StringPaddingSpace {
+		pad = ' '
+	}
+
+	data := make([]byte, int(maxLength)+int(maxLength)%2)
+	n := copy(data, value)
+	for i := n; i < len(data); i++ {
+		data[i] = pad
+	}
 	return c.WriteBytes(address, data)
 }
 
+// --- Date/Time Operations ---
+
+// DateTimeLayout selects how ReadDateTime decodes a date and time stored
+// across holding registers
+type DateTimeLayout int
+
+const (
+	// DateTimeUnix32 is a uint32 count of seconds since the Unix epoch in two
+	// registers, in the client's encoding
+	DateTimeUnix32 DateTimeLayout = iota
+	// DateTimeBCD3 is three registers of BCD digits: YYMM, DDhh and mmss,
+	// with the year counted from 2000
+	DateTimeBCD3
+	// DateTimeRegisters6 is six registers holding the year, month, day, hour,
+	// minute and second as plain integers
+	DateTimeRegisters6
+)
+
+// Registers returns the number of registers the layout occupies
+func (l DateTimeLayout) Registers() modbus.Quantity {
+	switch l {
+	case DateTimeUnix32:
+		return 2
+	case DateTimeBCD3:
+		return 3
+	case DateTimeRegisters6:
+		return 6
+	default:
+		return 0
+	}
+}
+
+// ReadUnixTime32 reads a uint32 count of seconds since the Unix epoch from
+// two holding registers and returns it as a UTC time
+func (c *Client) ReadUnixTime32(address modbus.Address) (time.Time, error) {
+	return c.ReadDateTime(address, DateTimeUnix32)
+}
+
+// ReadDateTime reads a date and time stored in holding registers using the
+// given layout. Broken-down layouts carry no time zone and are returned as
+// UTC; for a device keeping local time, rebuild the value with time.Date in
+// the device's location.
+func (c *Client) ReadDateTime(address modbus.Address, layout DateTimeLayout) (time.Time, error) {
+	quantity := layout.Registers()
+	if quantity == 0 {
+		return time.Time{}, fmt.Errorf("unknown date/time layout %d", layout)
+	}
+
+	regs, err := c.ReadHoldingRegisters(address, quantity)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if layout == DateTimeUnix32 {
+		return time.Unix(int64(c.decodeUint32(regs)), 0).UTC(), nil
+	}
+	return decodeDateTime(layout, regs)
+}
+
+// decodeDateTime decodes the broken-down date/time layouts
+func decodeDateTime(layout DateTimeLayout, regs []uint16) (time.Time, error) {
+	var fields [6]int // year, month, day, hour, minute, second
+	switch layout {
+	case DateTimeBCD3:
+		for i, reg := range regs {
+			hi, ok := decodeBCDByte(byte(reg >> 8))
+			if !ok {
+				return time.Time{}, fmt.Errorf("invalid BCD value 0x%04X in register %d", reg, i)
+			}
+			lo, ok := decodeBCDByte(byte(reg))
+			if !ok {
+				return time.Time{}, fmt.Errorf("invalid BCD value 0x%04X in register %d", reg, i)
+			}
+			fields[2*i], fields[2*i+1] = hi, lo
+		}
+		fields[0] += 2000
+	case DateTimeRegisters6:
+		for i, reg := range regs {
+			fields[i] = int(reg)
+		}
+	}
+
+	year, month, day, hour, minute, second := fields[0], fields[1], fields[2], fields[3], fields[4], fields[5]
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, fmt.Errorf("invalid date/time %04d-%02d-%02d %02d:%02d:%02d",
+			year, month, day, hour, minute, second)
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC)
+	if t.Day() != day {
+		return time.Time{}, fmt.Errorf("invalid date %04d-%02d-%02d", year, month, day)
+	}
+	return t, nil
+}
+
+// decodeBCDByte decodes a two-digit BCD byte
+func decodeBCDByte(b byte) (int, bool) {
+	hi, lo := b>>4, b&0x0F
+	if hi > 9 || lo > 9 {
+		return 0, false
+	}
+	return int(hi)*10 + int(lo), true
+}
+
 // --- Internal Encoding/Decoding Helpers ---
 
 func (c *Client) decodeUint32(regs []uint16) uint32 {
+	return decodeUint32With(c.GetEncoding(), regs)
+}
+
+// decodeUint32Enc decodes two registers using enc, or the client's encoding if enc is nil
+func (c *Client) decodeUint32Enc(enc *EncodingConfig, regs []uint16) uint32 {
+	if enc == nil {
+		enc = c.GetEncoding()
+	}
+	return decodeUint32With(enc, regs)
+}
+
+// decodeUint32With decodes two registers using the given encoding
+func decodeUint32With(enc *EncodingConfig, regs []uint16) uint32 {
 	if len(regs) < 2 {
 		return 0
 	}
 
-	enc := c.GetEncoding()
 	var high, low uint16
 
 	if enc.WordOrder == HighWordFirst {
@@ -554,11 +1044,23 @@ func (c *Client) encodeUint32(value uint32) []uint16 {
 }
 
 func (c *Client) decodeUint64(regs []uint16) uint64 {
+	return decodeUint64With(c.GetEncoding(), regs)
+}
+
+// decodeUint64Enc decodes four registers using enc, or the client's encoding if enc is nil
+func (c *Client) decodeUint64Enc(enc *EncodingConfig, regs []uint16) uint64 {
+	if enc == nil {
+		enc = c.GetEncoding()
+	}
+	return decodeUint64With(enc, regs)
+}
+
+// decodeUint64With decodes four registers using the given encoding
+func decodeUint64With(enc *EncodingConfig, regs []uint16) uint64 {
 	if len(regs) < 4 {
 		return 0
 	}
 
-	enc := c.GetEncoding()
 	var words [4]uint16
 
 	if enc.WordOrder == HighWordFirst {
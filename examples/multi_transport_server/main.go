@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	modbus "github.com/adibhanna/modbus-go"
+	"github.com/adibhanna/modbus-go/transport"
+)
+
+func main() {
+	address := flag.String("address", ":5502", "TCP listen address")
+	port := flag.String("port", "/dev/ttyUSB0", "Serial port for RTU")
+	baud := flag.Int("baud", 19200, "Serial baud rate")
+	slaveID := flag.Int("slave", 1, "RTU slave ID")
+	flag.Parse()
+
+	fmt.Println("Starting MODBUS TCP and RTU servers sharing one data store...")
+
+	// One data store and one handler serve both transports. Both are safe for
+	// concurrent use, so a write over TCP is immediately visible over serial
+	// and vice versa.
+	dataStore := modbus.NewDefaultDataStore(1000, 1000, 1000, 1000)
+	for i := 0; i < 20; i++ {
+		if err := dataStore.SetHoldingRegister(modbus.Address(i), uint16(i*100)); err != nil {
+			log.Printf("Warning: failed to set holding register %d: %v", i, err)
+		}
+	}
+
+	handler := modbus.NewServerRequestHandler(dataStore)
+
+	tcpServer := transport.NewTCPServer(*address, handler)
+	if err := tcpServer.Start(); err != nil {
+		log.Fatalf("Failed to start TCP server: %v", err)
+	}
+	fmt.Printf("MODBUS TCP server listening on %s\n", *address)
+
+	serialConfig, err := transport.NewSerialConfig(*port, *baud, 8, 1, "E")
+	if err != nil {
+		log.Fatalf("Invalid serial configuration: %v", err)
+	}
+
+	rtuServer := transport.NewRTUServer(serialConfig, modbus.SlaveID(*slaveID), handler)
+	if err := rtuServer.Start(); err != nil {
+		log.Fatalf("Failed to start RTU server: %v", err)
+	}
+	fmt.Printf("MODBUS RTU server on %s at %d baud, slave ID %d\n", *port, *baud, *slaveID)
+
+	fmt.Println("Press Ctrl+C to stop the servers")
+
+	// Wait for interrupt signal
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+
+	fmt.Println("\nShutting down servers...")
+	if err := rtuServer.Stop(); err != nil {
+		log.Printf("Error stopping RTU server: %v", err)
+	}
+	if err := tcpServer.Stop(); err != nil {
+		log.Printf("Error stopping TCP server: %v", err)
+	}
+
+	fmt.Println("Servers stopped")
+}
@@ -31,6 +31,9 @@ func main() {
 	// Create a new TCP client using configuration
 	client := modbus.NewTCPClient(cfg.Connection.GetFullAddress())
 	client.SetSlaveID(cfg.Modbus.GetSlaveID())
+	if profile, err := cfg.GetCurrentProfile(); err == nil {
+		client.SetSupportedFunctions(cfg.CurrentProfile, profile.FunctionCodes()...)
+	}
 	client.SetTimeout(cfg.Connection.GetTimeout())
 	client.SetRetryCount(cfg.Connection.RetryCount)
 
@@ -194,7 +197,8 @@ func main() {
 			fmt.Printf("  Vendor Name: %s\n", deviceID.VendorName)
 			fmt.Printf("  Product Code: %s\n", deviceID.ProductCode)
 			fmt.Printf("  Major/Minor Revision: %s\n", deviceID.MajorMinorRevision)
-			fmt.Printf("  Conformity Level: 0x%02X\n", deviceID.ConformityLevel)
+			fmt.Printf("  Conformity Level: 0x%02X (%s)\n", deviceID.ConformityLevel,
+				modbuslib.ConformityLevelString(deviceID.ConformityLevel))
 			fmt.Printf("  More Follows: %t, Next Object ID: %d\n", moreFollows, nextObjectID)
 		}
 	}
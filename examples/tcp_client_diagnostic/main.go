@@ -41,6 +41,9 @@ func main() {
 	fmt.Println("--- Step 2: Testing MODBUS Client Connection ---")
 	client := modbus.NewTCPClient(cfg.Connection.GetFullAddress())
 	client.SetSlaveID(cfg.Modbus.GetSlaveID())
+	if profile, err := cfg.GetCurrentProfile(); err == nil {
+		client.SetSupportedFunctions(cfg.CurrentProfile, profile.FunctionCodes()...)
+	}
 	client.SetTimeout(cfg.Connection.GetTimeout())
 	client.SetRetryCount(1) // Reduce retries for faster diagnosis
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -54,9 +55,14 @@ func main() {
 		log.Fatalf("Failed to create server: %v", err)
 	}
 
-	if err := server.Start(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
+	// Serve until interrupted; ListenAndServe then stops the server gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe(ctx)
+	}()
 
 	fmt.Println("MODBUS TCP Server started on port 5502")
 	fmt.Println("Test data initialized:")
@@ -94,14 +100,9 @@ func main() {
 		}
 	}()
 
-	// Wait for interrupt signal
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	<-c
-
-	fmt.Println("\nShutting down server...")
-	if err := server.Stop(); err != nil {
-		log.Printf("Error stopping server: %v", err)
+	// Wait for the server to shut down after an interrupt signal
+	if err := <-errCh; err != nil {
+		log.Fatalf("Server error: %v", err)
 	}
 
 	fmt.Println("Server stopped")
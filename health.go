@@ -0,0 +1,100 @@
+package modbus
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+// HealthStatus reports the client's view of the device, for liveness probes
+type HealthStatus struct {
+	// Connected is true if the transport is connected
+	Connected bool
+	// LastError is the error of the most recent request, nil if it succeeded
+	LastError error
+	// LastLatency is the round-trip time of the most recent request
+	LastLatency time.Duration
+	// LastSuccess is when the device last answered a request, zero if never
+	LastSuccess time.Time
+}
+
+// Healthy returns true if the client is connected and the most recent
+// request succeeded
+func (s HealthStatus) Healthy() bool {
+	return s.Connected && s.LastError == nil
+}
+
+// HealthProbe is the request made by HealthCheck
+type HealthProbe func(client *Client) error
+
+// healthState tracks the outcome of requests for HealthCheck
+type healthState struct {
+	lastErr     error
+	lastLatency time.Duration
+	lastSuccess time.Time
+	mutex       sync.Mutex
+}
+
+// record stores the outcome of a request attempt started at start
+func (h *healthState) record(start time.Time, latency time.Duration, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lastErr = err
+	h.lastLatency = latency
+	if err == nil {
+		h.lastSuccess = start.Add(latency)
+	}
+}
+
+// fail stores an error that kept a request from reaching the device
+func (h *healthState) fail(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lastErr = err
+}
+
+// SetHealthProbe sets the request made by HealthCheck. The default probe
+// reads holding register 0.
+func (c *Client) SetHealthProbe(probe HealthProbe) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.healthProbe = probe
+}
+
+// HealthCheck makes the health probe request and returns the resulting
+// status. Every request through the client, and its clones, updates the
+// status, so GetHealthStatus reflects regular polling without extra traffic.
+// An exception response counts as success: the device is reachable even if
+// it rejects the probed address.
+func (c *Client) HealthCheck() HealthStatus {
+	c.mutex.RLock()
+	probe := c.healthProbe
+	c.mutex.RUnlock()
+
+	if probe == nil {
+		probe = func(client *Client) error {
+			_, err := client.ReadHoldingRegisters(0, 1)
+			return err
+		}
+	}
+
+	var modbusErr *modbus.ModbusError
+	if err := probe(c); err != nil && !errors.As(err, &modbusErr) {
+		c.health.fail(err)
+	}
+	return c.GetHealthStatus()
+}
+
+// GetHealthStatus returns the status from the requests made so far
+func (c *Client) GetHealthStatus() HealthStatus {
+	c.health.mutex.Lock()
+	defer c.health.mutex.Unlock()
+	return HealthStatus{
+		Connected:   c.transport.IsConnected(),
+		LastError:   c.health.lastErr,
+		LastLatency: c.health.lastLatency,
+		LastSuccess: c.health.lastSuccess,
+	}
+}
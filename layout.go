@@ -0,0 +1,456 @@
+package modbus
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+// LayoutFieldType identifies how a layout field is decoded
+type LayoutFieldType int
+
+const (
+	LayoutUint16 LayoutFieldType = iota
+	LayoutInt16
+	LayoutUint32
+	LayoutInt32
+	LayoutFloat32
+	LayoutUint64
+	LayoutInt64
+	LayoutFloat64
+	// LayoutSkip marks registers that are read but not decoded
+	LayoutSkip
+)
+
+// String returns a string representation
+func (t LayoutFieldType) String() string {
+	switch t {
+	case LayoutUint16:
+		return "Uint16"
+	case LayoutInt16:
+		return "Int16"
+	case LayoutUint32:
+		return "Uint32"
+	case LayoutInt32:
+		return "Int32"
+	case LayoutFloat32:
+		return "Float32"
+	case LayoutUint64:
+		return "Uint64"
+	case LayoutInt64:
+		return "Int64"
+	case LayoutFloat64:
+		return "Float64"
+	case LayoutSkip:
+		return "Skip"
+	default:
+		return fmt.Sprintf("LayoutFieldType(%d)", int(t))
+	}
+}
+
+// LayoutField is a named value within a register layout. Unit, Scale and
+// Offset are display metadata used by ReadFormatted; a zero Scale means 1.
+type LayoutField struct {
+	Name      string
+	Type      LayoutFieldType
+	Registers int
+	Unit      string
+	Scale     float64
+	Offset    float64
+}
+
+// Layout describes a contiguous block of holding registers as a sequence of
+// typed fields. Layouts are values; each Add method returns a new layout, so
+// one can be built in a single expression:
+//
+//	layout := Layout{}.AddFloat32("temp").AddUint16("status").AddInt32("count")
+type Layout struct {
+	fields []LayoutField
+	err    error // first invalid Add, returned by ReadLayout and DecodeLayout
+}
+
+// add returns a copy of l with a field appended
+func (l Layout) add(name string, fieldType LayoutFieldType, registers int) Layout {
+	fields := make([]LayoutField, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
+	return Layout{fields: append(fields, LayoutField{Name: name, Type: fieldType, Registers: registers}), err: l.err}
+}
+
+// AddUint16 appends a uint16 field (1 register)
+func (l Layout) AddUint16(name string) Layout { return l.add(name, LayoutUint16, 1) }
+
+// AddInt16 appends an int16 field (1 register)
+func (l Layout) AddInt16(name string) Layout { return l.add(name, LayoutInt16, 1) }
+
+// AddUint32 appends a uint32 field (2 registers)
+func (l Layout) AddUint32(name string) Layout { return l.add(name, LayoutUint32, 2) }
+
+// AddInt32 appends an int32 field (2 registers)
+func (l Layout) AddInt32(name string) Layout { return l.add(name, LayoutInt32, 2) }
+
+// AddFloat32 appends a float32 field (2 registers)
+func (l Layout) AddFloat32(name string) Layout { return l.add(name, LayoutFloat32, 2) }
+
+// AddUint64 appends a uint64 field (4 registers)
+func (l Layout) AddUint64(name string) Layout { return l.add(name, LayoutUint64, 4) }
+
+// AddInt64 appends an int64 field (4 registers)
+func (l Layout) AddInt64(name string) Layout { return l.add(name, LayoutInt64, 4) }
+
+// AddFloat64 appends a float64 field (4 registers)
+func (l Layout) AddFloat64(name string) Layout { return l.add(name, LayoutFloat64, 4) }
+
+// AddSkip appends registers that are read but not decoded, such as reserved
+// gaps in a device's register map. A count below 1 makes the layout invalid,
+// and ReadLayout and DecodeLayout return an error for it.
+func (l Layout) AddSkip(registers int) Layout {
+	if registers < 1 {
+		if l.err == nil {
+			l.err = fmt.Errorf("skip of %d registers after field %d: must be at least 1", registers, len(l.fields))
+		}
+		return l
+	}
+	return l.add("", LayoutSkip, registers)
+}
+
+// WithUnit sets the unit, scale and offset of the most recently added field,
+// for display with ReadFormatted. The engineering value is raw*scale+offset:
+//
+//	layout := Layout{}.AddInt16("temp").WithUnit("°C", 0.1, 0).AddUint32("energy").WithUnit("kWh", 1, 0)
+//
+// It has no effect on an empty layout.
+func (l Layout) WithUnit(unit string, scale, offset float64) Layout {
+	if len(l.fields) == 0 {
+		return l
+	}
+	fields := make([]LayoutField, len(l.fields))
+	copy(fields, l.fields)
+	last := &fields[len(fields)-1]
+	last.Unit, last.Scale, last.Offset = unit, scale, offset
+	return Layout{fields: fields, err: l.err}
+}
+
+// Fields returns a copy of the layout's fields in order
+func (l Layout) Fields() []LayoutField {
+	fields := make([]LayoutField, len(l.fields))
+	copy(fields, l.fields)
+	return fields
+}
+
+// Registers returns the total number of registers the layout spans
+func (l Layout) Registers() int {
+	total := 0
+	for _, f := range l.fields {
+		total += f.Registers
+	}
+	return total
+}
+
+// LayoutValues holds the decoded values of a layout, keyed by field name
+type LayoutValues map[string]interface{}
+
+// Bool returns the named bool value, as read by ReadAll for coils and
+// discrete inputs, and whether it was present
+func (v LayoutValues) Bool(name string) (bool, bool) {
+	val, ok := v[name].(bool)
+	return val, ok
+}
+
+// Uint16 returns the named uint16 value and whether it was present
+func (v LayoutValues) Uint16(name string) (uint16, bool) {
+	val, ok := v[name].(uint16)
+	return val, ok
+}
+
+// Int16 returns the named int16 value and whether it was present
+func (v LayoutValues) Int16(name string) (int16, bool) {
+	val, ok := v[name].(int16)
+	return val, ok
+}
+
+// Uint32 returns the named uint32 value and whether it was present
+func (v LayoutValues) Uint32(name string) (uint32, bool) {
+	val, ok := v[name].(uint32)
+	return val, ok
+}
+
+// Int32 returns the named int32 value and whether it was present
+func (v LayoutValues) Int32(name string) (int32, bool) {
+	val, ok := v[name].(int32)
+	return val, ok
+}
+
+// Float32 returns the named float32 value and whether it was present
+func (v LayoutValues) Float32(name string) (float32, bool) {
+	val, ok := v[name].(float32)
+	return val, ok
+}
+
+// Uint64 returns the named uint64 value and whether it was present
+func (v LayoutValues) Uint64(name string) (uint64, bool) {
+	val, ok := v[name].(uint64)
+	return val, ok
+}
+
+// Int64 returns the named int64 value and whether it was present
+func (v LayoutValues) Int64(name string) (int64, bool) {
+	val, ok := v[name].(int64)
+	return val, ok
+}
+
+// Float64 returns the named float64 value and whether it was present
+func (v LayoutValues) Float64(name string) (float64, bool) {
+	val, ok := v[name].(float64)
+	return val, ok
+}
+
+// ReadLayout reads the holding registers spanned by layout in a single request
+// starting at address and decodes each field using the client's encoding
+func (c *Client) ReadLayout(address modbus.Address, layout Layout) (LayoutValues, error) {
+	if layout.err != nil {
+		return nil, fmt.Errorf("invalid layout: %w", layout.err)
+	}
+	count := layout.Registers()
+	if count == 0 {
+		return nil, fmt.Errorf("layout is empty")
+	}
+	if count > modbus.MaxReadHoldingRegs {
+		return nil, fmt.Errorf("layout spans %d registers, max %d per read", count, modbus.MaxReadHoldingRegs)
+	}
+
+	regs, err := c.ReadHoldingRegisters(address, modbus.Quantity(count))
+	if err != nil {
+		return nil, err
+	}
+
+	return c.DecodeLayout(layout, regs)
+}
+
+// FormattedValue is a layout field converted to its engineering value
+type FormattedValue struct {
+	Value float64
+	Unit  string
+}
+
+// String returns the value followed by its unit, if any
+func (v FormattedValue) String() string {
+	if v.Unit == "" {
+		return strconv.FormatFloat(v.Value, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(v.Value, 'g', -1, 64) + " " + v.Unit
+}
+
+// ReadFormatted reads layout like ReadLayout and converts each field to its
+// engineering value using the unit, scale and offset set with WithUnit.
+// Fields are keyed by name; skipped registers are left out.
+func (c *Client) ReadFormatted(address modbus.Address, layout Layout) (map[string]FormattedValue, error) {
+	values, err := c.ReadLayout(address, layout)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted := make(map[string]FormattedValue, len(values))
+	for _, f := range layout.fields {
+		if f.Type == LayoutSkip {
+			continue
+		}
+		scale := f.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		formatted[f.Name] = FormattedValue{
+			Value: layoutFloat(values[f.Name])*scale + f.Offset,
+			Unit:  f.Unit,
+		}
+	}
+	return formatted, nil
+}
+
+// layoutFloat converts a decoded layout value to float64
+func layoutFloat(v interface{}) float64 {
+	switch val := v.(type) {
+	case uint16:
+		return float64(val)
+	case int16:
+		return float64(val)
+	case uint32:
+		return float64(val)
+	case int32:
+		return float64(val)
+	case float32:
+		return float64(val)
+	case uint64:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case float64:
+		return val
+	default:
+		return 0
+	}
+}
+
+// DecodeLayout decodes registers previously read for layout using the
+// client's encoding
+func (c *Client) DecodeLayout(layout Layout, regs []uint16) (LayoutValues, error) {
+	if layout.err != nil {
+		return nil, fmt.Errorf("invalid layout: %w", layout.err)
+	}
+	if len(regs) < layout.Registers() {
+		return nil, fmt.Errorf("layout needs %d registers, got %d", layout.Registers(), len(regs))
+	}
+
+	values := make(LayoutValues, len(layout.fields))
+	offset := 0
+	for _, f := range layout.fields {
+		r := regs[offset : offset+f.Registers]
+		offset += f.Registers
+
+		if f.Type != LayoutSkip {
+			values[f.Name] = c.decodeLayoutField(f.Type, r)
+		}
+	}
+
+	return values, nil
+}
+
+// decodeLayoutField decodes the registers of one field of type t
+func (c *Client) decodeLayoutField(t LayoutFieldType, r []uint16) interface{} {
+	switch t {
+	case LayoutUint16:
+		return r[0]
+	case LayoutInt16:
+		return int16(r[0])
+	case LayoutUint32:
+		return c.decodeUint32(r)
+	case LayoutInt32:
+		return int32(c.decodeUint32(r))
+	case LayoutFloat32:
+		return math.Float32frombits(c.decodeUint32(r))
+	case LayoutUint64:
+		return c.decodeUint64(r)
+	case LayoutInt64:
+		return int64(c.decodeUint64(r))
+	case LayoutFloat64:
+		return math.Float64frombits(c.decodeUint64(r))
+	default:
+		return nil
+	}
+}
+
+// Registers returns the number of registers a field of type t spans.
+// LayoutSkip counts as one register.
+func (t LayoutFieldType) Registers() int {
+	switch t {
+	case LayoutUint32, LayoutInt32, LayoutFloat32:
+		return 2
+	case LayoutUint64, LayoutInt64, LayoutFloat64:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// schemaRegisters returns the number of registers spanned by schema
+func schemaRegisters(schema []LayoutFieldType) int {
+	total := 0
+	for _, t := range schema {
+		total += t.Registers()
+	}
+	return total
+}
+
+// ReadSchema reads the holding registers spanned by schema in a single
+// request starting at address and returns the decoded values in order, using
+// the client's encoding. It is a positional form of ReadLayout for quick use:
+//
+//	values, err := client.ReadSchema(100, []LayoutFieldType{LayoutUint16, LayoutFloat32, LayoutInt32})
+//	temp := values[1].(float32)
+//
+// Each LayoutSkip skips one register and leaves nil at its position.
+func (c *Client) ReadSchema(address modbus.Address, schema []LayoutFieldType) ([]interface{}, error) {
+	count := schemaRegisters(schema)
+	if count == 0 {
+		return nil, fmt.Errorf("schema is empty")
+	}
+	if count > modbus.MaxReadHoldingRegs {
+		return nil, fmt.Errorf("schema spans %d registers, max %d per read", count, modbus.MaxReadHoldingRegs)
+	}
+
+	regs, err := c.ReadHoldingRegisters(address, modbus.Quantity(count))
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(schema))
+	offset := 0
+	for i, t := range schema {
+		n := t.Registers()
+		values[i] = c.decodeLayoutField(t, regs[offset:offset+n])
+		offset += n
+	}
+	return values, nil
+}
+
+// WriteSchema encodes values by schema using the client's encoding and writes
+// them to holding registers starting at address in a single request. Each
+// value must have the Go type ReadSchema returns for its field type, e.g.
+// float32 for LayoutFloat32. LayoutSkip is not allowed, since the registers
+// it covers would be overwritten.
+func (c *Client) WriteSchema(address modbus.Address, schema []LayoutFieldType, values []interface{}) error {
+	if len(values) != len(schema) {
+		return fmt.Errorf("schema has %d fields, got %d values", len(schema), len(values))
+	}
+	count := schemaRegisters(schema)
+	if count == 0 {
+		return fmt.Errorf("schema is empty")
+	}
+	if count > modbus.MaxWriteMultipleRegs {
+		return fmt.Errorf("schema spans %d registers, max %d per write", count, modbus.MaxWriteMultipleRegs)
+	}
+
+	regs := make([]uint16, 0, count)
+	for i, t := range schema {
+		encoded, ok := c.encodeLayoutField(t, values[i])
+		if !ok {
+			return fmt.Errorf("field %d: cannot encode %T as %s", i, values[i], t)
+		}
+		regs = append(regs, encoded...)
+	}
+	return c.WriteMultipleRegisters(address, regs)
+}
+
+// encodeLayoutField encodes v as a field of type t, reporting false if v does
+// not have the matching Go type
+func (c *Client) encodeLayoutField(t LayoutFieldType, v interface{}) ([]uint16, bool) {
+	switch t {
+	case LayoutUint16:
+		val, ok := v.(uint16)
+		return []uint16{val}, ok
+	case LayoutInt16:
+		val, ok := v.(int16)
+		return []uint16{uint16(val)}, ok
+	case LayoutUint32:
+		val, ok := v.(uint32)
+		return c.encodeUint32(val), ok
+	case LayoutInt32:
+		val, ok := v.(int32)
+		return c.encodeUint32(uint32(val)), ok
+	case LayoutFloat32:
+		val, ok := v.(float32)
+		return c.encodeUint32(math.Float32bits(val)), ok
+	case LayoutUint64:
+		val, ok := v.(uint64)
+		return c.encodeUint64(val), ok
+	case LayoutInt64:
+		val, ok := v.(int64)
+		return c.encodeUint64(uint64(val)), ok
+	case LayoutFloat64:
+		val, ok := v.(float64)
+		return c.encodeUint64(math.Float64bits(val)), ok
+	default:
+		return nil, false
+	}
+}
@@ -42,6 +42,7 @@ const (
 	ExceptionCodeServerDeviceFailure = 0x04
 	ExceptionCodeAcknowledge         = 0x05
 	ExceptionCodeServerDeviceBusy    = 0x06
+	ExceptionCodeNegativeAcknowledge = 0x07
 	ExceptionCodeMemoryParityError   = 0x08
 	ExceptionCodeGatewayPathUnavail  = 0x0A
 	ExceptionCodeGatewayTargetFail   = 0x0B
@@ -88,6 +89,9 @@ const (
 	MaxTCPADUSize    = 260 // Maximum TCP ADU size (PDU + MBAP)
 	MaxSerialADUSize = 256 // Maximum Serial ADU size (PDU + Address + CRC)
 
+	// Maximum ASCII frame size: ':' + hex(Address + PDU + LRC) + CRLF
+	MaxASCIIFrameSize = 1 + 2*(MaxSerialADUSize-1) + 2
+
 	// Quantity limits per function code
 	MaxReadCoils            = 2000
 	MaxReadDiscreteInputs   = 2000
@@ -99,6 +103,7 @@ const (
 	MaxWriteReadWriteRegs   = 121  // Write quantity
 	MaxReadFileRecordBytes  = 245  // 0xF5
 	MaxWriteFileRecordBytes = 251  // 0xFB
+	MaxFileRecordNumber     = 9999 // 0x270F
 	MaxFIFOCount            = 31
 )
 
@@ -107,6 +112,7 @@ const (
 	MBAPHeaderSize = 7
 	MBAPProtocolID = 0x0000
 	TCPDefaultPort = 502
+	TCPSecurePort  = 802 // MODBUS/TCP Security (TLS)
 )
 
 // Diagnostic Sub-function codes
@@ -128,6 +134,17 @@ const (
 	DiagSubClearOverrunCounter       = 0x0014
 )
 
+// Diagnostic register bits maintained by the server. The register contents
+// are device-specific; these bits mirror the error counters.
+const (
+	DiagRegCommError   uint16 = 1 << 0 // A bus communication error was detected
+	DiagRegException   uint16 = 1 << 1 // An exception response was returned
+	DiagRegNoResponse  uint16 = 1 << 2 // A request went unanswered
+	DiagRegNAK         uint16 = 1 << 3 // A negative acknowledge was returned
+	DiagRegBusy        uint16 = 1 << 4 // A request was rejected as busy
+	DiagRegCharOverrun uint16 = 1 << 5 // A character overrun occurred
+)
+
 // Common coil values
 const (
 	CoilOn  = 0xFF00
@@ -148,10 +165,19 @@ const (
 // Broadcast address (no response expected)
 const (
 	BroadcastAddress = 0x00
+
+	// MaxSlaveID is the highest individual serial line address; 248-255 are
+	// reserved
+	MaxSlaveID = 247
 )
 
 // Timeout defaults (in milliseconds)
 const (
 	DefaultResponseTimeout = 1000
 	DefaultConnectTimeout  = 5000
+	DefaultWriteTimeout    = 500
+
+	// DefaultTurnaroundDelay is the pause after a serial broadcast that gives
+	// every device time to process it before the next request
+	DefaultTurnaroundDelay = 100
 )
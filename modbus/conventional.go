@@ -0,0 +1,72 @@
+package modbus
+
+import "fmt"
+
+// Conventional reference table prefixes (0xxxx, 1xxxx, 3xxxx, 4xxxx)
+const (
+	ConventionalCoil            = 0
+	ConventionalDiscreteInput   = 1
+	ConventionalInputRegister   = 3
+	ConventionalHoldingRegister = 4
+)
+
+// FromConventional converts a conventional 1-based reference such as 40001 or
+// 300010 into the read function code for its table and the zero-based protocol
+// address. Five-digit references (e.g. 40001-49999) and six-digit references
+// (e.g. 400001-465536) are both accepted. Coil references are 1-9999.
+func FromConventional(ref uint32) (FunctionCode, Address, error) {
+	var table, offset uint32
+	if ref >= 100000 {
+		table, offset = ref/100000, ref%100000
+	} else {
+		table, offset = ref/10000, ref%10000
+	}
+
+	if offset < 1 || offset > MaxAddress+1 {
+		return 0, 0, fmt.Errorf("invalid conventional reference %d: offset %d out of range", ref, offset)
+	}
+
+	var fc FunctionCode
+	switch table {
+	case ConventionalCoil:
+		fc = FuncCodeReadCoils
+	case ConventionalDiscreteInput:
+		fc = FuncCodeReadDiscreteInputs
+	case ConventionalInputRegister:
+		fc = FuncCodeReadInputRegisters
+	case ConventionalHoldingRegister:
+		fc = FuncCodeReadHoldingRegisters
+	default:
+		return 0, 0, fmt.Errorf("invalid conventional reference %d: unknown table %dxxxx", ref, table)
+	}
+
+	return fc, Address(offset - 1), nil
+}
+
+// ToConventional converts a read function code and zero-based address into a
+// conventional reference. Addresses up to 9998 use the five-digit form
+// (e.g. 40001); higher addresses use the six-digit form (e.g. 410000).
+func ToConventional(fc FunctionCode, address Address) (uint32, error) {
+	var table uint32
+	switch fc {
+	case FuncCodeReadCoils:
+		table = ConventionalCoil
+	case FuncCodeReadDiscreteInputs:
+		table = ConventionalDiscreteInput
+	case FuncCodeReadInputRegisters:
+		table = ConventionalInputRegister
+	case FuncCodeReadHoldingRegisters:
+		table = ConventionalHoldingRegister
+	default:
+		return 0, fmt.Errorf("function code %s has no conventional reference table", fc)
+	}
+
+	offset := uint32(address) + 1
+	if offset <= 9999 {
+		return table*10000 + offset, nil
+	}
+	if table == ConventionalCoil {
+		return 0, fmt.Errorf("coil address %d cannot be expressed as a conventional reference", address)
+	}
+	return table*100000 + offset, nil
+}
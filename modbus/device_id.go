@@ -0,0 +1,187 @@
+package modbus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxDeviceIDObjectLength is the longest object value that fits in a single
+// Read Device Identification response alongside its header
+const MaxDeviceIDObjectLength = MaxPDUSize - 1 - 6 - 2
+
+// DeviceIDExtendedFirst is the first object ID of the extended (private)
+// object range
+const DeviceIDExtendedFirst = 0x80
+
+// DeviceIdentificationBuilder builds a DeviceIdentification whose conformity
+// level matches the objects it holds:
+//
+//	info, err := modbus.NewDeviceIdentification().
+//		Basic("Acme", "PM-100", "1.2").
+//		Regular("https://acme.example", "Power Meter", "PM-100A", "").
+//		Extended(0x80, "serial 123456").
+//		Build()
+type DeviceIdentificationBuilder struct {
+	info     DeviceIdentification
+	extended map[uint8]string
+	err      error
+}
+
+// NewDeviceIdentification starts building a device identification
+func NewDeviceIdentification() *DeviceIdentificationBuilder {
+	return &DeviceIdentificationBuilder{}
+}
+
+// Basic sets the mandatory basic objects (0x00-0x02)
+func (b *DeviceIdentificationBuilder) Basic(vendorName, productCode, majorMinorRevision string) *DeviceIdentificationBuilder {
+	b.info.VendorName = vendorName
+	b.info.ProductCode = productCode
+	b.info.MajorMinorRevision = majorMinorRevision
+	return b
+}
+
+// Regular sets the optional regular objects (0x03-0x06). Empty values are
+// left out of responses.
+func (b *DeviceIdentificationBuilder) Regular(vendorURL, productName, modelName, userApplicationName string) *DeviceIdentificationBuilder {
+	b.info.VendorURL = vendorURL
+	b.info.ProductName = productName
+	b.info.ModelName = modelName
+	b.info.UserApplicationName = userApplicationName
+	return b
+}
+
+// Extended adds a private object. Object IDs must be in the range 0x80-0xFF.
+func (b *DeviceIdentificationBuilder) Extended(objectID uint8, value string) *DeviceIdentificationBuilder {
+	if objectID < DeviceIDExtendedFirst {
+		if b.err == nil {
+			b.err = fmt.Errorf("extended object ID 0x%02X out of range 0x80-0xFF", objectID)
+		}
+		return b
+	}
+	if b.extended == nil {
+		b.extended = make(map[uint8]string)
+	}
+	b.extended[objectID] = value
+	return b
+}
+
+// Build validates the objects and returns the device identification. The
+// conformity level is extended if any extended object was added, regular if
+// any regular object is set, and basic otherwise; individual access is always
+// advertised.
+func (b *DeviceIdentificationBuilder) Build() (*DeviceIdentification, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	var missing []string
+	if b.info.VendorName == "" {
+		missing = append(missing, "VendorName")
+	}
+	if b.info.ProductCode == "" {
+		missing = append(missing, "ProductCode")
+	}
+	if b.info.MajorMinorRevision == "" {
+		missing = append(missing, "MajorMinorRevision")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("basic device identification requires %s", strings.Join(missing, ", "))
+	}
+
+	info := b.info
+	switch {
+	case len(b.extended) > 0:
+		info.ExtendedObjects = make(map[uint8]string, len(b.extended))
+		for id, value := range b.extended {
+			info.ExtendedObjects[id] = value
+		}
+		info.ConformityLevel = ConformityLevelExtendedIndividual
+	case info.VendorURL != "" || info.ProductName != "" || info.ModelName != "" || info.UserApplicationName != "":
+		info.ConformityLevel = ConformityLevelRegularIndividual
+	default:
+		info.ConformityLevel = ConformityLevelBasicIndividual
+	}
+
+	for _, obj := range info.Objects(DeviceIDReadExtended) {
+		if len(obj.Value) > MaxDeviceIDObjectLength {
+			return nil, fmt.Errorf("object 0x%02X is %d bytes, max %d", obj.ID, len(obj.Value), MaxDeviceIDObjectLength)
+		}
+	}
+
+	return &info, nil
+}
+
+// ConformityLevelString returns a readable name for a conformity level, such
+// as "Basic (stream)" for ConformityLevelBasicStream
+func ConformityLevelString(level uint8) string {
+	switch level {
+	case ConformityLevelBasicStream:
+		return "Basic (stream)"
+	case ConformityLevelRegularStream:
+		return "Regular (stream)"
+	case ConformityLevelExtendedStream:
+		return "Extended (stream)"
+	case ConformityLevelBasicIndividual:
+		return "Basic (individual)"
+	case ConformityLevelRegularIndividual:
+		return "Regular (individual)"
+	case ConformityLevelExtendedIndividual:
+		return "Extended (individual)"
+	default:
+		return fmt.Sprintf("Unknown(%02x)", level)
+	}
+}
+
+// DeviceIDObject is a single device identification object
+type DeviceIDObject struct {
+	ID    uint8
+	Value string
+}
+
+// Objects returns the objects readable with readCode (DeviceIDReadBasic,
+// DeviceIDReadRegular or DeviceIDReadExtended) in object ID order. A read code
+// above the device's conformity level is answered at the conformity level, as
+// the specification requires. Empty regular objects are left out.
+func (d *DeviceIdentification) Objects(readCode uint8) []DeviceIDObject {
+	level := d.ConformityLevel &^ 0x80
+	if level == 0 {
+		level = DeviceIDReadBasic
+	}
+	if readCode > level {
+		readCode = level
+	}
+
+	objects := []DeviceIDObject{
+		{DeviceIDVendorName, d.VendorName},
+		{DeviceIDProductCode, d.ProductCode},
+		{DeviceIDMajorMinorRevision, d.MajorMinorRevision},
+	}
+	if readCode < DeviceIDReadRegular {
+		return objects
+	}
+
+	for _, obj := range []DeviceIDObject{
+		{DeviceIDVendorURL, d.VendorURL},
+		{DeviceIDProductName, d.ProductName},
+		{DeviceIDModelName, d.ModelName},
+		{DeviceIDUserAppName, d.UserApplicationName},
+	} {
+		if obj.Value != "" {
+			objects = append(objects, obj)
+		}
+	}
+	if readCode < DeviceIDReadExtended {
+		return objects
+	}
+
+	ids := make([]int, 0, len(d.ExtendedObjects))
+	for id := range d.ExtendedObjects {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		objects = append(objects, DeviceIDObject{uint8(id), d.ExtendedObjects[uint8(id)]})
+	}
+	return objects
+}
@@ -0,0 +1,40 @@
+package modbus
+
+import "fmt"
+
+// DiagnosticRegisterFlags names the diagnostic register bits set by this
+// library's server. Other devices define their own bits; pass their names to
+// DecodeDiagnosticRegisterWith.
+var DiagnosticRegisterFlags = map[uint16]string{
+	DiagRegCommError:   "CommError",
+	DiagRegException:   "Exception",
+	DiagRegNoResponse:  "NoResponse",
+	DiagRegNAK:         "NAK",
+	DiagRegBusy:        "Busy",
+	DiagRegCharOverrun: "CharOverrun",
+}
+
+// DecodeDiagnosticRegister returns the names of the bits set in value, from
+// the least significant bit up, using DiagnosticRegisterFlags
+func DecodeDiagnosticRegister(value uint16) []string {
+	return DecodeDiagnosticRegisterWith(value, DiagnosticRegisterFlags)
+}
+
+// DecodeDiagnosticRegisterWith returns the names of the bits set in value,
+// from the least significant bit up, using a device-specific bit mapping. Set
+// bits without a name are reported as "bit N".
+func DecodeDiagnosticRegisterWith(value uint16, names map[uint16]string) []string {
+	var flags []string
+	for bit := 0; bit < 16; bit++ {
+		mask := uint16(1) << bit
+		if value&mask == 0 {
+			continue
+		}
+		if name, ok := names[mask]; ok {
+			flags = append(flags, name)
+		} else {
+			flags = append(flags, fmt.Sprintf("bit %d", bit))
+		}
+	}
+	return flags
+}
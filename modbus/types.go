@@ -2,8 +2,11 @@ package modbus
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -102,6 +105,8 @@ func (ec ExceptionCode) String() string {
 		return "Acknowledge"
 	case ExceptionCodeServerDeviceBusy:
 		return "ServerDeviceBusy"
+	case ExceptionCodeNegativeAcknowledge:
+		return "NegativeAcknowledge"
 	case ExceptionCodeMemoryParityError:
 		return "MemoryParityError"
 	case ExceptionCodeGatewayPathUnavail:
@@ -144,6 +149,85 @@ func NewModbusError(fc FunctionCode, ec ExceptionCode, message string) *ModbusEr
 	}
 }
 
+// IsGatewayException returns true for exceptions raised by a gateway rather
+// than the target device (Gateway Path Unavailable, Gateway Target Failed)
+func (ec ExceptionCode) IsGatewayException() bool {
+	return ec == ExceptionCodeGatewayPathUnavail || ec == ExceptionCodeGatewayTargetFail
+}
+
+// IsGatewayError reports whether err carries a gateway exception, meaning the
+// gateway could not reach the target device rather than the device rejecting the request
+func IsGatewayError(err error) bool {
+	var modbusErr *ModbusError
+	if errors.As(err, &modbusErr) {
+		return modbusErr.ExceptionCode.IsGatewayException()
+	}
+	var ec ExceptionCode
+	if errors.As(err, &ec) {
+		return ec.IsGatewayException()
+	}
+	return false
+}
+
+// TimeoutError indicates that no response arrived within the configured timeout.
+// It implements net.Error, so callers can detect timeouts with a type assertion.
+type TimeoutError struct {
+	Err error
+}
+
+// Error implements the error interface
+func (e *TimeoutError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the error is a timeout, delegating to the
+// underlying net.Error when there is one
+func (e *TimeoutError) Timeout() bool {
+	var netErr net.Error
+	if errors.As(e.Err, &netErr) {
+		return netErr.Timeout()
+	}
+	return true
+}
+
+// Temporary implements net.Error; timeouts are always considered temporary
+func (e *TimeoutError) Temporary() bool {
+	return true
+}
+
+// IsTimeout reports whether err was caused by a network or response timeout
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// WriteError indicates that a request could not be written to the connection
+// or serial port, so it never reached the device
+type WriteError struct {
+	Err error
+}
+
+// Error implements the error interface
+func (e *WriteError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// IsWriteError reports whether err was caused by a failed request write
+func IsWriteError(err error) bool {
+	var writeErr *WriteError
+	return errors.As(err, &writeErr)
+}
+
 // TransportType represents the type of MODBUS transport
 type TransportType int
 
@@ -167,7 +251,10 @@ func (tt TransportType) String() string {
 	}
 }
 
-// ClientConfig holds configuration for a MODBUS client
+// ClientConfig holds configuration for a MODBUS client.
+// A request is attempted up to RetryCount+1 times, waiting RetryDelay between
+// attempts. Only transport failures are retried; MODBUS exception responses
+// are returned to the caller immediately.
 type ClientConfig struct {
 	SlaveID        SlaveID
 	Timeout        time.Duration
@@ -244,8 +331,9 @@ func LoadClientConfigFromJSON(filepath string) (*ClientConfig, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var jsonConfig JSONClientConfig
-	if err := json.Unmarshal(data, &jsonConfig); err != nil {
+	// Start from defaults so omitted fields keep sensible non-zero values
+	jsonConfig := DefaultClientConfig().ToJSONClientConfig()
+	if err := json.Unmarshal(data, jsonConfig); err != nil {
 		return nil, fmt.Errorf("failed to parse JSON config: %w", err)
 	}
 
@@ -254,8 +342,8 @@ func LoadClientConfigFromJSON(filepath string) (*ClientConfig, error) {
 
 // LoadClientConfigFromJSONString loads client configuration from a JSON string
 func LoadClientConfigFromJSONString(jsonStr string) (*ClientConfig, error) {
-	var jsonConfig JSONClientConfig
-	if err := json.Unmarshal([]byte(jsonStr), &jsonConfig); err != nil {
+	jsonConfig := DefaultClientConfig().ToJSONClientConfig()
+	if err := json.Unmarshal([]byte(jsonStr), jsonConfig); err != nil {
 		return nil, fmt.Errorf("failed to parse JSON config: %w", err)
 	}
 
@@ -347,6 +435,17 @@ type DataStore interface {
 	GetCommEventLog() (uint16, uint16, uint16, []byte, error) // status, eventCount, messageCount, events
 }
 
+// SettableDataStore is an optional interface for data stores whose values can
+// be set directly, including the tables clients can only read. Simulators
+// holding a DataStore can type-assert to it to update sensor values.
+type SettableDataStore interface {
+	DataStore
+	SetCoil(address Address, value bool) error
+	SetDiscreteInput(address Address, value bool) error
+	SetHoldingRegister(address Address, value uint16) error
+	SetInputRegister(address Address, value uint16) error
+}
+
 // DeviceIdentification holds device identification information
 type DeviceIdentification struct {
 	VendorName          string
@@ -356,16 +455,36 @@ type DeviceIdentification struct {
 	ProductName         string
 	ModelName           string
 	UserApplicationName string
+	ExtendedObjects     map[uint8]string // private objects 0x80-0xFF
 	ConformityLevel     uint8
 }
 
+// ServerIDReport holds a parsed Report Server ID response: the server ID, the
+// run indicator status and any device-specific additional data, in the order
+// they are sent
+type ServerIDReport struct {
+	ServerID       []byte
+	RunIndicator   bool // true when the device reports RUN (0xFF)
+	AdditionalData []byte
+}
+
 // FileRecord represents a file record sub-request
 type FileRecord struct {
-	ReferenceType uint8
-	FileNumber    uint16
-	RecordNumber  uint16
-	RecordLength  uint16
-	RecordData    []uint16
+	ReferenceType uint8    `json:"reference_type"`
+	FileNumber    uint16   `json:"file_number"`
+	RecordNumber  uint16   `json:"record_number"`
+	RecordLength  uint16   `json:"record_length"`
+	RecordData    []uint16 `json:"record_data,omitempty"`
+}
+
+// String returns a string representation with the data as hex registers
+func (r FileRecord) String() string {
+	data := make([]string, len(r.RecordData))
+	for i, v := range r.RecordData {
+		data[i] = fmt.Sprintf("%04X", v)
+	}
+	return fmt.Sprintf("FileRecord(file=%d, record=%d, length=%d, data=[%s])",
+		r.FileNumber, r.RecordNumber, r.RecordLength, strings.Join(data, " "))
 }
 
 // DiagnosticData holds diagnostic information
@@ -378,4 +497,12 @@ type DiagnosticData struct {
 	ServerNAKCount      uint16
 	ServerBusyCount     uint16
 	BusCharOverrunCount uint16
+	DiagnosticRegister  uint16 // DiagReg* bits set as errors are recorded
+}
+
+// String returns a one-line summary of the counters
+func (d DiagnosticData) String() string {
+	return fmt.Sprintf("DiagnosticData(bus=%d, commErrors=%d, exceptions=%d, server=%d, noResponse=%d, nak=%d, busy=%d, overrun=%d, register=0x%04X)",
+		d.BusMessageCount, d.BusCommErrorCount, d.BusExceptionCount, d.ServerMessageCount,
+		d.ServerNoRespCount, d.ServerNAKCount, d.ServerBusyCount, d.BusCharOverrunCount, d.DiagnosticRegister)
 }
@@ -2,6 +2,7 @@ package pdu
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 
 	"github.com/adibhanna/modbus-go/modbus"
@@ -34,6 +35,19 @@ func (p *PDU) Size() int {
 	return 1 + len(p.Data)
 }
 
+// Code returns the function code. For exception responses the exception bit
+// (0x80) is still set; use IsException to test for it.
+func (p *PDU) Code() modbus.FunctionCode {
+	return p.FunctionCode
+}
+
+// Payload returns a copy of the data following the function code
+func (p *PDU) Payload() []byte {
+	data := make([]byte, len(p.Data))
+	copy(data, p.Data)
+	return data
+}
+
 // IsException returns true if this is an exception response PDU
 func (p *PDU) IsException() bool {
 	return p.FunctionCode.IsException()
@@ -86,9 +100,148 @@ func NewRequest(functionCode modbus.FunctionCode, data []byte) *Request {
 	}
 }
 
+// ExpectedResponseSize returns the size in bytes of the normal response PDU
+// to req, function code included, when the request determines it: reads,
+// writes, mask write, read/write multiple registers, file record access,
+// exception status, comm event counter and most diagnostics. It returns false
+// for responses whose length is only known once received, such as FIFO
+// queues, event logs, server ID and device identification, and for malformed
+// requests. Exception responses are always 2 bytes.
+func ExpectedResponseSize(req *Request) (int, bool) {
+	if req == nil || req.PDU == nil {
+		return 0, false
+	}
+
+	data := req.Data
+	switch req.FunctionCode {
+	case modbus.FuncCodeReadCoils, modbus.FuncCodeReadDiscreteInputs:
+		if len(data) != 4 {
+			return 0, false
+		}
+		quantity := int(binary.BigEndian.Uint16(data[2:4]))
+		return 2 + (quantity+7)/8, true
+	case modbus.FuncCodeReadHoldingRegisters, modbus.FuncCodeReadInputRegisters:
+		if len(data) != 4 {
+			return 0, false
+		}
+		return 2 + 2*int(binary.BigEndian.Uint16(data[2:4])), true
+	case modbus.FuncCodeReadWriteMultipleRegs:
+		if len(data) < 9 {
+			return 0, false
+		}
+		return 2 + 2*int(binary.BigEndian.Uint16(data[2:4])), true
+	case modbus.FuncCodeWriteSingleCoil, modbus.FuncCodeWriteSingleRegister,
+		modbus.FuncCodeWriteMultipleCoils, modbus.FuncCodeWriteMultipleRegisters:
+		return 5, true
+	case modbus.FuncCodeMaskWriteRegister:
+		return 7, true
+	case modbus.FuncCodeReadExceptionStatus:
+		return 2, true
+	case modbus.FuncCodeGetCommEventCounter:
+		return 5, true
+	case modbus.FuncCodeDiagnostic:
+		if len(data) < 2 {
+			return 0, false
+		}
+		switch binary.BigEndian.Uint16(data[0:2]) {
+		case modbus.DiagSubReturnQueryData:
+			return req.Size(), true
+		case modbus.DiagSubForceListenOnlyMode:
+			return 0, false // no response is sent
+		default:
+			return 5, true
+		}
+	case modbus.FuncCodeWriteFileRecord:
+		return req.Size(), true // echo of the request
+	case modbus.FuncCodeReadFileRecord:
+		if len(data) < 1 || len(data) != 1+int(data[0]) || (len(data)-1)%7 != 0 {
+			return 0, false
+		}
+		size := 2 // function code and byte count
+		for offset := 1; offset < len(data); offset += 7 {
+			size += 2 + 2*int(binary.BigEndian.Uint16(data[offset+5:offset+7]))
+		}
+		return size, true
+	default:
+		return 0, false
+	}
+}
+
 // Response represents a MODBUS response PDU
 type Response struct {
 	*PDU
+
+	// Request is the request this response answers, if known. The parsers use
+	// it to describe the request in exception errors.
+	Request *Request
+}
+
+// Err returns a *modbus.ModbusError for an exception response and nil
+// otherwise
+func (r *Response) Err() error {
+	if !r.IsException() {
+		return nil
+	}
+	ec, err := r.GetExceptionCode()
+	if err != nil {
+		return err
+	}
+	return modbus.NewModbusError(r.FunctionCode.FromException(), ec, r.requestContext())
+}
+
+// ErrFunctionCodeMismatch is returned when a normal response carries a
+// function code other than the one requested, as sent by buggy devices or
+// read from a desynchronized stream
+var ErrFunctionCodeMismatch = errors.New("response function code does not match request")
+
+// checkFunctionCode returns ErrFunctionCodeMismatch unless the response
+// function code is one of expected and, if the request is known, the one it
+// asked for
+func (r *Response) checkFunctionCode(expected ...modbus.FunctionCode) error {
+	if r.Request != nil && r.Request.PDU != nil && r.FunctionCode != r.Request.FunctionCode {
+		return fmt.Errorf("%w: expected %s, got %s", ErrFunctionCodeMismatch, r.Request.FunctionCode, r.FunctionCode)
+	}
+	for _, fc := range expected {
+		if r.FunctionCode == fc {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: expected %s, got %s", ErrFunctionCodeMismatch, expected[0], r.FunctionCode)
+}
+
+// requestContext describes the originating request for exception errors,
+// e.g. "address 100, quantity 5". It returns "" if the request is unknown.
+func (r *Response) requestContext() string {
+	if r.Request == nil || r.Request.PDU == nil {
+		return ""
+	}
+
+	data := r.Request.Data
+	switch r.Request.FunctionCode {
+	case modbus.FuncCodeReadCoils, modbus.FuncCodeReadDiscreteInputs,
+		modbus.FuncCodeReadHoldingRegisters, modbus.FuncCodeReadInputRegisters,
+		modbus.FuncCodeWriteMultipleCoils, modbus.FuncCodeWriteMultipleRegisters:
+		if len(data) >= 4 {
+			return fmt.Sprintf("address %d, quantity %d",
+				binary.BigEndian.Uint16(data[0:2]), binary.BigEndian.Uint16(data[2:4]))
+		}
+	case modbus.FuncCodeReadWriteMultipleRegs:
+		if len(data) >= 8 {
+			return fmt.Sprintf("read address %d, quantity %d, write address %d, quantity %d",
+				binary.BigEndian.Uint16(data[0:2]), binary.BigEndian.Uint16(data[2:4]),
+				binary.BigEndian.Uint16(data[4:6]), binary.BigEndian.Uint16(data[6:8]))
+		}
+	case modbus.FuncCodeWriteSingleCoil, modbus.FuncCodeWriteSingleRegister,
+		modbus.FuncCodeMaskWriteRegister, modbus.FuncCodeReadFIFOQueue:
+		if len(data) >= 2 {
+			return fmt.Sprintf("address %d", binary.BigEndian.Uint16(data[0:2]))
+		}
+	case modbus.FuncCodeDiagnostic:
+		if len(data) >= 2 {
+			return fmt.Sprintf("sub-function 0x%04X", binary.BigEndian.Uint16(data[0:2]))
+		}
+	}
+	return ""
 }
 
 // NewResponse creates a new response PDU
@@ -147,6 +300,25 @@ func DecodeUint16Slice(data []byte) ([]uint16, error) {
 	return values, nil
 }
 
+// DecodeUint16SliceInto decodes big-endian uint16 values into dst without
+// allocating. dst must hold at least len(data)/2 values.
+func DecodeUint16SliceInto(data []byte, dst []uint16) error {
+	if len(data)%2 != 0 {
+		return fmt.Errorf("invalid data length for uint16 slice: must be even, got %d", len(data))
+	}
+
+	count := len(data) / 2
+	if len(dst) < count {
+		return fmt.Errorf("destination too small: need %d values, got %d", count, len(dst))
+	}
+
+	for i := 0; i < count; i++ {
+		dst[i] = binary.BigEndian.Uint16(data[i*2:])
+	}
+
+	return nil
+}
+
 // EncodeBoolSlice encodes a slice of bool values as a bit-packed byte slice
 func EncodeBoolSlice(values []bool) []byte {
 	if len(values) == 0 {
@@ -181,6 +353,16 @@ func DecodeBoolSlice(data []byte, count int) []bool {
 	return result
 }
 
+// DecodeBoolSliceInto decodes the first len(dst) bits of a bit-packed byte
+// slice into dst without allocating. Bits beyond the data are set to false.
+func DecodeBoolSliceInto(data []byte, dst []bool) {
+	for i := range dst {
+		byteIndex := i / 8
+		bitIndex := i % 8
+		dst[i] = byteIndex < len(data) && (data[byteIndex]&(1<<bitIndex)) != 0
+	}
+}
+
 // ValidateQuantity validates that a quantity is within acceptable limits for a function code
 func ValidateQuantity(functionCode modbus.FunctionCode, quantity modbus.Quantity) error {
 	switch functionCode {
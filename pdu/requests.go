@@ -214,10 +214,43 @@ func ReportServerIDRequest() (*Request, error) {
 	return NewRequest(modbus.FuncCodeReportServerID, []byte{}), nil
 }
 
+// ValidateFileRecords checks file record sub-requests before they are sent.
+// Each record must use reference type 6, a file number of 1-65535, a record
+// number of 0-9999 and a non-zero length; for writes the length must also
+// match RecordData.
+func ValidateFileRecords(records []modbus.FileRecord, write bool) error {
+	if len(records) == 0 {
+		return fmt.Errorf("at least one file record must be specified")
+	}
+
+	for i, record := range records {
+		if record.ReferenceType != modbus.FileRecordTypeExtended {
+			return fmt.Errorf("record %d: reference type %d, must be %d",
+				i, record.ReferenceType, modbus.FileRecordTypeExtended)
+		}
+		if record.FileNumber == 0 {
+			return fmt.Errorf("record %d: file number 0 is invalid, must be 1-65535", i)
+		}
+		if record.RecordNumber > modbus.MaxFileRecordNumber {
+			return fmt.Errorf("record %d: record number %d exceeds maximum %d",
+				i, record.RecordNumber, modbus.MaxFileRecordNumber)
+		}
+		if record.RecordLength == 0 {
+			return fmt.Errorf("record %d: RecordLength must be at least 1", i)
+		}
+		if write && int(record.RecordLength) != len(record.RecordData) {
+			return fmt.Errorf("record %d: RecordLength %d != data length %d",
+				i, record.RecordLength, len(record.RecordData))
+		}
+	}
+
+	return nil
+}
+
 // ReadFileRecordRequest creates a PDU for reading file record
 func ReadFileRecordRequest(records []modbus.FileRecord) (*Request, error) {
-	if len(records) == 0 {
-		return nil, fmt.Errorf("at least one file record must be specified")
+	if err := ValidateFileRecords(records, false); err != nil {
+		return nil, err
 	}
 
 	var data []byte
@@ -244,8 +277,8 @@ func ReadFileRecordRequest(records []modbus.FileRecord) (*Request, error) {
 
 // WriteFileRecordRequest creates a PDU for writing file record
 func WriteFileRecordRequest(records []modbus.FileRecord) (*Request, error) {
-	if len(records) == 0 {
-		return nil, fmt.Errorf("at least one file record must be specified")
+	if err := ValidateFileRecords(records, true); err != nil {
+		return nil, err
 	}
 
 	var data []byte
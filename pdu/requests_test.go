@@ -0,0 +1,117 @@
+package pdu
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+func TestValidateFileRecords(t *testing.T) {
+	valid := modbus.FileRecord{
+		ReferenceType: modbus.FileRecordTypeExtended,
+		FileNumber:    1,
+		RecordNumber:  0,
+		RecordLength:  2,
+		RecordData:    []uint16{0x1234, 0x5678},
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(r *modbus.FileRecord)
+		wantErr string
+	}{
+		{"Valid", func(r *modbus.FileRecord) {}, ""},
+		{"BadReferenceType", func(r *modbus.FileRecord) { r.ReferenceType = 0x05 }, "record 1: reference type 5, must be 6"},
+		{"ZeroFileNumber", func(r *modbus.FileRecord) { r.FileNumber = 0 }, "record 1: file number 0"},
+		{"RecordNumberTooLarge", func(r *modbus.FileRecord) { r.RecordNumber = 10000 }, "record 1: record number 10000"},
+		{"ZeroLength", func(r *modbus.FileRecord) { r.RecordLength = 0 }, "record 1: RecordLength must be at least 1"},
+		{"LengthMismatch", func(r *modbus.FileRecord) { r.RecordLength = 5 }, "record 1: RecordLength 5 != data length 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad := valid
+			tt.modify(&bad)
+			records := []modbus.FileRecord{valid, bad}
+
+			_, err := WriteFileRecordRequest(records)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("ReadIgnoresData", func(t *testing.T) {
+		record := valid
+		record.RecordData = nil
+		if _, err := ReadFileRecordRequest([]modbus.FileRecord{record}); err != nil {
+			t.Errorf("Unexpected error for read without data: %v", err)
+		}
+
+		record.ReferenceType = 0
+		if _, err := ReadFileRecordRequest([]modbus.FileRecord{record}); err == nil {
+			t.Error("Expected error for bad reference type on read")
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		if err := ValidateFileRecords(nil, false); err == nil {
+			t.Error("Expected error for no records")
+		}
+	})
+}
+
+func TestExpectedResponseSize(t *testing.T) {
+	must := func(req *Request, err error) *Request {
+		if err != nil {
+			t.Fatalf("Failed to build request: %v", err)
+		}
+		return req
+	}
+	records := []modbus.FileRecord{
+		{ReferenceType: modbus.FileRecordTypeExtended, FileNumber: 1, RecordNumber: 0, RecordLength: 3, RecordData: []uint16{1, 2, 3}},
+		{ReferenceType: modbus.FileRecordTypeExtended, FileNumber: 2, RecordNumber: 5, RecordLength: 1, RecordData: []uint16{4}},
+	}
+
+	tests := []struct {
+		name  string
+		req   *Request
+		size  int
+		known bool
+	}{
+		{"ReadCoils", must(ReadCoilsRequest(0, 10)), 4, true},
+		{"ReadDiscreteInputs", must(ReadDiscreteInputsRequest(0, 8)), 3, true},
+		{"ReadHoldingRegisters", must(ReadHoldingRegistersRequest(0, 3)), 8, true},
+		{"ReadInputRegisters", must(ReadInputRegistersRequest(0, 125)), 252, true},
+		{"WriteSingleCoil", must(WriteSingleCoilRequest(1, true)), 5, true},
+		{"WriteMultipleRegisters", must(WriteMultipleRegistersRequest(1, []uint16{1, 2})), 5, true},
+		{"MaskWriteRegister", must(MaskWriteRegisterRequest(1, 0xFF00, 0x00FF)), 7, true},
+		{"ReadWriteMultipleRegisters", must(ReadWriteMultipleRegistersRequest(0, 4, 10, []uint16{1})), 10, true},
+		{"ReadExceptionStatus", must(ReadExceptionStatusRequest()), 2, true},
+		{"GetCommEventCounter", must(GetCommEventCounterRequest()), 5, true},
+		{"DiagnosticEcho", must(DiagnosticRequest(modbus.DiagSubReturnQueryData, []byte{1, 2, 3, 4})), 7, true},
+		{"DiagnosticCounter", must(DiagnosticRequest(modbus.DiagSubReturnServerBusyCount, []byte{0, 0})), 5, true},
+		{"DiagnosticListenOnly", must(DiagnosticRequest(modbus.DiagSubForceListenOnlyMode, []byte{0, 0})), 0, false},
+		{"ReadFileRecord", must(ReadFileRecordRequest(records)), 2 + 8 + 4, true},
+		{"WriteFileRecord", must(WriteFileRecordRequest(records)), 1 + 1 + 2*7 + 2*4, true},
+		{"ReadFIFOQueue", must(ReadFIFOQueueRequest(0)), 0, false},
+		{"ReportServerID", must(ReportServerIDRequest()), 0, false},
+		{"Malformed", NewRequest(modbus.FuncCodeReadCoils, []byte{0, 1}), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, known := ExpectedResponseSize(tt.req)
+			if size != tt.size || known != tt.known {
+				t.Errorf("ExpectedResponseSize = %d, %v; want %d, %v", size, known, tt.size, tt.known)
+			}
+		})
+	}
+}
@@ -10,7 +10,11 @@ import (
 func ParseReadCoilsResponse(resp *Response, expectedQuantity modbus.Quantity) ([]bool, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeReadCoils); err != nil {
+		return nil, err
 	}
 
 	if len(resp.Data) < 1 {
@@ -23,6 +27,11 @@ func ParseReadCoilsResponse(resp *Response, expectedQuantity modbus.Quantity) ([
 			byteCount, len(resp.Data)-1)
 	}
 
+	if byteCount != (int(expectedQuantity)+7)/8 {
+		return nil, fmt.Errorf("invalid read coils response: expected %d bytes for %d bits, got %d",
+			(expectedQuantity+7)/8, expectedQuantity, byteCount)
+	}
+
 	return DecodeBoolSlice(resp.Data[1:], int(expectedQuantity)), nil
 }
 
@@ -30,7 +39,11 @@ func ParseReadCoilsResponse(resp *Response, expectedQuantity modbus.Quantity) ([
 func ParseReadDiscreteInputsResponse(resp *Response, expectedQuantity modbus.Quantity) ([]bool, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeReadDiscreteInputs); err != nil {
+		return nil, err
 	}
 
 	if len(resp.Data) < 1 {
@@ -43,6 +56,11 @@ func ParseReadDiscreteInputsResponse(resp *Response, expectedQuantity modbus.Qua
 			byteCount, len(resp.Data)-1)
 	}
 
+	if byteCount != (int(expectedQuantity)+7)/8 {
+		return nil, fmt.Errorf("invalid read discrete inputs response: expected %d bytes for %d bits, got %d",
+			(expectedQuantity+7)/8, expectedQuantity, byteCount)
+	}
+
 	return DecodeBoolSlice(resp.Data[1:], int(expectedQuantity)), nil
 }
 
@@ -50,7 +68,11 @@ func ParseReadDiscreteInputsResponse(resp *Response, expectedQuantity modbus.Qua
 func ParseReadHoldingRegistersResponse(resp *Response, expectedQuantity modbus.Quantity) ([]uint16, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeReadHoldingRegisters); err != nil {
+		return nil, err
 	}
 
 	if len(resp.Data) < 1 {
@@ -75,7 +97,11 @@ func ParseReadHoldingRegistersResponse(resp *Response, expectedQuantity modbus.Q
 func ParseReadInputRegistersResponse(resp *Response, expectedQuantity modbus.Quantity) ([]uint16, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeReadInputRegisters); err != nil {
+		return nil, err
 	}
 
 	if len(resp.Data) < 1 {
@@ -96,11 +122,80 @@ func ParseReadInputRegistersResponse(resp *Response, expectedQuantity modbus.Qua
 	return DecodeUint16Slice(resp.Data[1:])
 }
 
+// ParseReadBitsResponseInto parses a read coils or read discrete inputs
+// response into dst, which must hold at least expectedQuantity values
+func ParseReadBitsResponseInto(resp *Response, expectedQuantity modbus.Quantity, dst []bool) error {
+	if resp.IsException() {
+		ec, _ := resp.GetExceptionCode()
+		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeReadCoils, modbus.FuncCodeReadDiscreteInputs); err != nil {
+		return err
+	}
+
+	if len(dst) < int(expectedQuantity) {
+		return fmt.Errorf("destination too small: need %d values, got %d", expectedQuantity, len(dst))
+	}
+
+	if len(resp.Data) < 1 {
+		return fmt.Errorf("invalid %s response: no byte count", resp.FunctionCode)
+	}
+
+	byteCount := int(resp.Data[0])
+	if len(resp.Data) != 1+byteCount {
+		return fmt.Errorf("invalid %s response: expected %d data bytes, got %d",
+			resp.FunctionCode, byteCount, len(resp.Data)-1)
+	}
+
+	if byteCount != (int(expectedQuantity)+7)/8 {
+		return fmt.Errorf("invalid %s response: expected %d bytes for %d bits, got %d",
+			resp.FunctionCode, (expectedQuantity+7)/8, expectedQuantity, byteCount)
+	}
+
+	DecodeBoolSliceInto(resp.Data[1:], dst[:expectedQuantity])
+	return nil
+}
+
+// ParseReadRegistersResponseInto parses a read holding registers or read
+// input registers response into dst, which must hold at least expectedQuantity values
+func ParseReadRegistersResponseInto(resp *Response, expectedQuantity modbus.Quantity, dst []uint16) error {
+	if resp.IsException() {
+		ec, _ := resp.GetExceptionCode()
+		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeReadHoldingRegisters, modbus.FuncCodeReadInputRegisters); err != nil {
+		return err
+	}
+
+	if len(resp.Data) < 1 {
+		return fmt.Errorf("invalid %s response: no byte count", resp.FunctionCode)
+	}
+
+	byteCount := int(resp.Data[0])
+	if len(resp.Data) != 1+byteCount {
+		return fmt.Errorf("invalid %s response: expected %d data bytes, got %d",
+			resp.FunctionCode, byteCount, len(resp.Data)-1)
+	}
+
+	if byteCount != int(expectedQuantity)*2 {
+		return fmt.Errorf("invalid %s response: expected %d bytes for %d registers, got %d",
+			resp.FunctionCode, expectedQuantity*2, expectedQuantity, byteCount)
+	}
+
+	return DecodeUint16SliceInto(resp.Data[1:], dst)
+}
+
 // ParseWriteSingleCoilResponse parses a response PDU for write single coil
 func ParseWriteSingleCoilResponse(resp *Response, expectedAddress modbus.Address, expectedValue bool) error {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeWriteSingleCoil); err != nil {
+		return err
 	}
 
 	if len(resp.Data) != 4 {
@@ -139,7 +234,11 @@ func ParseWriteSingleCoilResponse(resp *Response, expectedAddress modbus.Address
 func ParseWriteSingleRegisterResponse(resp *Response, expectedAddress modbus.Address, expectedValue uint16) error {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeWriteSingleRegister); err != nil {
+		return err
 	}
 
 	if len(resp.Data) != 4 {
@@ -173,7 +272,11 @@ func ParseWriteSingleRegisterResponse(resp *Response, expectedAddress modbus.Add
 func ParseWriteMultipleCoilsResponse(resp *Response, expectedAddress modbus.Address, expectedQuantity modbus.Quantity) error {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeWriteMultipleCoils); err != nil {
+		return err
 	}
 
 	if len(resp.Data) != 4 {
@@ -207,7 +310,11 @@ func ParseWriteMultipleCoilsResponse(resp *Response, expectedAddress modbus.Addr
 func ParseWriteMultipleRegistersResponse(resp *Response, expectedAddress modbus.Address, expectedQuantity modbus.Quantity) error {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeWriteMultipleRegisters); err != nil {
+		return err
 	}
 
 	if len(resp.Data) != 4 {
@@ -241,7 +348,11 @@ func ParseWriteMultipleRegistersResponse(resp *Response, expectedAddress modbus.
 func ParseReadWriteMultipleRegistersResponse(resp *Response, expectedReadQuantity modbus.Quantity) ([]uint16, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeReadWriteMultipleRegs); err != nil {
+		return nil, err
 	}
 
 	if len(resp.Data) < 1 {
@@ -266,7 +377,11 @@ func ParseReadWriteMultipleRegistersResponse(resp *Response, expectedReadQuantit
 func ParseMaskWriteRegisterResponse(resp *Response, expectedAddress modbus.Address, expectedAndMask, expectedOrMask uint16) error {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeMaskWriteRegister); err != nil {
+		return err
 	}
 
 	if len(resp.Data) != 6 {
@@ -310,7 +425,11 @@ func ParseMaskWriteRegisterResponse(resp *Response, expectedAddress modbus.Addre
 func ParseReadFIFOQueueResponse(resp *Response) ([]uint16, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeReadFIFOQueue); err != nil {
+		return nil, err
 	}
 
 	if len(resp.Data) < 4 {
@@ -353,7 +472,11 @@ func ParseReadFIFOQueueResponse(resp *Response) ([]uint16, error) {
 func ParseReadExceptionStatusResponse(resp *Response) (uint8, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return 0, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return 0, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeReadExceptionStatus); err != nil {
+		return 0, err
 	}
 
 	if len(resp.Data) != 1 {
@@ -367,7 +490,11 @@ func ParseReadExceptionStatusResponse(resp *Response) (uint8, error) {
 func ParseDiagnosticResponse(resp *Response) (uint16, []byte, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return 0, nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return 0, nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeDiagnostic); err != nil {
+		return 0, nil, err
 	}
 
 	if len(resp.Data) < 2 {
@@ -389,7 +516,11 @@ func ParseDiagnosticResponse(resp *Response) (uint16, []byte, error) {
 func ParseGetCommEventCounterResponse(resp *Response) (uint16, uint16, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return 0, 0, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return 0, 0, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeGetCommEventCounter); err != nil {
+		return 0, 0, err
 	}
 
 	if len(resp.Data) != 4 {
@@ -413,7 +544,11 @@ func ParseGetCommEventCounterResponse(resp *Response) (uint16, uint16, error) {
 func ParseGetCommEventLogResponse(resp *Response) (uint16, uint16, uint16, []byte, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return 0, 0, 0, nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return 0, 0, 0, nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeGetCommEventLog); err != nil {
+		return 0, 0, 0, nil, err
 	}
 
 	if len(resp.Data) < 7 {
@@ -451,7 +586,11 @@ func ParseGetCommEventLogResponse(resp *Response) (uint16, uint16, uint16, []byt
 func ParseReportServerIDResponse(resp *Response) ([]byte, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeReportServerID); err != nil {
+		return nil, err
 	}
 
 	if len(resp.Data) < 2 {
@@ -464,18 +603,53 @@ func ParseReportServerIDResponse(resp *Response) ([]byte, error) {
 			byteCount, len(resp.Data)-1)
 	}
 
-	// Return everything after the byte count (includes run indicator and server ID)
+	// Return everything after the byte count (server ID, run indicator and
+	// additional data)
 	serverData := make([]byte, byteCount)
 	copy(serverData, resp.Data[1:])
 
 	return serverData, nil
 }
 
+// ParseServerIDReport splits the data returned by ParseReportServerIDResponse
+// into the server ID, the run indicator status and the additional data. The
+// server ID length is device specific, so the caller supplies it as idLen.
+func ParseServerIDReport(serverData []byte, idLen int) (*modbus.ServerIDReport, error) {
+	if idLen < 0 {
+		return nil, fmt.Errorf("invalid server ID length %d", idLen)
+	}
+	if len(serverData) < idLen+1 {
+		return nil, fmt.Errorf("invalid report server ID data: need %d bytes for a %d byte server ID and run indicator, got %d",
+			idLen+1, idLen, len(serverData))
+	}
+
+	runIndicator := serverData[idLen]
+	if runIndicator != 0x00 && runIndicator != 0xFF {
+		return nil, fmt.Errorf("invalid run indicator status after %d byte server ID: expected 0x00 or 0xFF, got 0x%02X",
+			idLen, runIndicator)
+	}
+
+	serverID := make([]byte, idLen)
+	copy(serverID, serverData[:idLen])
+	additionalData := make([]byte, len(serverData)-idLen-1)
+	copy(additionalData, serverData[idLen+1:])
+
+	return &modbus.ServerIDReport{
+		ServerID:       serverID,
+		RunIndicator:   runIndicator == 0xFF,
+		AdditionalData: additionalData,
+	}, nil
+}
+
 // ParseReadFileRecordResponse parses a response PDU for read file record
 func ParseReadFileRecordResponse(resp *Response, requestedRecords []modbus.FileRecord) ([]modbus.FileRecord, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return nil, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeReadFileRecord); err != nil {
+		return nil, err
 	}
 
 	if len(resp.Data) < 1 {
@@ -535,7 +709,11 @@ func ParseReadFileRecordResponse(resp *Response, requestedRecords []modbus.FileR
 func ParseWriteFileRecordResponse(resp *Response) error {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeWriteFileRecord); err != nil {
+		return err
 	}
 
 	// The response is an echo of the request, so we just validate the format
@@ -556,7 +734,11 @@ func ParseWriteFileRecordResponse(resp *Response) error {
 func ParseReadDeviceIdentificationResponse(resp *Response) (*modbus.DeviceIdentification, bool, uint8, error) {
 	if resp.IsException() {
 		ec, _ := resp.GetExceptionCode()
-		return nil, false, 0, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, "")
+		return nil, false, 0, modbus.NewModbusError(resp.FunctionCode.FromException(), ec, resp.requestContext())
+	}
+
+	if err := resp.checkFunctionCode(modbus.FuncCodeEncapsulatedInterface); err != nil {
+		return nil, false, 0, err
 	}
 
 	if len(resp.Data) < 6 {
@@ -611,6 +793,13 @@ func ParseReadDeviceIdentificationResponse(resp *Response) (*modbus.DeviceIdenti
 			deviceID.ModelName = objectValue
 		case modbus.DeviceIDUserAppName:
 			deviceID.UserApplicationName = objectValue
+		default:
+			if objectID >= modbus.DeviceIDExtendedFirst {
+				if deviceID.ExtendedObjects == nil {
+					deviceID.ExtendedObjects = make(map[uint8]string)
+				}
+				deviceID.ExtendedObjects[objectID] = objectValue
+			}
 		}
 	}
 
@@ -0,0 +1,64 @@
+package pdu
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+func TestParseResponseFunctionCodeMismatch(t *testing.T) {
+	// A read input registers response answering a read holding registers request
+	resp := NewResponse(modbus.FuncCodeReadInputRegisters, []byte{0x02, 0x12, 0x34})
+
+	if _, err := ParseReadHoldingRegistersResponse(resp, 1); !errors.Is(err, ErrFunctionCodeMismatch) {
+		t.Errorf("Expected ErrFunctionCodeMismatch, got %v", err)
+	}
+	if _, err := ParseReadInputRegistersResponse(resp, 1); err != nil {
+		t.Errorf("Expected matching response to parse, got %v", err)
+	}
+
+	// The shared parsers accept either table but must match a known request
+	dst := make([]uint16, 1)
+	if err := ParseReadRegistersResponseInto(resp, 1, dst); err != nil {
+		t.Errorf("Expected input registers response to parse, got %v", err)
+	}
+	resp.Request, _ = ReadHoldingRegistersRequest(0, 1)
+	if err := ParseReadRegistersResponseInto(resp, 1, dst); !errors.Is(err, ErrFunctionCodeMismatch) {
+		t.Errorf("Expected ErrFunctionCodeMismatch for known request, got %v", err)
+	}
+
+	if err := ParseWriteSingleRegisterResponse(NewResponse(modbus.FuncCodeWriteSingleCoil, []byte{0, 1, 0xFF, 0}), 1, 0xFF00); !errors.Is(err, ErrFunctionCodeMismatch) {
+		t.Errorf("Expected ErrFunctionCodeMismatch for write response, got %v", err)
+	}
+}
+
+func TestParseReadCoilsResponseIgnoresPadding(t *testing.T) {
+	// 10 coils: 0xFF for coils 0-7, then 0xFE with coil 8 clear, coil 9 set
+	// and the 6 padding bits all set
+	resp := NewResponse(modbus.FuncCodeReadCoils, []byte{0x02, 0xFF, 0xFE})
+	values, err := ParseReadCoilsResponse(resp, 10)
+	if err != nil {
+		t.Fatalf("ParseReadCoilsResponse failed: %v", err)
+	}
+	want := []bool{true, true, true, true, true, true, true, true, false, true}
+	if len(values) != len(want) {
+		t.Fatalf("Expected %d values, got %d", len(want), len(values))
+	}
+	for i := range want {
+		if values[i] != want[i] {
+			t.Errorf("Coil %d: expected %v, got %v", i, want[i], values[i])
+		}
+	}
+
+	// A response one byte short would otherwise pad the missing coils with false
+	if _, err := ParseReadCoilsResponse(NewResponse(modbus.FuncCodeReadCoils, []byte{0x01, 0xFF}), 10); err == nil {
+		t.Error("Expected error for short coil response")
+	}
+	if _, err := ParseReadDiscreteInputsResponse(NewResponse(modbus.FuncCodeReadDiscreteInputs, []byte{0x03, 0xFF, 0xFF, 0xFF}), 16); err == nil {
+		t.Error("Expected error for long discrete input response")
+	}
+	if err := ParseReadBitsResponseInto(NewResponse(modbus.FuncCodeReadCoils, []byte{0x01, 0xFF}), 9, make([]bool, 9)); err == nil {
+		t.Error("Expected error for short response into dst")
+	}
+}
@@ -0,0 +1,92 @@
+package modbus
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+// ReadRange is one read request planned by PlanReads
+type ReadRange struct {
+	Address  modbus.Address
+	Quantity modbus.Quantity
+}
+
+// PlanReads merges scattered addresses into as few reads as possible. Two
+// addresses share a read if at most maxGap unneeded addresses lie between
+// them and the read stays within maxQuantity; the unneeded addresses are read
+// and discarded. A maxGap of 0 merges only adjacent addresses. Addresses need
+// not be sorted, and duplicates are ignored.
+func PlanReads(addresses []modbus.Address, maxGap int, maxQuantity int) []ReadRange {
+	spans := make([]ReadRange, len(addresses))
+	for i, a := range addresses {
+		spans[i] = ReadRange{Address: a, Quantity: 1}
+	}
+	return planSpans(spans, maxGap, maxQuantity)
+}
+
+// planSpans merges spans of addresses into reads like PlanReads. A span is
+// never split across reads, so a span longer than maxQuantity gets a read of
+// its own.
+func planSpans(spans []ReadRange, maxGap int, maxQuantity int) []ReadRange {
+	if len(spans) == 0 || maxQuantity <= 0 {
+		return nil
+	}
+
+	sorted := make([]ReadRange, len(spans))
+	copy(sorted, spans)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Address < sorted[j].Address })
+
+	var plan []ReadRange
+	start := int(sorted[0].Address)
+	end := start + int(sorted[0].Quantity) - 1
+	for _, span := range sorted[1:] {
+		first, last := int(span.Address), int(span.Address)+int(span.Quantity)-1
+		if first-end-1 > maxGap || max(end, last)-start+1 > maxQuantity {
+			plan = append(plan, ReadRange{Address: modbus.Address(start), Quantity: modbus.Quantity(end - start + 1)})
+			start, end = first, last
+			continue
+		}
+		end = max(end, last)
+	}
+	return append(plan, ReadRange{Address: modbus.Address(start), Quantity: modbus.Quantity(end - start + 1)})
+}
+
+// ReadPoints reads the holding registers at scattered addresses, merging
+// nearby addresses into combined reads with PlanReads. Reads are limited to
+// MaxReadHoldingRegs or the limit found by DiscoverMaxReadQuantity. The result
+// holds a value for each requested address.
+func (c *Client) ReadPoints(addresses []modbus.Address, maxGap int) (map[modbus.Address]uint16, error) {
+	return readPoints(addresses, maxGap, c.maxReadQuantity(modbus.FuncCodeReadHoldingRegisters), c.ReadHoldingRegisters)
+}
+
+// ReadCoilPoints reads the coils at scattered addresses like ReadPoints
+func (c *Client) ReadCoilPoints(addresses []modbus.Address, maxGap int) (map[modbus.Address]bool, error) {
+	return readPoints(addresses, maxGap, c.maxReadQuantity(modbus.FuncCodeReadCoils), c.ReadCoils)
+}
+
+// readPoints reads addresses using the reads planned by PlanReads and keeps
+// only the requested values
+func readPoints[T any](addresses []modbus.Address, maxGap, maxQuantity int,
+	read func(modbus.Address, modbus.Quantity) ([]T, error)) (map[modbus.Address]T, error) {
+	wanted := make(map[modbus.Address]bool, len(addresses))
+	for _, a := range addresses {
+		wanted[a] = true
+	}
+
+	values := make(map[modbus.Address]T, len(wanted))
+	for _, r := range PlanReads(addresses, maxGap, maxQuantity) {
+		block, err := read(r.Address, r.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %d at address %d: %w", r.Quantity, r.Address, err)
+		}
+		// Values read in the gaps are discarded
+		for i, v := range block {
+			if address := r.Address + modbus.Address(i); wanted[address] {
+				values[address] = v
+			}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,297 @@
+package modbus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+// PointTable names the data table a schema point is read from
+type PointTable string
+
+// Data tables a schema point can be read from
+const (
+	TableCoils            PointTable = "coils"
+	TableDiscreteInputs   PointTable = "discrete_inputs"
+	TableHoldingRegisters PointTable = "holding_registers"
+	TableInputRegisters   PointTable = "input_registers"
+)
+
+// pointTables lists the tables in the order ReadAll reads them
+var pointTables = []PointTable{TableCoils, TableDiscreteInputs, TableHoldingRegisters, TableInputRegisters}
+
+// functionCode returns the read function code for the table
+func (t PointTable) functionCode() modbus.FunctionCode {
+	switch t {
+	case TableCoils:
+		return modbus.FuncCodeReadCoils
+	case TableDiscreteInputs:
+		return modbus.FuncCodeReadDiscreteInputs
+	case TableInputRegisters:
+		return modbus.FuncCodeReadInputRegisters
+	default:
+		return modbus.FuncCodeReadHoldingRegisters
+	}
+}
+
+// isBits returns true for the coil and discrete input tables
+func (t PointTable) isBits() bool {
+	return t == TableCoils || t == TableDiscreteInputs
+}
+
+// SchemaPoint is one named value in a PointSchema. Type is a layout field
+// type name such as "int16" or "float32" (default "uint16"); bit tables take
+// no type. ByteOrder ("big" or "little") and WordOrder ("high" or "low")
+// override the client's encoding for a 32 or 64-bit point; if only one is
+// set, the other is the MODBUS default.
+type SchemaPoint struct {
+	Name      string     `json:"name"`
+	Table     PointTable `json:"table"`
+	Address   uint16     `json:"address"`
+	Type      string     `json:"type,omitempty"`
+	Scale     float64    `json:"scale,omitempty"`
+	Offset    float64    `json:"offset,omitempty"`
+	ByteOrder string     `json:"byte_order,omitempty"`
+	WordOrder string     `json:"word_order,omitempty"`
+}
+
+// PointSchema describes device points at runtime, for reading them with
+// ReadAll without declaring Go types. It is usually loaded from JSON:
+//
+//	{
+//	  "max_gap": 4,
+//	  "points": [
+//	    {"name": "running", "table": "coils", "address": 0},
+//	    {"name": "temp", "table": "input_registers", "address": 10, "type": "int16", "scale": 0.1},
+//	    {"name": "energy", "table": "holding_registers", "address": 100, "type": "uint32", "word_order": "low"}
+//	  ]
+//	}
+//
+// MaxGap is the number of unneeded addresses a read may span to combine
+// nearby points, as in PlanReads.
+type PointSchema struct {
+	MaxGap int           `json:"max_gap,omitempty"`
+	Points []SchemaPoint `json:"points"`
+}
+
+// ParsePointSchema parses and validates a JSON point schema
+func ParsePointSchema(data []byte) (*PointSchema, error) {
+	var schema PointSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse point schema: %w", err)
+	}
+	if err := schema.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid point schema: %w", err)
+	}
+	return &schema, nil
+}
+
+// LoadPointSchema reads and validates a JSON point schema file
+func LoadPointSchema(path string) (*PointSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read point schema %s: %w", path, err)
+	}
+	return ParsePointSchema(data)
+}
+
+// Validate checks every point and returns all problems found, joined
+func (s *PointSchema) Validate() error {
+	if len(s.Points) == 0 {
+		return errors.New("schema has no points")
+	}
+	if s.MaxGap < 0 {
+		return fmt.Errorf("max_gap %d is negative", s.MaxGap)
+	}
+
+	var errs []error
+	names := make(map[string]bool, len(s.Points))
+	for i, p := range s.Points {
+		fail := func(format string, v ...interface{}) {
+			errs = append(errs, fmt.Errorf("point %d (%s): %s", i, p.Name, fmt.Sprintf(format, v...)))
+		}
+
+		if p.Name == "" {
+			fail("name is empty")
+		} else if names[p.Name] {
+			fail("duplicate name")
+		}
+		names[p.Name] = true
+
+		if p.Table.isBits() {
+			if p.Type != "" && p.Type != "bool" {
+				fail("type %q not allowed for %s", p.Type, p.Table)
+			}
+			continue
+		}
+		if p.Table != TableHoldingRegisters && p.Table != TableInputRegisters {
+			fail("unknown table %q", p.Table)
+			continue
+		}
+
+		t, err := p.fieldType()
+		if err != nil {
+			fail("%v", err)
+			continue
+		}
+		if int(p.Address)+t.Registers() > 65536 {
+			fail("%s at address %d runs past the end of the address space", t, p.Address)
+		}
+		if _, err := p.encoding(); err != nil {
+			fail("%v", err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fieldType returns the layout field type named by the point's Type
+func (p SchemaPoint) fieldType() (LayoutFieldType, error) {
+	if p.Type == "" {
+		return LayoutUint16, nil
+	}
+	for t := LayoutUint16; t < LayoutSkip; t++ {
+		if strings.EqualFold(p.Type, t.String()) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown type %q", p.Type)
+}
+
+// encoding returns the point's encoding override, or nil to use the client's
+func (p SchemaPoint) encoding() (*EncodingConfig, error) {
+	if p.ByteOrder == "" && p.WordOrder == "" {
+		return nil, nil
+	}
+
+	enc := DefaultEncodingConfig()
+	switch strings.ToLower(p.ByteOrder) {
+	case "", "big":
+	case "little":
+		enc.ByteOrder = LittleEndian
+	default:
+		return nil, fmt.Errorf("unknown byte_order %q", p.ByteOrder)
+	}
+	switch strings.ToLower(p.WordOrder) {
+	case "", "high":
+	case "low":
+		enc.WordOrder = LowWordFirst
+	default:
+		return nil, fmt.Errorf("unknown word_order %q", p.WordOrder)
+	}
+	return enc, nil
+}
+
+// span returns the addresses the point occupies
+func (p SchemaPoint) span() ReadRange {
+	quantity := 1
+	if !p.Table.isBits() {
+		t, _ := p.fieldType()
+		quantity = t.Registers()
+	}
+	return ReadRange{Address: modbus.Address(p.Address), Quantity: modbus.Quantity(quantity)}
+}
+
+// ReadAll reads every point in schema and returns the values keyed by point
+// name. Points in the same table are merged into as few reads as possible,
+// limited by MaxGap and the client's read limits; a multi-register point is
+// never split across reads. Bits are returned as bool and registers as the
+// Go type of their layout field type, or as float64 when the point has a
+// scale or offset (value*scale+offset).
+func (c *Client) ReadAll(schema *PointSchema) (LayoutValues, error) {
+	if err := schema.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid point schema: %w", err)
+	}
+
+	values := make(LayoutValues, len(schema.Points))
+	for _, table := range pointTables {
+		var points []SchemaPoint
+		var spans []ReadRange
+		for _, p := range schema.Points {
+			if p.Table == table {
+				points = append(points, p)
+				spans = append(spans, p.span())
+			}
+		}
+
+		for _, r := range planSpans(spans, schema.MaxGap, c.maxReadQuantity(table.functionCode())) {
+			if err := c.readPointRange(table, r, points, values); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+// readPointRange reads r from table and decodes the points it contains into
+// values
+func (c *Client) readPointRange(table PointTable, r ReadRange, points []SchemaPoint, values LayoutValues) error {
+	var bits []bool
+	var regs []uint16
+	var err error
+	switch table {
+	case TableCoils:
+		bits, err = c.ReadCoils(r.Address, r.Quantity)
+	case TableDiscreteInputs:
+		bits, err = c.ReadDiscreteInputs(r.Address, r.Quantity)
+	case TableHoldingRegisters:
+		regs, err = c.ReadHoldingRegisters(r.Address, r.Quantity)
+	case TableInputRegisters:
+		regs, err = c.ReadInputRegisters(r.Address, r.Quantity)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %d %s at address %d: %w", r.Quantity, table, r.Address, err)
+	}
+
+	for _, p := range points {
+		span := p.span()
+		if span.Address < r.Address || int(span.Address)+int(span.Quantity) > int(r.Address)+int(r.Quantity) {
+			continue
+		}
+		offset := int(span.Address - r.Address)
+		if table.isBits() {
+			values[p.Name] = bits[offset]
+			continue
+		}
+		values[p.Name] = c.decodePoint(p, regs[offset:offset+int(span.Quantity)])
+	}
+	return nil
+}
+
+// decodePoint decodes a register point, applying its encoding, scale and
+// offset
+func (c *Client) decodePoint(p SchemaPoint, regs []uint16) interface{} {
+	t, _ := p.fieldType()
+	enc, _ := p.encoding()
+
+	var value interface{}
+	switch t {
+	case LayoutUint32:
+		value = c.decodeUint32Enc(enc, regs)
+	case LayoutInt32:
+		value = int32(c.decodeUint32Enc(enc, regs))
+	case LayoutFloat32:
+		value = math.Float32frombits(c.decodeUint32Enc(enc, regs))
+	case LayoutUint64:
+		value = c.decodeUint64Enc(enc, regs)
+	case LayoutInt64:
+		value = int64(c.decodeUint64Enc(enc, regs))
+	case LayoutFloat64:
+		value = math.Float64frombits(c.decodeUint64Enc(enc, regs))
+	default:
+		value = c.decodeLayoutField(t, regs)
+	}
+
+	if p.Scale == 0 && p.Offset == 0 {
+		return value
+	}
+	scale := p.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	return layoutFloat(value)*scale + p.Offset
+}
@@ -0,0 +1,247 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adibhanna/modbus-go/modbus"
+	"github.com/adibhanna/modbus-go/transport"
+	"go.bug.st/serial"
+)
+
+// serialScanDelay is the pause between probes on serial lines, giving slow
+// devices time to return to receive mode before the next unit is addressed
+const serialScanDelay = 50 * time.Millisecond
+
+// ProbeFunc probes the client's current slave ID and returns nil if it responded
+type ProbeFunc func(c *Client) error
+
+// ProbeReportServerID probes a slave using Report Server ID (function code 0x11)
+func ProbeReportServerID(c *Client) error {
+	_, err := c.ReportServerID()
+	return err
+}
+
+// ProbeReadHoldingRegister returns a probe that reads a single holding register
+func ProbeReadHoldingRegister(address modbus.Address) ProbeFunc {
+	return func(c *Client) error {
+		_, err := c.ReadHoldingRegisters(address, 1)
+		return err
+	}
+}
+
+// ScanSlaves probes every slave ID in [first, last] and returns the IDs that
+// answered without an exception or timeout. Each ID is probed once, without
+// retries. Over TCP all probes share the current connection; on serial lines
+// a short delay is inserted between probes. The broadcast address is skipped.
+// If probe is nil, ProbeReportServerID is used.
+func (c *Client) ScanSlaves(first, last modbus.SlaveID, probe ProbeFunc) []modbus.SlaveID {
+	if probe == nil {
+		probe = ProbeReportServerID
+	}
+
+	// Probe through a copy of the client so concurrent requests keep using
+	// the configured slave ID and retry count
+	scanner := c.clone()
+	scanner.retryCount = 0
+
+	serial := scanner.transport.GetTransportType() != modbus.TransportTCP
+
+	var found []modbus.SlaveID
+	for id := int(first); id <= int(last); id++ {
+		if id == modbus.BroadcastAddress {
+			continue
+		}
+
+		scanner.slaveID = modbus.SlaveID(id)
+		if err := probe(scanner); err == nil {
+			found = append(found, modbus.SlaveID(id))
+		}
+
+		if serial && id < int(last) {
+			time.Sleep(serialScanDelay)
+		}
+	}
+
+	return found
+}
+
+// DefaultDetectBaudRates are the baud rates DetectSerialSettings tries when
+// none are given, most common first
+var DefaultDetectBaudRates = []int{19200, 9600, 38400, 57600, 115200, 4800, 2400}
+
+// DefaultDetectParities are the parities DetectSerialSettings tries when none
+// are given. Even parity is the MODBUS serial line default.
+var DefaultDetectParities = []string{"E", "N", "O"}
+
+// serialReconfigurer is implemented by serial transports whose line settings
+// can be changed, such as transport.RTUTransport
+type serialReconfigurer interface {
+	GetConfig() *transport.SerialConfig
+	Reconfigure(config *transport.SerialConfig) error
+}
+
+// DetectSerialSettings searches for the baud rate and parity of the client's
+// slave on an RTU transport. Each combination is applied with Reconfigure and
+// probed once without retries; the first combination that yields a CRC-valid
+// response, including an exception response, is left applied and returned.
+// If nothing answers or a combination cannot be applied, the original
+// settings and connection state are restored and an error is returned. Nil
+// baudRates, parities or probe select the defaults.
+func (c *Client) DetectSerialSettings(baudRates []int, parities []string, probe ProbeFunc) (found *transport.SerialConfig, err error) {
+	line, ok := c.transport.(serialReconfigurer)
+	if !ok {
+		return nil, fmt.Errorf("serial settings detection requires an RTU transport, got %s", c.transport.GetTransportType())
+	}
+	if baudRates == nil {
+		baudRates = DefaultDetectBaudRates
+	}
+	if parities == nil {
+		parities = DefaultDetectParities
+	}
+	if probe == nil {
+		probe = ProbeReportServerID
+	}
+
+	parsed := make([]serial.Parity, len(parities))
+	for i, p := range parities {
+		parity, err := transport.ParseParity(p)
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = parity
+	}
+
+	prober := c.clone()
+	prober.retryCount = 0
+	prober.autoReconnect = false
+
+	original := line.GetConfig()
+	wasConnected := c.transport.IsConnected()
+	defer func() {
+		if found != nil {
+			return
+		}
+		restoreErr := line.Reconfigure(original)
+		if restoreErr == nil && wasConnected && !c.transport.IsConnected() {
+			restoreErr = c.transport.Connect()
+		}
+		if restoreErr != nil {
+			err = fmt.Errorf("%w; restoring original settings failed: %v", err, restoreErr)
+		}
+	}()
+
+	for _, baud := range baudRates {
+		for _, parity := range parsed {
+			config := *original
+			config.BaudRate = baud
+			config.Parity = parity
+			if err := line.Reconfigure(&config); err != nil {
+				return nil, err
+			}
+			if !c.transport.IsConnected() {
+				if err := c.transport.Connect(); err != nil {
+					return nil, err
+				}
+			}
+
+			err := probe(prober)
+			var modbusErr *modbus.ModbusError
+			if err == nil || errors.As(err, &modbusErr) {
+				return line.GetConfig(), nil
+			}
+
+			time.Sleep(serialScanDelay)
+		}
+	}
+
+	return nil, fmt.Errorf("no response from slave %d at any of the tried serial settings", prober.slaveID)
+}
+
+// protocolReadLimits are the protocol maximum quantities of the read functions
+var protocolReadLimits = map[modbus.FunctionCode]int{
+	modbus.FuncCodeReadCoils:            modbus.MaxReadCoils,
+	modbus.FuncCodeReadDiscreteInputs:   modbus.MaxReadDiscreteInputs,
+	modbus.FuncCodeReadHoldingRegisters: modbus.MaxReadHoldingRegs,
+	modbus.FuncCodeReadInputRegisters:   modbus.MaxReadInputRegs,
+}
+
+// DiscoverMaxReadQuantity finds the largest quantity the slave accepts for a
+// read function code (0x01-0x04) starting at address. The protocol maximum is
+// tried first; a request rejected with IllegalDataValue or IllegalDataAddress
+// is retried with a smaller quantity, binary searching for the limit. Each
+// probe is sent once, without retries. Any other error stops the search.
+//
+// The limit found is remembered and used as the chunk size by SnapshotAll and
+// StreamHoldingRegisters.
+func (c *Client) DiscoverMaxReadQuantity(fc modbus.FunctionCode, address modbus.Address) (int, error) {
+	max, ok := protocolReadLimits[fc]
+	if !ok {
+		return 0, fmt.Errorf("function code 0x%02X is not a read function", uint8(fc))
+	}
+
+	prober := c.clone()
+	prober.retryCount = 0
+
+	read := func(quantity int) error {
+		q := modbus.Quantity(quantity)
+		var err error
+		switch fc {
+		case modbus.FuncCodeReadCoils:
+			_, err = prober.ReadCoils(address, q)
+		case modbus.FuncCodeReadDiscreteInputs:
+			_, err = prober.ReadDiscreteInputs(address, q)
+		case modbus.FuncCodeReadHoldingRegisters:
+			_, err = prober.ReadHoldingRegisters(address, q)
+		case modbus.FuncCodeReadInputRegisters:
+			_, err = prober.ReadInputRegisters(address, q)
+		}
+		return err
+	}
+
+	// lo is the largest quantity accepted, hi the smallest rejected
+	lo, hi := 0, max+1
+	for quantity := max; hi-lo > 1; quantity = (lo + hi) / 2 {
+		err := read(quantity)
+		if err == nil {
+			lo = quantity
+			continue
+		}
+
+		var modbusErr *modbus.ModbusError
+		if !errors.As(err, &modbusErr) ||
+			(modbusErr.ExceptionCode != modbus.ExceptionCodeIllegalDataValue &&
+				modbusErr.ExceptionCode != modbus.ExceptionCodeIllegalDataAddress) {
+			return 0, fmt.Errorf("failed to read %d at address %d: %w", quantity, address, err)
+		}
+		hi = quantity
+	}
+
+	if lo == 0 {
+		return 0, fmt.Errorf("slave rejected every quantity for function code 0x%02X at address %d", uint8(fc), address)
+	}
+
+	c.mutex.Lock()
+	limits := make(map[modbus.FunctionCode]int, len(c.readLimits)+1)
+	for k, v := range c.readLimits {
+		limits[k] = v
+	}
+	limits[fc] = lo
+	c.readLimits = limits
+	c.mutex.Unlock()
+
+	return lo, nil
+}
+
+// maxReadQuantity returns the discovered read limit for fc, or the protocol
+// maximum if none was discovered
+func (c *Client) maxReadQuantity(fc modbus.FunctionCode) int {
+	c.mutex.RLock()
+	limit, ok := c.readLimits[fc]
+	c.mutex.RUnlock()
+	if ok {
+		return limit
+	}
+	return protocolReadLimits[fc]
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/adibhanna/modbus-go/modbus"
@@ -11,6 +12,14 @@ import (
 	"github.com/adibhanna/modbus-go/transport"
 )
 
+// Compile-time checks that the server types satisfy the interfaces shared with
+// the modbus and transport packages
+var (
+	_ modbus.DataStore         = (*DefaultDataStore)(nil)
+	_ modbus.DataStore         = (*CallbackDataStore)(nil)
+	_ transport.RequestHandler = (*ServerRequestHandler)(nil)
+)
+
 // Server represents a MODBUS server
 type Server struct {
 	transport  transport.RequestHandler
@@ -20,14 +29,25 @@ type Server struct {
 	mutex      sync.RWMutex
 }
 
+// Default file record limits of a DefaultDataStore; see SetFileRecordLimits
+const (
+	DefaultMaxFileRecords   = 10000
+	DefaultMaxFileRegisters = 1 << 20
+)
+
 // DefaultDataStore provides a simple in-memory data store
 type DefaultDataStore struct {
-	coils            []bool
-	discreteInputs   []bool
-	holdingRegisters []uint16
-	inputRegisters   []uint16
+	coils            dataTable[bool]
+	discreteInputs   dataTable[bool]
+	holdingRegisters dataTable[uint16]
+	inputRegisters   dataTable[uint16]
 	fileRecords      map[uint16]map[uint16][]uint16 // fileNumber -> recordNumber -> data
-	fifoQueues       map[uint16][]uint16            // address -> queue data
+	fileRecordCount  int
+	fileRegisters    int
+	maxFileRecords   int
+	maxFileRegisters int
+	fifoQueues       map[uint16][]uint16 // address -> queue data
+	maxFIFOCount     int
 	exceptionStatus  uint8
 	diagnosticData   modbus.DiagnosticData
 	commEventLog     []byte
@@ -37,50 +57,135 @@ type DefaultDataStore struct {
 // NewDefaultDataStore creates a new default data store with the given sizes
 func NewDefaultDataStore(coilCount, discreteInputCount, holdingRegCount, inputRegCount int) *DefaultDataStore {
 	return &DefaultDataStore{
-		coils:            make([]bool, coilCount),
-		discreteInputs:   make([]bool, discreteInputCount),
-		holdingRegisters: make([]uint16, holdingRegCount),
-		inputRegisters:   make([]uint16, inputRegCount),
+		coils:            newDataTable[bool](coilCount),
+		discreteInputs:   newDataTable[bool](discreteInputCount),
+		holdingRegisters: newDataTable[uint16](holdingRegCount),
+		inputRegisters:   newDataTable[uint16](inputRegCount),
 		fileRecords:      make(map[uint16]map[uint16][]uint16),
+		maxFileRecords:   DefaultMaxFileRecords,
+		maxFileRegisters: DefaultMaxFileRegisters,
 		fifoQueues:       make(map[uint16][]uint16),
+		maxFIFOCount:     modbus.MaxFIFOCount,
 		exceptionStatus:  0,
 		diagnosticData:   modbus.DiagnosticData{},
 		commEventLog:     make([]byte, 0, 64),
 	}
 }
 
+// DataStoreOption seeds a DefaultDataStore during construction
+type DataStoreOption func(ds *DefaultDataStore) error
+
+// WithCoils seeds coil values by address
+func WithCoils(values map[modbus.Address]bool) DataStoreOption {
+	return func(ds *DefaultDataStore) error {
+		for address, value := range values {
+			if err := ds.SetCoil(address, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithDiscreteInputs seeds discrete input values by address
+func WithDiscreteInputs(values map[modbus.Address]bool) DataStoreOption {
+	return func(ds *DefaultDataStore) error {
+		for address, value := range values {
+			if err := ds.SetDiscreteInput(address, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithHoldingRegisters seeds holding register values by address
+func WithHoldingRegisters(values map[modbus.Address]uint16) DataStoreOption {
+	return func(ds *DefaultDataStore) error {
+		for address, value := range values {
+			if err := ds.SetHoldingRegister(address, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithInputRegisters seeds input register values by address
+func WithInputRegisters(values map[modbus.Address]uint16) DataStoreOption {
+	return func(ds *DefaultDataStore) error {
+		for address, value := range values {
+			if err := ds.SetInputRegister(address, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithLogicalSize extends the address space of each table beyond its
+// allocated size, up to 65536 addresses. Addresses past the allocation are
+// stored sparsely and read as zero until written, so a device with a few high
+// addresses can be simulated without allocating the whole range. A size
+// smaller than the allocation leaves that table unchanged.
+func WithLogicalSize(coils, discreteInputs, holdingRegs, inputRegs int) DataStoreOption {
+	return func(ds *DefaultDataStore) error {
+		for _, size := range []int{coils, discreteInputs, holdingRegs, inputRegs} {
+			if size > 65536 {
+				return fmt.Errorf("logical size %d exceeds the 65536 address space", size)
+			}
+		}
+
+		ds.mutex.Lock()
+		defer ds.mutex.Unlock()
+		ds.coils.setSize(coils)
+		ds.discreteInputs.setSize(discreteInputs)
+		ds.holdingRegisters.setSize(holdingRegs)
+		ds.inputRegisters.setSize(inputRegs)
+		return nil
+	}
+}
+
+// NewDefaultDataStoreWithInit creates a new default data store with the given
+// sizes and applies each option in order to seed its contents
+func NewDefaultDataStoreWithInit(coilCount, discreteInputCount, holdingRegCount, inputRegCount int, opts ...DataStoreOption) (*DefaultDataStore, error) {
+	ds := NewDefaultDataStore(coilCount, discreteInputCount, holdingRegCount, inputRegCount)
+	for _, opt := range opts {
+		if err := opt(ds); err != nil {
+			return nil, fmt.Errorf("failed to initialize data store: %w", err)
+		}
+	}
+	return ds, nil
+}
+
 // ReadCoils implements modbus.DataStore
 func (ds *DefaultDataStore) ReadCoils(address modbus.Address, quantity modbus.Quantity) ([]bool, error) {
 	ds.mutex.RLock()
 	defer ds.mutex.RUnlock()
 
-	start := int(address)
-	end := start + int(quantity)
-
-	if start < 0 || end > len(ds.coils) {
+	result, ok := ds.coils.read(int(address), int(quantity))
+	if !ok {
 		return nil, modbus.NewModbusError(modbus.FuncCodeReadCoils, modbus.ExceptionCodeIllegalDataAddress,
-			fmt.Sprintf("address range %d-%d out of bounds (0-%d)", start, end-1, len(ds.coils)-1))
+			ds.coils.rangeMessage(int(address), int(quantity)))
 	}
-
-	result := make([]bool, quantity)
-	copy(result, ds.coils[start:end])
 	return result, nil
 }
 
-// WriteCoils implements modbus.DataStore
+// WriteCoils implements modbus.DataStore. The whole range is validated
+// before any coil is changed, so a rejected write leaves the store unchanged.
 func (ds *DefaultDataStore) WriteCoils(address modbus.Address, values []bool) error {
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
 
-	start := int(address)
-	end := start + len(values)
-
-	if start < 0 || end > len(ds.coils) {
+	if !ds.coils.inRange(int(address), len(values)) {
 		return modbus.NewModbusError(modbus.FuncCodeWriteMultipleCoils, modbus.ExceptionCodeIllegalDataAddress,
-			fmt.Sprintf("address range %d-%d out of bounds (0-%d)", start, end-1, len(ds.coils)-1))
+			ds.coils.rangeMessage(int(address), len(values)))
 	}
-
-	copy(ds.coils[start:end], values)
+	if readOnly, ok := ds.coils.firstReadOnly(int(address), len(values)); ok {
+		return modbus.NewModbusError(modbus.FuncCodeWriteMultipleCoils, modbus.ExceptionCodeIllegalDataAddress,
+			fmt.Sprintf("address %d is read-only", readOnly))
+	}
+	ds.coils.write(int(address), values)
 	return nil
 }
 
@@ -89,16 +194,11 @@ func (ds *DefaultDataStore) ReadDiscreteInputs(address modbus.Address, quantity
 	ds.mutex.RLock()
 	defer ds.mutex.RUnlock()
 
-	start := int(address)
-	end := start + int(quantity)
-
-	if start < 0 || end > len(ds.discreteInputs) {
+	result, ok := ds.discreteInputs.read(int(address), int(quantity))
+	if !ok {
 		return nil, modbus.NewModbusError(modbus.FuncCodeReadDiscreteInputs, modbus.ExceptionCodeIllegalDataAddress,
-			fmt.Sprintf("address range %d-%d out of bounds (0-%d)", start, end-1, len(ds.discreteInputs)-1))
+			ds.discreteInputs.rangeMessage(int(address), int(quantity)))
 	}
-
-	result := make([]bool, quantity)
-	copy(result, ds.discreteInputs[start:end])
 	return result, nil
 }
 
@@ -107,33 +207,30 @@ func (ds *DefaultDataStore) ReadHoldingRegisters(address modbus.Address, quantit
 	ds.mutex.RLock()
 	defer ds.mutex.RUnlock()
 
-	start := int(address)
-	end := start + int(quantity)
-
-	if start < 0 || end > len(ds.holdingRegisters) {
+	result, ok := ds.holdingRegisters.read(int(address), int(quantity))
+	if !ok {
 		return nil, modbus.NewModbusError(modbus.FuncCodeReadHoldingRegisters, modbus.ExceptionCodeIllegalDataAddress,
-			fmt.Sprintf("address range %d-%d out of bounds (0-%d)", start, end-1, len(ds.holdingRegisters)-1))
+			ds.holdingRegisters.rangeMessage(int(address), int(quantity)))
 	}
-
-	result := make([]uint16, quantity)
-	copy(result, ds.holdingRegisters[start:end])
 	return result, nil
 }
 
-// WriteHoldingRegisters implements modbus.DataStore
+// WriteHoldingRegisters implements modbus.DataStore. The whole range is
+// validated before any register is changed, so a rejected write leaves the
+// store unchanged.
 func (ds *DefaultDataStore) WriteHoldingRegisters(address modbus.Address, values []uint16) error {
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
 
-	start := int(address)
-	end := start + len(values)
-
-	if start < 0 || end > len(ds.holdingRegisters) {
+	if !ds.holdingRegisters.inRange(int(address), len(values)) {
 		return modbus.NewModbusError(modbus.FuncCodeWriteMultipleRegisters, modbus.ExceptionCodeIllegalDataAddress,
-			fmt.Sprintf("address range %d-%d out of bounds (0-%d)", start, end-1, len(ds.holdingRegisters)-1))
+			ds.holdingRegisters.rangeMessage(int(address), len(values)))
 	}
-
-	copy(ds.holdingRegisters[start:end], values)
+	if readOnly, ok := ds.holdingRegisters.firstReadOnly(int(address), len(values)); ok {
+		return modbus.NewModbusError(modbus.FuncCodeWriteMultipleRegisters, modbus.ExceptionCodeIllegalDataAddress,
+			fmt.Sprintf("address %d is read-only", readOnly))
+	}
+	ds.holdingRegisters.write(int(address), values)
 	return nil
 }
 
@@ -142,29 +239,48 @@ func (ds *DefaultDataStore) ReadInputRegisters(address modbus.Address, quantity
 	ds.mutex.RLock()
 	defer ds.mutex.RUnlock()
 
-	start := int(address)
-	end := start + int(quantity)
-
-	if start < 0 || end > len(ds.inputRegisters) {
+	result, ok := ds.inputRegisters.read(int(address), int(quantity))
+	if !ok {
 		return nil, modbus.NewModbusError(modbus.FuncCodeReadInputRegisters, modbus.ExceptionCodeIllegalDataAddress,
-			fmt.Sprintf("address range %d-%d out of bounds (0-%d)", start, end-1, len(ds.inputRegisters)-1))
+			ds.inputRegisters.rangeMessage(int(address), int(quantity)))
 	}
-
-	result := make([]uint16, quantity)
-	copy(result, ds.inputRegisters[start:end])
 	return result, nil
 }
 
+// SetReadOnlyCoils makes quantity coils starting at address read-only to
+// clients: writes touching any of them fail with IllegalDataAddress and change
+// nothing. SetCoil still updates them.
+func (ds *DefaultDataStore) SetReadOnlyCoils(address modbus.Address, quantity modbus.Quantity) error {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	if !ds.coils.setReadOnly(int(address), int(quantity)) {
+		return fmt.Errorf("read-only coils: %s", ds.coils.rangeMessage(int(address), int(quantity)))
+	}
+	return nil
+}
+
+// SetReadOnlyHoldingRegisters makes quantity holding registers starting at
+// address read-only to clients: writes touching any of them fail with
+// IllegalDataAddress and change nothing. SetHoldingRegister still updates them.
+func (ds *DefaultDataStore) SetReadOnlyHoldingRegisters(address modbus.Address, quantity modbus.Quantity) error {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	if !ds.holdingRegisters.setReadOnly(int(address), int(quantity)) {
+		return fmt.Errorf("read-only holding registers: %s", ds.holdingRegisters.rangeMessage(int(address), int(quantity)))
+	}
+	return nil
+}
+
 // SetCoil sets a single coil value
 func (ds *DefaultDataStore) SetCoil(address modbus.Address, value bool) error {
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
 
-	if int(address) >= len(ds.coils) {
-		return fmt.Errorf("coil address %d out of bounds (0-%d)", address, len(ds.coils)-1)
+	if !ds.coils.write(int(address), []bool{value}) {
+		return fmt.Errorf("coil %s", ds.coils.rangeMessage(int(address), 1))
 	}
-
-	ds.coils[address] = value
 	return nil
 }
 
@@ -173,11 +289,9 @@ func (ds *DefaultDataStore) SetDiscreteInput(address modbus.Address, value bool)
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
 
-	if int(address) >= len(ds.discreteInputs) {
-		return fmt.Errorf("discrete input address %d out of bounds (0-%d)", address, len(ds.discreteInputs)-1)
+	if !ds.discreteInputs.write(int(address), []bool{value}) {
+		return fmt.Errorf("discrete input %s", ds.discreteInputs.rangeMessage(int(address), 1))
 	}
-
-	ds.discreteInputs[address] = value
 	return nil
 }
 
@@ -186,11 +300,9 @@ func (ds *DefaultDataStore) SetHoldingRegister(address modbus.Address, value uin
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
 
-	if int(address) >= len(ds.holdingRegisters) {
-		return fmt.Errorf("holding register address %d out of bounds (0-%d)", address, len(ds.holdingRegisters)-1)
+	if !ds.holdingRegisters.write(int(address), []uint16{value}) {
+		return fmt.Errorf("holding register %s", ds.holdingRegisters.rangeMessage(int(address), 1))
 	}
-
-	ds.holdingRegisters[address] = value
 	return nil
 }
 
@@ -199,11 +311,9 @@ func (ds *DefaultDataStore) SetInputRegister(address modbus.Address, value uint1
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
 
-	if int(address) >= len(ds.inputRegisters) {
-		return fmt.Errorf("input register address %d out of bounds (0-%d)", address, len(ds.inputRegisters)-1)
+	if !ds.inputRegisters.write(int(address), []uint16{value}) {
+		return fmt.Errorf("input register %s", ds.inputRegisters.rangeMessage(int(address), 1))
 	}
-
-	ds.inputRegisters[address] = value
 	return nil
 }
 
@@ -245,17 +355,49 @@ func (ds *DefaultDataStore) ReadFileRecords(records []modbus.FileRecord) ([]modb
 	return result, nil
 }
 
-// WriteFileRecords implements modbus.DataStore
+// WriteFileRecords implements modbus.DataStore. The write is rejected as a
+// whole if any record is invalid or the stored records would exceed the
+// limits set by SetFileRecordLimits.
 func (ds *DefaultDataStore) WriteFileRecords(records []modbus.FileRecord) error {
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
 
+	written := make(map[FileRecordKey]int, len(records))
+	recordCount, registers := ds.fileRecordCount, ds.fileRegisters
+
 	for _, record := range records {
 		if record.ReferenceType != modbus.FileRecordTypeExtended {
 			return modbus.NewModbusError(modbus.FuncCodeWriteFileRecord, modbus.ExceptionCodeIllegalDataValue,
 				fmt.Sprintf("unsupported reference type %d", record.ReferenceType))
 		}
+		if int(record.RecordLength) != len(record.RecordData) {
+			return modbus.NewModbusError(modbus.FuncCodeWriteFileRecord, modbus.ExceptionCodeIllegalDataValue,
+				fmt.Sprintf("record %d: RecordLength %d != data length %d", record.RecordNumber, record.RecordLength, len(record.RecordData)))
+		}
+
+		// Account for the record replacing any stored or earlier written one
+		key := FileRecordKey{record.FileNumber, record.RecordNumber}
+		if n, ok := written[key]; ok {
+			registers -= n
+		} else if existing, ok := ds.fileRecords[record.FileNumber][record.RecordNumber]; ok {
+			registers -= len(existing)
+		} else {
+			recordCount++
+		}
+		written[key] = len(record.RecordData)
+		registers += len(record.RecordData)
+	}
+
+	if ds.maxFileRecords > 0 && recordCount > ds.maxFileRecords {
+		return modbus.NewModbusError(modbus.FuncCodeWriteFileRecord, modbus.ExceptionCodeServerDeviceFailure,
+			fmt.Sprintf("file record storage full: %d records exceeds limit %d", recordCount, ds.maxFileRecords))
+	}
+	if ds.maxFileRegisters > 0 && registers > ds.maxFileRegisters {
+		return modbus.NewModbusError(modbus.FuncCodeWriteFileRecord, modbus.ExceptionCodeServerDeviceFailure,
+			fmt.Sprintf("file record storage full: %d registers exceeds limit %d", registers, ds.maxFileRegisters))
+	}
 
+	for _, record := range records {
 		fileMap, exists := ds.fileRecords[record.FileNumber]
 		if !exists {
 			fileMap = make(map[uint16][]uint16)
@@ -265,10 +407,69 @@ func (ds *DefaultDataStore) WriteFileRecords(records []modbus.FileRecord) error
 		fileMap[record.RecordNumber] = make([]uint16, len(record.RecordData))
 		copy(fileMap[record.RecordNumber], record.RecordData)
 	}
+	ds.fileRecordCount, ds.fileRegisters = recordCount, registers
 
 	return nil
 }
 
+// FileRecordKey identifies a stored file record
+type FileRecordKey struct {
+	FileNumber   uint16
+	RecordNumber uint16
+}
+
+// ListFileRecords returns the keys of all stored file records, sorted by file
+// and record number. The protocol has no way to enumerate file records; this
+// is for inspecting the store, e.g. to check what a client wrote.
+func (ds *DefaultDataStore) ListFileRecords() []FileRecordKey {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	keys := make([]FileRecordKey, 0, ds.fileRecordCount)
+	for file, fileMap := range ds.fileRecords {
+		for record := range fileMap {
+			keys = append(keys, FileRecordKey{FileNumber: file, RecordNumber: record})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].FileNumber != keys[j].FileNumber {
+			return keys[i].FileNumber < keys[j].FileNumber
+		}
+		return keys[i].RecordNumber < keys[j].RecordNumber
+	})
+	return keys
+}
+
+// GetFileRecord returns a copy of the stored record data
+func (ds *DefaultDataStore) GetFileRecord(fileNumber, recordNumber uint16) ([]uint16, bool) {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	data, exists := ds.fileRecords[fileNumber][recordNumber]
+	if !exists {
+		return nil, false
+	}
+	result := make([]uint16, len(data))
+	copy(result, data)
+	return result, true
+}
+
+// SetFileRecordLimits bounds the file records the store holds: at most
+// maxRecords records and maxRegisters registers across all files. A limit of
+// 0 disables that check. Writes that would exceed a limit fail with a
+// ServerDeviceFailure exception; records already stored are kept.
+func (ds *DefaultDataStore) SetFileRecordLimits(maxRecords, maxRegisters int) error {
+	if maxRecords < 0 || maxRegisters < 0 {
+		return fmt.Errorf("invalid file record limits %d records, %d registers", maxRecords, maxRegisters)
+	}
+
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	ds.maxFileRecords = maxRecords
+	ds.maxFileRegisters = maxRegisters
+	return nil
+}
+
 // ReadFIFOQueue implements modbus.DataStore
 func (ds *DefaultDataStore) ReadFIFOQueue(address modbus.Address) ([]uint16, error) {
 	ds.mutex.RLock()
@@ -279,6 +480,10 @@ func (ds *DefaultDataStore) ReadFIFOQueue(address modbus.Address) ([]uint16, err
 		// Return empty queue if not exists
 		return []uint16{}, nil
 	}
+	if len(queue) > ds.maxFIFOCount {
+		return nil, modbus.NewModbusError(modbus.FuncCodeReadFIFOQueue, modbus.ExceptionCodeIllegalDataValue,
+			fmt.Sprintf("FIFO queue size %d exceeds maximum %d", len(queue), ds.maxFIFOCount))
+	}
 
 	// Return a copy of the queue
 	result := make([]uint16, len(queue))
@@ -291,9 +496,9 @@ func (ds *DefaultDataStore) WriteFIFOQueue(address modbus.Address, values []uint
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
 
-	if len(values) > modbus.MaxFIFOCount {
+	if len(values) > ds.maxFIFOCount {
 		return modbus.NewModbusError(modbus.FuncCodeReadFIFOQueue, modbus.ExceptionCodeIllegalDataValue,
-			fmt.Sprintf("FIFO queue size %d exceeds maximum %d", len(values), modbus.MaxFIFOCount))
+			fmt.Sprintf("FIFO queue size %d exceeds maximum %d", len(values), ds.maxFIFOCount))
 	}
 
 	ds.fifoQueues[uint16(address)] = make([]uint16, len(values))
@@ -301,6 +506,20 @@ func (ds *DefaultDataStore) WriteFIFOQueue(address modbus.Address, values []uint
 	return nil
 }
 
+// SetMaxFIFOCount lowers the FIFO queue size limit below the protocol maximum
+// of MaxFIFOCount, to emulate a device with a shorter queue. Queues already
+// longer than n are answered with IllegalDataValue when read.
+func (ds *DefaultDataStore) SetMaxFIFOCount(n int) error {
+	if n < 0 || n > modbus.MaxFIFOCount {
+		return fmt.Errorf("FIFO count %d out of range 0-%d", n, modbus.MaxFIFOCount)
+	}
+
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	ds.maxFIFOCount = n
+	return nil
+}
+
 // ReadExceptionStatus implements modbus.DataStore
 func (ds *DefaultDataStore) ReadExceptionStatus() (uint8, error) {
 	ds.mutex.RLock()
@@ -332,11 +551,7 @@ func (ds *DefaultDataStore) GetDiagnosticData(subFunction uint16, data []byte) (
 		return data, nil
 
 	case modbus.DiagSubReturnDiagRegister:
-		// Return diagnostic register (16-bit value)
-		result := make([]byte, 2)
-		result[0] = 0x00 // Diagnostic register high byte
-		result[1] = 0x00 // Diagnostic register low byte
-		return result, nil
+		return pdu.EncodeUint16(ds.diagnosticData.DiagnosticRegister), nil
 
 	case modbus.DiagSubClearCounters:
 		// Clear all counters and diagnostic register
@@ -367,6 +582,12 @@ func (ds *DefaultDataStore) GetDiagnosticData(subFunction uint16, data []byte) (
 	case modbus.DiagSubReturnBusCharOverrunCount:
 		return pdu.EncodeUint16(ds.diagnosticData.BusCharOverrunCount), nil
 
+	case modbus.DiagSubClearOverrunCounter:
+		// Clear overrun counter and flag
+		ds.diagnosticData.BusCharOverrunCount = 0
+		ds.diagnosticData.DiagnosticRegister &^= modbus.DiagRegCharOverrun
+		return data, nil
+
 	default:
 		return nil, modbus.NewModbusError(modbus.FuncCodeDiagnostic, modbus.ExceptionCodeIllegalFunction,
 			fmt.Sprintf("unsupported diagnostic sub-function %d", subFunction))
@@ -402,7 +623,8 @@ func (ds *DefaultDataStore) GetCommEventLog() (uint16, uint16, uint16, []byte, e
 	return status, eventCount, messageCount, events, nil
 }
 
-// IncrementDiagnosticCounter increments a diagnostic counter (helper method)
+// IncrementDiagnosticCounter increments a diagnostic counter (helper method).
+// Error counters also set the matching bit in the diagnostic register.
 func (ds *DefaultDataStore) IncrementDiagnosticCounter(counter string) {
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
@@ -412,27 +634,79 @@ func (ds *DefaultDataStore) IncrementDiagnosticCounter(counter string) {
 		ds.diagnosticData.BusMessageCount++
 	case "BusCommError":
 		ds.diagnosticData.BusCommErrorCount++
+		ds.diagnosticData.DiagnosticRegister |= modbus.DiagRegCommError
 	case "BusException":
 		ds.diagnosticData.BusExceptionCount++
+		ds.diagnosticData.DiagnosticRegister |= modbus.DiagRegException
 	case "ServerMessage":
 		ds.diagnosticData.ServerMessageCount++
 	case "ServerNoResp":
 		ds.diagnosticData.ServerNoRespCount++
+		ds.diagnosticData.DiagnosticRegister |= modbus.DiagRegNoResponse
 	case "ServerNAK":
 		ds.diagnosticData.ServerNAKCount++
+		ds.diagnosticData.DiagnosticRegister |= modbus.DiagRegNAK
 	case "ServerBusy":
 		ds.diagnosticData.ServerBusyCount++
+		ds.diagnosticData.DiagnosticRegister |= modbus.DiagRegBusy
 	case "BusCharOverrun":
 		ds.diagnosticData.BusCharOverrunCount++
+		ds.diagnosticData.DiagnosticRegister |= modbus.DiagRegCharOverrun
 	}
 }
 
-// ServerRequestHandler implements the RequestHandler interface
+// SetDiagnosticRegisterBits sets device-specific bits in the diagnostic register
+func (ds *DefaultDataStore) SetDiagnosticRegisterBits(bits uint16) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	ds.diagnosticData.DiagnosticRegister |= bits
+}
+
+// GetDiagnosticRegister returns the current diagnostic register value
+func (ds *DefaultDataStore) GetDiagnosticRegister() uint16 {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+	return ds.diagnosticData.DiagnosticRegister
+}
+
+// Reset returns the store to its initial state: all coils, discrete inputs
+// and registers read as zero, file records and FIFO queues are removed, and
+// the exception status, diagnostic data and event log are cleared. Sizes and
+// limits are kept. It is safe to call while a server is using the store.
+func (ds *DefaultDataStore) Reset() {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.coils.reset()
+	ds.discreteInputs.reset()
+	ds.holdingRegisters.reset()
+	ds.inputRegisters.reset()
+	ds.fileRecords = make(map[uint16]map[uint16][]uint16)
+	ds.fileRecordCount = 0
+	ds.fileRegisters = 0
+	ds.fifoQueues = make(map[uint16][]uint16)
+	ds.exceptionStatus = 0
+	ds.diagnosticData = modbus.DiagnosticData{}
+	ds.commEventLog = make([]byte, 0, 64)
+}
+
+// ServerRequestHandler implements the RequestHandler interface. It is safe for
+// concurrent use, so one handler can serve a TCPServer and an RTUServer at once.
 type ServerRequestHandler struct {
-	dataStore  modbus.DataStore
-	deviceInfo *modbus.DeviceIdentification
+	dataStore         modbus.DataStore
+	deviceInfo        *modbus.DeviceIdentification
+	serverID          []byte
+	serverIDData      []byte
+	exceptionObserver ExceptionObserver
+	unknownMEI        modbus.ExceptionCode
+	pooled            bool
+	mutex             sync.RWMutex
 }
 
+// ExceptionObserver is called with the unit ID, request function code and
+// exception code of every exception response produced by the handler
+type ExceptionObserver func(unitID modbus.SlaveID, fc modbus.FunctionCode, ec modbus.ExceptionCode)
+
 // NewServerRequestHandler creates a new server request handler
 func NewServerRequestHandler(dataStore modbus.DataStore) *ServerRequestHandler {
 	return &ServerRequestHandler{
@@ -443,16 +717,87 @@ func NewServerRequestHandler(dataStore modbus.DataStore) *ServerRequestHandler {
 			MajorMinorRevision: "1.0.0",
 			ConformityLevel:    modbus.ConformityLevelBasicStream,
 		},
+		serverID:   []byte("ModbusGo Server v1.0"),
+		unknownMEI: modbus.ExceptionCodeIllegalFunction,
 	}
 }
 
 // SetDeviceIdentification sets the device identification information
 func (h *ServerRequestHandler) SetDeviceIdentification(deviceInfo *modbus.DeviceIdentification) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
 	h.deviceInfo = deviceInfo
 }
 
+// SetServerIDData sets additional device-specific data appended to the
+// Report Server ID response after the server ID and run indicator
+func (h *ServerRequestHandler) SetServerIDData(data []byte) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// Response data is limited to MaxPDUSize minus function code and byte count
+	maxData := modbus.MaxPDUSize - 2 - 1 - len(h.serverID)
+	if len(data) > maxData {
+		return fmt.Errorf("server ID data too large: %d bytes, max %d", len(data), maxData)
+	}
+
+	h.serverIDData = make([]byte, len(data))
+	copy(h.serverIDData, data)
+	return nil
+}
+
+// SetUnknownMEIException sets the exception returned for encapsulated
+// interface requests (function code 0x2B) with an unsupported MEI type. The
+// default is IllegalFunction; some conformance suites expect IllegalDataValue.
+func (h *ServerRequestHandler) SetUnknownMEIException(code modbus.ExceptionCode) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.unknownMEI = code
+}
+
+// SetExceptionObserver sets a function called whenever the handler returns an
+// exception response. Pass nil to remove it.
+func (h *ServerRequestHandler) SetExceptionObserver(observer ExceptionObserver) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.exceptionObserver = observer
+}
+
 // HandleRequest implements transport.RequestHandler
 func (h *ServerRequestHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	response := h.dispatch(req)
+
+	if response != nil && response.IsException() {
+		code, _ := response.GetExceptionCode()
+		switch code {
+		case modbus.ExceptionCodeServerDeviceBusy:
+			h.IncrementDiagnosticCounter("ServerBusy")
+		case modbus.ExceptionCodeNegativeAcknowledge:
+			h.IncrementDiagnosticCounter("ServerNAK")
+		}
+
+		h.mutex.RLock()
+		observer := h.exceptionObserver
+		h.mutex.RUnlock()
+
+		if observer != nil {
+			observer(slaveID, req.FunctionCode, code)
+		}
+	}
+
+	return response
+}
+
+// IncrementDiagnosticCounter increments a diagnostic counter in the data
+// store, if it keeps them. Servers call it for events the handler never sees.
+func (h *ServerRequestHandler) IncrementDiagnosticCounter(counter string) {
+	if c, ok := h.dataStore.(transport.DiagnosticCounter); ok {
+		c.IncrementDiagnosticCounter(counter)
+	}
+}
+
+// dispatch routes a request to the handler for its function code
+func (h *ServerRequestHandler) dispatch(req *pdu.Request) *pdu.Response {
 	switch req.FunctionCode {
 	case modbus.FuncCodeReadCoils:
 		return h.handleReadCoils(req)
@@ -515,12 +860,7 @@ func (h *ServerRequestHandler) handleReadCoils(req *pdu.Request) *pdu.Response {
 		return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeServerDeviceFailure)
 	}
 
-	coilBytes := pdu.EncodeBoolSlice(values)
-	responseData := make([]byte, 1+len(coilBytes))
-	responseData[0] = byte(len(coilBytes))
-	copy(responseData[1:], coilBytes)
-
-	return pdu.NewResponse(req.FunctionCode, responseData)
+	return h.bitsResponse(req.FunctionCode, values)
 }
 
 // handleReadDiscreteInputs handles read discrete inputs request
@@ -541,12 +881,7 @@ func (h *ServerRequestHandler) handleReadDiscreteInputs(req *pdu.Request) *pdu.R
 		return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeServerDeviceFailure)
 	}
 
-	inputBytes := pdu.EncodeBoolSlice(values)
-	responseData := make([]byte, 1+len(inputBytes))
-	responseData[0] = byte(len(inputBytes))
-	copy(responseData[1:], inputBytes)
-
-	return pdu.NewResponse(req.FunctionCode, responseData)
+	return h.bitsResponse(req.FunctionCode, values)
 }
 
 // handleReadHoldingRegisters handles read holding registers request
@@ -567,12 +902,7 @@ func (h *ServerRequestHandler) handleReadHoldingRegisters(req *pdu.Request) *pdu
 		return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeServerDeviceFailure)
 	}
 
-	registerBytes := pdu.EncodeUint16Slice(values)
-	responseData := make([]byte, 1+len(registerBytes))
-	responseData[0] = byte(len(registerBytes))
-	copy(responseData[1:], registerBytes)
-
-	return pdu.NewResponse(req.FunctionCode, responseData)
+	return h.registersResponse(req.FunctionCode, values)
 }
 
 // handleReadInputRegisters handles read input registers request
@@ -593,12 +923,86 @@ func (h *ServerRequestHandler) handleReadInputRegisters(req *pdu.Request) *pdu.R
 		return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeServerDeviceFailure)
 	}
 
-	registerBytes := pdu.EncodeUint16Slice(values)
-	responseData := make([]byte, 1+len(registerBytes))
-	responseData[0] = byte(len(registerBytes))
-	copy(responseData[1:], registerBytes)
+	return h.registersResponse(req.FunctionCode, values)
+}
 
-	return pdu.NewResponse(req.FunctionCode, responseData)
+// pooledResponseSize is the capacity of pooled response data buffers, enough
+// for any read response. ReleaseResponse recognises pooled buffers by this
+// capacity. An unpooled response that happens to be exactly this size, such
+// as a 126-register read, is put in the pool too; that is harmless, since it
+// is a buffer of the pooled size that is no longer in use.
+const pooledResponseSize = modbus.MaxPDUSize
+
+// responsePool holds read responses for handlers with pooling enabled
+var responsePool = sync.Pool{
+	New: func() any {
+		return pdu.NewResponse(0, make([]byte, 0, pooledResponseSize))
+	},
+}
+
+// SetResponseBufferPool makes the read coils, discrete inputs, holding
+// registers and input registers handlers take their responses from a shared
+// pool instead of allocating them. Responses must then be passed to
+// ReleaseResponse once their bytes are written; the TCP and serial servers do
+// this. Disabled by default.
+func (h *ServerRequestHandler) SetResponseBufferPool(enabled bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.pooled = enabled
+}
+
+// ReleaseResponse returns a response taken from the pool to it. Other
+// responses are ignored. The response must not be used afterwards.
+func (h *ServerRequestHandler) ReleaseResponse(response *pdu.Response) {
+	if response == nil || response.PDU == nil || cap(response.Data) != pooledResponseSize {
+		return
+	}
+	switch response.FunctionCode {
+	case modbus.FuncCodeReadCoils, modbus.FuncCodeReadDiscreteInputs,
+		modbus.FuncCodeReadHoldingRegisters, modbus.FuncCodeReadInputRegisters:
+		response.Request = nil
+		responsePool.Put(response)
+	}
+}
+
+// newReadResponse returns a response with size zeroed data bytes, from the
+// pool if enabled
+func (h *ServerRequestHandler) newReadResponse(fc modbus.FunctionCode, size int) *pdu.Response {
+	h.mutex.RLock()
+	pooled := h.pooled
+	h.mutex.RUnlock()
+
+	if !pooled || size > pooledResponseSize {
+		return pdu.NewResponse(fc, make([]byte, size))
+	}
+	response := responsePool.Get().(*pdu.Response)
+	response.FunctionCode = fc
+	response.Data = response.Data[:size]
+	clear(response.Data)
+	return response
+}
+
+// bitsResponse builds a read coils or discrete inputs response
+func (h *ServerRequestHandler) bitsResponse(fc modbus.FunctionCode, values []bool) *pdu.Response {
+	byteCount := (len(values) + 7) / 8
+	response := h.newReadResponse(fc, 1+byteCount)
+	response.Data[0] = byte(byteCount)
+	for i, value := range values {
+		if value {
+			response.Data[1+i/8] |= 1 << (i % 8)
+		}
+	}
+	return response
+}
+
+// registersResponse builds a read holding or input registers response
+func (h *ServerRequestHandler) registersResponse(fc modbus.FunctionCode, values []uint16) *pdu.Response {
+	response := h.newReadResponse(fc, 1+2*len(values))
+	response.Data[0] = byte(2 * len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint16(response.Data[1+2*i:], value)
+	}
+	return response
 }
 
 // handleWriteSingleCoil handles write single coil request
@@ -817,7 +1221,10 @@ func (h *ServerRequestHandler) handleEncapsulatedInterface(req *pdu.Request) *pd
 	case modbus.MEITypeDeviceIdentification:
 		return h.handleReadDeviceIdentification(req)
 	default:
-		return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeIllegalFunction)
+		h.mutex.RLock()
+		code := h.unknownMEI
+		h.mutex.RUnlock()
+		return pdu.NewExceptionResponse(req.FunctionCode, code)
 	}
 }
 
@@ -830,32 +1237,60 @@ func (h *ServerRequestHandler) handleReadDeviceIdentification(req *pdu.Request)
 	readCode := req.Data[1]
 	objectID := req.Data[2]
 
-	// Basic implementation - return basic device info
-	responseData := []byte{
-		modbus.MEITypeDeviceIdentification,
-		readCode,
-		h.deviceInfo.ConformityLevel,
-		0x00, // More follows = false
-		0x00, // Next object ID
-		0x03, // Number of objects (VendorName, ProductCode, MajorMinorRevision)
+	h.mutex.RLock()
+	deviceInfo := h.deviceInfo
+	h.mutex.RUnlock()
+
+	var objects []modbus.DeviceIDObject
+	switch readCode {
+	case modbus.DeviceIDReadBasic, modbus.DeviceIDReadRegular, modbus.DeviceIDReadExtended:
+		objects = deviceInfo.Objects(readCode)
+		// Stream access starts at objectID; an unknown ID restarts at the first object
+		for i, obj := range objects {
+			if obj.ID == objectID {
+				objects = objects[i:]
+				break
+			}
+		}
+	case modbus.DeviceIDReadSpecific:
+		for _, obj := range deviceInfo.Objects(modbus.DeviceIDReadExtended) {
+			if obj.ID == objectID {
+				objects = []modbus.DeviceIDObject{obj}
+				break
+			}
+		}
+		if objects == nil {
+			return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeIllegalDataAddress)
+		}
+	default:
+		return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeIllegalDataValue)
 	}
 
-	// Add VendorName
-	responseData = append(responseData, modbus.DeviceIDVendorName)
-	responseData = append(responseData, byte(len(h.deviceInfo.VendorName)))
-	responseData = append(responseData, []byte(h.deviceInfo.VendorName)...)
-
-	// Add ProductCode
-	responseData = append(responseData, modbus.DeviceIDProductCode)
-	responseData = append(responseData, byte(len(h.deviceInfo.ProductCode)))
-	responseData = append(responseData, []byte(h.deviceInfo.ProductCode)...)
-
-	// Add MajorMinorRevision
-	responseData = append(responseData, modbus.DeviceIDMajorMinorRevision)
-	responseData = append(responseData, byte(len(h.deviceInfo.MajorMinorRevision)))
-	responseData = append(responseData, []byte(h.deviceInfo.MajorMinorRevision)...)
-
-	_ = objectID // For future use with individual access
+	// The response never exceeds limit, so it is allocated once. More
+	// follows, next object ID and number of objects are filled in below.
+	limit := modbus.MaxPDUSize - 1
+	responseData := make([]byte, 6, limit)
+	responseData[0] = modbus.MEITypeDeviceIdentification
+	responseData[1] = readCode
+	responseData[2] = deviceInfo.ConformityLevel
+
+	// Objects that do not fit are left for a follow-up request
+	count := 0
+	for _, obj := range objects {
+		value := obj.Value
+		if count == 0 && len(responseData)+2+len(value) > limit {
+			value = value[:limit-len(responseData)-2]
+		}
+		if len(responseData)+2+len(value) > limit {
+			responseData[3] = 0xFF
+			responseData[4] = obj.ID
+			break
+		}
+		responseData = append(responseData, obj.ID, byte(len(value)))
+		responseData = append(responseData, value...)
+		count++
+	}
+	responseData[5] = byte(count)
 
 	return pdu.NewResponse(req.FunctionCode, responseData)
 }
@@ -943,14 +1378,18 @@ func (h *ServerRequestHandler) handleGetCommEventLog(req *pdu.Request) *pdu.Resp
 
 // handleReportServerID handles report server ID request
 func (h *ServerRequestHandler) handleReportServerID(req *pdu.Request) *pdu.Response {
-	// Basic implementation - return server ID and run indicator status
-	serverID := []byte("ModbusGo Server v1.0")
+	// Return server ID, run indicator status and any additional data
 	runIndicator := byte(0xFF) // 0xFF = ON
 
-	responseData := make([]byte, 2+len(serverID))
-	responseData[0] = byte(1 + len(serverID)) // Byte count
-	responseData[1] = runIndicator
-	copy(responseData[2:], serverID)
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	byteCount := len(h.serverID) + 1 + len(h.serverIDData)
+	responseData := make([]byte, 1+byteCount)
+	responseData[0] = byte(byteCount)
+	copy(responseData[1:], h.serverID)
+	responseData[1+len(h.serverID)] = runIndicator
+	copy(responseData[2+len(h.serverID):], h.serverIDData)
 
 	return pdu.NewResponse(req.FunctionCode, responseData)
 }
@@ -995,21 +1434,25 @@ func (h *ServerRequestHandler) handleReadFileRecord(req *pdu.Request) *pdu.Respo
 	}
 
 	// Build response
-	var responseData []byte
+	size := 0
 	for _, record := range resultRecords {
-		subResp := make([]byte, 1+1+len(record.RecordData)*2)
-		subResp[0] = 1 + byte(len(record.RecordData)*2) // Sub-req length
-		subResp[1] = record.ReferenceType
-		recordBytes := pdu.EncodeUint16Slice(record.RecordData)
-		copy(subResp[2:], recordBytes)
-		responseData = append(responseData, subResp...)
+		size += 2 + 2*len(record.RecordData)
 	}
 
-	fullResponse := make([]byte, 1+len(responseData))
-	fullResponse[0] = byte(len(responseData))
-	copy(fullResponse[1:], responseData)
+	responseData := make([]byte, 1+size)
+	responseData[0] = byte(size)
+	offset = 1
+	for _, record := range resultRecords {
+		responseData[offset] = 1 + byte(len(record.RecordData)*2) // Sub-req length
+		responseData[offset+1] = record.ReferenceType
+		offset += 2
+		for _, value := range record.RecordData {
+			binary.BigEndian.PutUint16(responseData[offset:], value)
+			offset += 2
+		}
+	}
 
-	return pdu.NewResponse(req.FunctionCode, fullResponse)
+	return pdu.NewResponse(req.FunctionCode, responseData)
 }
 
 // handleWriteFileRecord handles write file record request
@@ -1085,6 +1528,8 @@ func (h *ServerRequestHandler) handleReadFIFOQueue(req *pdu.Request) *pdu.Respon
 		return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeServerDeviceFailure)
 	}
 
+	// The queue is limited to MaxFIFOCount values, which a custom DataStore
+	// could otherwise exceed
 	if len(values) > modbus.MaxFIFOCount {
 		return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeIllegalDataValue)
 	}
@@ -1105,3 +1550,14 @@ func NewTCPServer(address string, dataStore modbus.DataStore) (*transport.TCPSer
 	handler := NewServerRequestHandler(dataStore)
 	return transport.NewTCPServer(address, handler), nil
 }
+
+// NewRTUServer creates a new MODBUS RTU server answering as slaveID. To serve
+// the same data over TCP and serial at once, pass one ServerRequestHandler to
+// both transport.NewTCPServer and transport.NewRTUServer.
+func NewRTUServer(config *transport.SerialConfig, slaveID modbus.SlaveID, dataStore modbus.DataStore) (*transport.RTUServer, error) {
+	if config == nil {
+		return nil, fmt.Errorf("serial config is required")
+	}
+	handler := NewServerRequestHandler(dataStore)
+	return transport.NewRTUServer(config, slaveID, handler), nil
+}
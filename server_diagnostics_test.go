@@ -1,10 +1,17 @@
 package modbus
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/adibhanna/modbus-go/modbus"
 	"github.com/adibhanna/modbus-go/pdu"
+	"github.com/adibhanna/modbus-go/transport"
 )
 
 func TestDiagnosticsFunctions(t *testing.T) {
@@ -58,6 +65,49 @@ func TestDiagnosticsFunctions(t *testing.T) {
 		}
 	})
 
+	t.Run("DiagnosticRegister", func(t *testing.T) {
+		ds := NewDefaultDataStore(100, 100, 100, 100)
+		handler := NewServerRequestHandler(ds)
+
+		ds.IncrementDiagnosticCounter("BusCommError")
+		ds.IncrementDiagnosticCounter("BusCharOverrun")
+
+		readRegister := func() uint16 {
+			req := pdu.NewRequest(modbus.FuncCodeDiagnostic,
+				append(pdu.EncodeUint16(modbus.DiagSubReturnDiagRegister), 0x00, 0x00))
+			resp := handler.HandleRequest(1, req)
+			if resp.FunctionCode != modbus.FuncCodeDiagnostic || len(resp.Data) != 4 {
+				t.Fatalf("Unexpected diagnostic register response: %v", resp.Data)
+			}
+			value, _ := pdu.DecodeUint16(resp.Data[2:4])
+			return value
+		}
+
+		expected := modbus.DiagRegCommError | modbus.DiagRegCharOverrun
+		if value := readRegister(); value != expected {
+			t.Errorf("Expected diagnostic register 0x%04X, got 0x%04X", expected, value)
+		}
+		if flags := modbus.DecodeDiagnosticRegister(expected); !reflect.DeepEqual(flags, []string{"CommError", "CharOverrun"}) {
+			t.Errorf("Unexpected decoded flags: %v", flags)
+		}
+		if flags := modbus.DecodeDiagnosticRegisterWith(0x8001, map[uint16]string{0x0001: "Ready"}); !reflect.DeepEqual(flags, []string{"Ready", "bit 15"}) {
+			t.Errorf("Unexpected decoded custom flags: %v", flags)
+		}
+
+		// Clear Overrun Counter and Flag only clears the overrun bit
+		handler.HandleRequest(1, pdu.NewRequest(modbus.FuncCodeDiagnostic,
+			append(pdu.EncodeUint16(modbus.DiagSubClearOverrunCounter), 0x00, 0x00)))
+		if value := readRegister(); value != modbus.DiagRegCommError {
+			t.Errorf("Expected diagnostic register 0x%04X, got 0x%04X", modbus.DiagRegCommError, value)
+		}
+
+		handler.HandleRequest(1, pdu.NewRequest(modbus.FuncCodeDiagnostic,
+			append(pdu.EncodeUint16(modbus.DiagSubClearCounters), 0x00, 0x00)))
+		if value := readRegister(); value != 0 {
+			t.Errorf("Expected cleared diagnostic register, got 0x%04X", value)
+		}
+	})
+
 	t.Run("GetCommEventCounter", func(t *testing.T) {
 		ds := NewDefaultDataStore(100, 100, 100, 100)
 		handler := NewServerRequestHandler(ds)
@@ -153,16 +203,74 @@ func TestDiagnosticsFunctions(t *testing.T) {
 			t.Errorf("Byte count mismatch: %d vs %d", byteCount, len(resp.Data)-1)
 		}
 
-		runIndicator := resp.Data[1]
-		if runIndicator != 0xFF {
+		// Server ID first, then the run indicator
+		serverID := string(resp.Data[1 : len(resp.Data)-1])
+		if runIndicator := resp.Data[len(resp.Data)-1]; runIndicator != 0xFF {
 			t.Errorf("Expected run indicator 0xFF, got 0x%02X", runIndicator)
 		}
-
-		serverID := string(resp.Data[2:])
 		if serverID != "ModbusGo Server v1.0" {
 			t.Errorf("Expected server ID 'ModbusGo Server v1.0', got '%s'", serverID)
 		}
 	})
+
+	t.Run("ReportServerIDAdditionalData", func(t *testing.T) {
+		ds := NewDefaultDataStore(100, 100, 100, 100)
+		handler := NewServerRequestHandler(ds)
+
+		if err := handler.SetServerIDData([]byte{0x01, 0x02, 0x03}); err != nil {
+			t.Fatalf("Failed to set server ID data: %v", err)
+		}
+
+		if err := handler.SetServerIDData(make([]byte, 300)); err == nil {
+			t.Error("Expected error for oversized server ID data")
+		}
+
+		req := pdu.NewRequest(modbus.FuncCodeReportServerID, []byte{})
+		resp := handler.HandleRequest(1, req)
+
+		serverData, err := pdu.ParseReportServerIDResponse(resp)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+
+		report, err := pdu.ParseServerIDReport(serverData, len("ModbusGo Server v1.0"))
+		if err != nil {
+			t.Fatalf("Failed to parse server ID report: %v", err)
+		}
+
+		if !report.RunIndicator {
+			t.Error("Expected run indicator to be ON")
+		}
+		if string(report.ServerID) != "ModbusGo Server v1.0" {
+			t.Errorf("Expected server ID 'ModbusGo Server v1.0', got %q", report.ServerID)
+		}
+		if !bytes.Equal(report.AdditionalData, []byte{0x01, 0x02, 0x03}) {
+			t.Errorf("Expected additional data [1 2 3], got %v", report.AdditionalData)
+		}
+	})
+
+	t.Run("ParseServerIDReport", func(t *testing.T) {
+		// Spec order: server ID, run indicator, additional data
+		report, err := pdu.ParseServerIDReport([]byte{0x2A, 0xFF, 'X'}, 1)
+		if err != nil {
+			t.Fatalf("Failed to parse spec-ordered report: %v", err)
+		}
+		if !bytes.Equal(report.ServerID, []byte{0x2A}) || !report.RunIndicator || string(report.AdditionalData) != "X" {
+			t.Errorf("Unexpected report %+v", report)
+		}
+
+		report, err = pdu.ParseServerIDReport([]byte{0x00, 0x01, 0x00}, 2)
+		if err != nil || report.RunIndicator || len(report.AdditionalData) != 0 {
+			t.Errorf("Expected OFF with no additional data, got %+v, %v", report, err)
+		}
+
+		if _, err := pdu.ParseServerIDReport([]byte{0x2A}, 1); err == nil {
+			t.Error("Expected error for missing run indicator")
+		}
+		if _, err := pdu.ParseServerIDReport([]byte{0x2A, 0x42}, 1); err == nil {
+			t.Error("Expected error for invalid run indicator")
+		}
+	})
 }
 
 func TestFileRecordFunctions(t *testing.T) {
@@ -288,3 +396,95 @@ func TestFIFOQueue(t *testing.T) {
 		}
 	})
 }
+
+// rejectingDataStore answers holding register reads as busy and input
+// register reads with a negative acknowledge
+type rejectingDataStore struct {
+	*DefaultDataStore
+}
+
+func (ds *rejectingDataStore) ReadHoldingRegisters(address modbus.Address, quantity modbus.Quantity) ([]uint16, error) {
+	return nil, modbus.NewModbusError(modbus.FuncCodeReadHoldingRegisters, modbus.ExceptionCodeServerDeviceBusy, "")
+}
+
+func (ds *rejectingDataStore) ReadInputRegisters(address modbus.Address, quantity modbus.Quantity) ([]uint16, error) {
+	return nil, modbus.NewModbusError(modbus.FuncCodeReadInputRegisters, modbus.ExceptionCodeNegativeAcknowledge, "")
+}
+
+func TestDiagnosticCountersTrackedAutomatically(t *testing.T) {
+	ds := &rejectingDataStore{NewDefaultDataStore(10, 10, 10, 10)}
+	handler := NewServerRequestHandler(ds)
+
+	counter := func(subFunction uint16) uint16 {
+		data, err := ds.GetDiagnosticData(subFunction, nil)
+		if err != nil {
+			t.Fatalf("Failed to read counter 0x%04X: %v", subFunction, err)
+		}
+		value, _ := pdu.DecodeUint16(data)
+		return value
+	}
+
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+	handler.HandleRequest(1, req)
+	req, _ = pdu.ReadInputRegistersRequest(0, 1)
+	handler.HandleRequest(1, req)
+	handler.HandleRequest(1, req)
+
+	if got := counter(modbus.DiagSubReturnServerBusyCount); got != 1 {
+		t.Errorf("Expected busy count 1, got %d", got)
+	}
+	if got := counter(modbus.DiagSubReturnServerNAKCount); got != 2 {
+		t.Errorf("Expected NAK count 2, got %d", got)
+	}
+
+	// Broadcasts served over RTU get no response
+	serverSide, line := net.Pipe()
+	defer line.Close()
+	rtuServer := transport.NewRTUServer(nil, 1, handler)
+	if err := rtuServer.Serve(serverSide); err != nil {
+		t.Fatalf("Failed to start RTU server: %v", err)
+	}
+	defer func() { _ = rtuServer.Stop() }()
+
+	write, _ := pdu.WriteSingleRegisterRequest(0, 7)
+	if _, err := line.Write(rtuFrame(modbus.BroadcastAddress, write.Bytes())); err != nil {
+		t.Fatalf("RTU write failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond) // inter-frame silence
+	frame := rtuFrame(1, write.Bytes())
+	if _, err := line.Write(frame); err != nil {
+		t.Fatalf("RTU write failed: %v", err)
+	}
+	if _, err := io.ReadFull(line, make([]byte, len(frame))); err != nil {
+		t.Fatalf("RTU read failed: %v", err)
+	}
+
+	if got := counter(modbus.DiagSubReturnServerNoRespCount); got != 1 {
+		t.Errorf("Expected no-response count 1, got %d", got)
+	}
+}
+
+func TestFileRecordAndDiagnosticDataString(t *testing.T) {
+	record := modbus.FileRecord{ReferenceType: 6, FileNumber: 4, RecordNumber: 1, RecordLength: 2, RecordData: []uint16{0x00FF, 0x1234}}
+	if s := record.String(); s != "FileRecord(file=4, record=1, length=2, data=[00FF 1234])" {
+		t.Errorf("Unexpected FileRecord string %q", s)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"reference_type":6,"file_number":4,"record_number":1,"record_length":2,"record_data":[255,4660]}` {
+		t.Errorf("Unexpected FileRecord JSON %s", data)
+	}
+	var decoded modbus.FileRecord
+	if err := json.Unmarshal(data, &decoded); err != nil || !reflect.DeepEqual(decoded, record) {
+		t.Errorf("Expected round trip to give %v, got %v, %v", record, decoded, err)
+	}
+
+	diag := modbus.DiagnosticData{BusMessageCount: 10, BusExceptionCount: 1, ServerMessageCount: 9, DiagnosticRegister: 0x0004}
+	want := "DiagnosticData(bus=10, commErrors=0, exceptions=1, server=9, noResponse=0, nak=0, busy=0, overrun=0, register=0x0004)"
+	if s := diag.String(); s != want {
+		t.Errorf("Expected %q, got %q", want, s)
+	}
+}
@@ -1,12 +1,29 @@
 package modbus
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/adibhanna/modbus-go/modbus"
 	"github.com/adibhanna/modbus-go/pdu"
+	"github.com/adibhanna/modbus-go/transport"
 )
 
 func TestDefaultDataStore(t *testing.T) {
@@ -130,6 +147,55 @@ func TestDefaultDataStore(t *testing.T) {
 			t.Errorf("Expected %v, got %v", expected, values)
 		}
 	})
+
+	t.Run("Settable", func(t *testing.T) {
+		var store modbus.DataStore = ds
+		settable, ok := store.(modbus.SettableDataStore)
+		if !ok {
+			t.Fatal("Expected DefaultDataStore to implement SettableDataStore")
+		}
+
+		if err := settable.SetDiscreteInput(7, true); err != nil {
+			t.Fatalf("Failed to set discrete input: %v", err)
+		}
+		values, _ := store.ReadDiscreteInputs(7, 1)
+		if !values[0] {
+			t.Error("Expected discrete input 7 to be set")
+		}
+		if err := settable.SetInputRegister(100, 1); err == nil {
+			t.Error("Expected error setting input register out of bounds")
+		}
+	})
+
+	t.Run("WithInit", func(t *testing.T) {
+		seeded, err := NewDefaultDataStoreWithInit(10, 10, 10, 10,
+			WithCoils(map[modbus.Address]bool{3: true}),
+			WithHoldingRegisters(map[modbus.Address]uint16{0: 100, 9: 900}),
+			WithInputRegisters(map[modbus.Address]uint16{1: 42}),
+		)
+		if err != nil {
+			t.Fatalf("Failed to create seeded data store: %v", err)
+		}
+
+		regs, _ := seeded.ReadHoldingRegisters(0, 10)
+		if regs[0] != 100 || regs[9] != 900 {
+			t.Errorf("Expected seeded holding registers 100 and 900, got %v", regs)
+		}
+		coils, _ := seeded.ReadCoils(3, 1)
+		if !coils[0] {
+			t.Error("Expected seeded coil 3 to be ON")
+		}
+		inputs, _ := seeded.ReadInputRegisters(1, 1)
+		if inputs[0] != 42 {
+			t.Errorf("Expected seeded input register 42, got %d", inputs[0])
+		}
+
+		_, err = NewDefaultDataStoreWithInit(10, 10, 10, 10,
+			WithHoldingRegisters(map[modbus.Address]uint16{10: 1}))
+		if err == nil {
+			t.Error("Expected error for out-of-range seed address")
+		}
+	})
 }
 
 func TestServerRequestHandler(t *testing.T) {
@@ -475,6 +541,169 @@ func TestDeviceIdentification(t *testing.T) {
 			t.Errorf("Expected 3 objects, got %d", resp.Data[5])
 		}
 	})
+
+	t.Run("Builder", func(t *testing.T) {
+		if _, err := modbus.NewDeviceIdentification().Basic("Acme", "", "1.0").Build(); err == nil {
+			t.Error("Expected error for missing ProductCode")
+		}
+		if _, err := modbus.NewDeviceIdentification().Basic("Acme", "PM", "1.0").Extended(0x10, "x").Build(); err == nil {
+			t.Error("Expected error for extended object ID below 0x80")
+		}
+
+		info, err := modbus.NewDeviceIdentification().Basic("Acme", "PM", "1.0").Build()
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		if info.ConformityLevel != modbus.ConformityLevelBasicIndividual {
+			t.Errorf("Expected basic conformity level, got 0x%02X", info.ConformityLevel)
+		}
+
+		info, err = modbus.NewDeviceIdentification().
+			Basic("Acme", "PM", "1.0").
+			Regular("", "Power Meter", "", "").
+			Extended(0x81, "serial").
+			Build()
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		if info.ConformityLevel != modbus.ConformityLevelExtendedIndividual {
+			t.Errorf("Expected extended conformity level, got 0x%02X", info.ConformityLevel)
+		}
+
+		h := NewServerRequestHandler(ds)
+		h.SetDeviceIdentification(info)
+
+		req := pdu.NewRequest(modbus.FuncCodeEncapsulatedInterface,
+			[]byte{modbus.MEITypeDeviceIdentification, modbus.DeviceIDReadExtended, 0x00})
+		resp := h.HandleRequest(1, req)
+		if resp.IsException() || resp.Data[5] != 5 {
+			t.Fatalf("Expected 5 objects, got response % X", resp.Bytes())
+		}
+
+		req = pdu.NewRequest(modbus.FuncCodeEncapsulatedInterface,
+			[]byte{modbus.MEITypeDeviceIdentification, modbus.DeviceIDReadSpecific, 0x81})
+		resp = h.HandleRequest(1, req)
+		parsed, _, _, err := pdu.ParseReadDeviceIdentificationResponse(resp)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if parsed.ExtendedObjects[0x81] != "serial" {
+			t.Errorf("Expected extended object 0x81, got %v", parsed.ExtendedObjects)
+		}
+
+		req = pdu.NewRequest(modbus.FuncCodeEncapsulatedInterface,
+			[]byte{modbus.MEITypeDeviceIdentification, modbus.DeviceIDReadSpecific, 0x05})
+		resp = h.HandleRequest(1, req)
+		if code, _ := resp.GetExceptionCode(); code != modbus.ExceptionCodeIllegalDataAddress {
+			t.Errorf("Expected IllegalDataAddress for unset object, got %v", code)
+		}
+	})
+}
+
+func TestConformityLevelString(t *testing.T) {
+	tests := []struct {
+		level uint8
+		want  string
+	}{
+		{modbus.ConformityLevelBasicStream, "Basic (stream)"},
+		{modbus.ConformityLevelRegularStream, "Regular (stream)"},
+		{modbus.ConformityLevelExtendedStream, "Extended (stream)"},
+		{modbus.ConformityLevelBasicIndividual, "Basic (individual)"},
+		{modbus.ConformityLevelRegularIndividual, "Regular (individual)"},
+		{modbus.ConformityLevelExtendedIndividual, "Extended (individual)"},
+		{0x7F, "Unknown(7f)"},
+	}
+
+	for _, tt := range tests {
+		if got := ConformityLevelString(tt.level); got != tt.want {
+			t.Errorf("ConformityLevelString(0x%02X) = %q, want %q", tt.level, got, tt.want)
+		}
+	}
+}
+
+// modulePath returns the module path declared in go.mod
+func modulePath(t *testing.T) string {
+	f, err := os.Open("go.mod")
+	if err != nil {
+		t.Fatalf("Failed to open go.mod: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "module "))
+		}
+	}
+	t.Fatal("No module directive in go.mod")
+	return ""
+}
+
+func TestImportPathConsistency(t *testing.T) {
+	module := modulePath(t)
+	owner := module[:strings.LastIndex(module, "/")+1]
+
+	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && path != "." {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		for _, imp := range file.Imports {
+			importPath, _ := strconv.Unquote(imp.Path.Value)
+			if strings.HasPrefix(importPath, owner) && importPath != module &&
+				!strings.HasPrefix(importPath, module+"/") {
+				t.Errorf("%s imports %q, expected a path under %q", path, importPath, module)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk source tree: %v", err)
+	}
+}
+
+func TestServerEndToEnd(t *testing.T) {
+	// The same DataStore value is wired through ServerRequestHandler into
+	// transport.TCPServer, so the types must be identical across packages
+	var store modbus.DataStore = NewDefaultDataStore(10, 10, 10, 10)
+	var handler transport.RequestHandler = NewServerRequestHandler(store)
+
+	server := transport.NewTCPServer("localhost:15508", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15508")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.WriteSingleRegister(3, 0xBEEF); err != nil {
+		t.Fatalf("Failed to write register: %v", err)
+	}
+
+	values, err := store.ReadHoldingRegisters(3, 1)
+	if err != nil {
+		t.Fatalf("Failed to read store: %v", err)
+	}
+	if values[0] != 0xBEEF {
+		t.Errorf("Expected 0xBEEF in store, got 0x%04X", values[0])
+	}
 }
 
 // Benchmark tests
@@ -521,3 +750,1138 @@ func BenchmarkServerHandleRequest(b *testing.B) {
 		handler.HandleRequest(1, req)
 	}
 }
+
+func BenchmarkServerHandleRequestPooled(b *testing.B) {
+	ds := NewDefaultDataStore(1000, 1000, 1000, 1000)
+	handler := NewServerRequestHandler(ds)
+	handler.SetResponseBufferPool(true)
+
+	reqData := make([]byte, 4)
+	copy(reqData[0:2], pdu.EncodeUint16(0))   // Starting address
+	copy(reqData[2:4], pdu.EncodeUint16(100)) // Quantity
+
+	req := pdu.NewRequest(modbus.FuncCodeReadHoldingRegisters, reqData)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ReleaseResponse(handler.HandleRequest(1, req))
+	}
+}
+
+// blockingHandler never returns until released
+type blockingHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	close(h.started)
+	<-h.release
+	return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeServerDeviceFailure)
+}
+
+func TestServerStopWithBlockedHandler(t *testing.T) {
+	handler := &blockingHandler{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(handler.release)
+
+	server := transport.NewTCPServer("localhost:15514", handler)
+	server.SetDetachHandlers(true)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15514")
+	client.SetRetryCount(0)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	go func() { _, _ = client.ReadHoldingRegisters(0, 1) }()
+	<-handler.started
+
+	if err := server.StopWithTimeout(time.Second); err != nil {
+		t.Errorf("Expected Stop to return despite blocked handler, got %v", err)
+	}
+}
+
+// contextBlockingHandler blocks until the server shuts down
+type contextBlockingHandler struct {
+	started chan struct{}
+}
+
+func (h *contextBlockingHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	return h.HandleRequestContext(context.Background(), slaveID, req)
+}
+
+func (h *contextBlockingHandler) HandleRequestContext(ctx context.Context, slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	close(h.started)
+	<-ctx.Done()
+	return pdu.NewExceptionResponse(req.FunctionCode, modbus.ExceptionCodeServerDeviceFailure)
+}
+
+func TestServerStopWithContextHandler(t *testing.T) {
+	handler := &contextBlockingHandler{started: make(chan struct{})}
+	server := transport.NewTCPServer("localhost:15558", handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15558")
+	client.SetRetryCount(0)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	go func() { _, _ = client.ReadHoldingRegisters(0, 1) }()
+	<-handler.started
+
+	// The handler runs on the connection goroutine and returns on shutdown
+	if err := server.StopWithTimeout(time.Second); err != nil {
+		t.Errorf("Expected Stop to return once the handler saw shutdown, got %v", err)
+	}
+}
+
+func TestCallbackDataStore(t *testing.T) {
+	var written []uint16
+	ds := &CallbackDataStore{
+		ReadHoldingRegistersFunc: func(address modbus.Address, quantity modbus.Quantity) ([]uint16, error) {
+			values := make([]uint16, quantity)
+			for i := range values {
+				values[i] = uint16(address) + uint16(i)
+			}
+			return values, nil
+		},
+		WriteHoldingRegistersFunc: func(address modbus.Address, values []uint16) error {
+			written = append(written, values...)
+			return nil
+		},
+	}
+	handler := NewServerRequestHandler(ds)
+
+	t.Run("ReadHoldingRegisters", func(t *testing.T) {
+		req, _ := pdu.ReadHoldingRegistersRequest(10, 3)
+		resp := handler.HandleRequest(1, req)
+		values, err := pdu.ParseReadHoldingRegistersResponse(resp, 3)
+		if err != nil {
+			t.Fatalf("Failed to read holding registers: %v", err)
+		}
+		if !reflect.DeepEqual(values, []uint16{10, 11, 12}) {
+			t.Errorf("Expected [10 11 12], got %v", values)
+		}
+	})
+
+	t.Run("WriteSingleRegister", func(t *testing.T) {
+		req, _ := pdu.WriteSingleRegisterRequest(5, 0xBEEF)
+		resp := handler.HandleRequest(1, req)
+		if resp.IsException() {
+			t.Fatalf("Unexpected exception response: %v", resp.Data)
+		}
+		if !reflect.DeepEqual(written, []uint16{0xBEEF}) {
+			t.Errorf("Expected write callback with [BEEF], got %X", written)
+		}
+	})
+
+	t.Run("UnsetCallback", func(t *testing.T) {
+		req, _ := pdu.ReadCoilsRequest(0, 1)
+		resp := handler.HandleRequest(1, req)
+		ec, err := resp.GetExceptionCode()
+		if !resp.IsException() || err != nil || ec != modbus.ExceptionCodeIllegalFunction {
+			t.Errorf("Expected IllegalFunction exception, got %v", resp.Data)
+		}
+	})
+}
+
+// rtuFrame builds an RTU request ADU with CRC for the given slave and PDU
+func rtuFrame(slaveID byte, pduBytes []byte) []byte {
+	frame := append([]byte{slaveID}, pduBytes...)
+	crc := uint16(0xFFFF)
+	for _, b := range frame {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return append(frame, byte(crc), byte(crc>>8))
+}
+
+func TestServerSharedTCPAndRTU(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	handler := NewServerRequestHandler(dataStore)
+
+	tcpServer := transport.NewTCPServer("localhost:15515", handler)
+	if err := tcpServer.Start(); err != nil {
+		t.Fatalf("Failed to start TCP server: %v", err)
+	}
+	defer func() { _ = tcpServer.Stop() }()
+
+	serverSide, line := net.Pipe()
+	defer line.Close()
+	rtuServer := transport.NewRTUServer(nil, 1, handler)
+	if err := rtuServer.Serve(serverSide); err != nil {
+		t.Fatalf("Failed to start RTU server: %v", err)
+	}
+	defer func() { _ = rtuServer.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15515")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// Write over TCP and serial concurrently
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			if err := client.WriteSingleRegister(modbus.Address(i), uint16(i+100)); err != nil {
+				t.Errorf("TCP write %d failed: %v", i, err)
+				return
+			}
+		}
+	}()
+
+	for i := 10; i < 20; i++ {
+		req, _ := pdu.WriteSingleRegisterRequest(modbus.Address(i), uint16(i+100))
+		frame := rtuFrame(1, req.Bytes())
+		if _, err := line.Write(frame); err != nil {
+			t.Fatalf("RTU write %d failed: %v", i, err)
+		}
+		echo := make([]byte, len(frame))
+		if _, err := io.ReadFull(line, echo); err != nil {
+			t.Fatalf("RTU response %d failed: %v", i, err)
+		}
+		if !bytes.Equal(echo, frame) {
+			t.Fatalf("Expected echo % X, got % X", frame, echo)
+		}
+	}
+	wg.Wait()
+
+	// Registers written over serial are visible over TCP
+	values, err := client.ReadHoldingRegisters(0, 20)
+	if err != nil {
+		t.Fatalf("Failed to read registers: %v", err)
+	}
+	for i, v := range values {
+		if v != uint16(i+100) {
+			t.Errorf("Register %d: expected %d, got %d", i, i+100, v)
+		}
+	}
+
+	// Frames for other slave IDs get no reply
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+	if _, err := line.Write(rtuFrame(2, req.Bytes())); err != nil {
+		t.Fatalf("RTU write failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond) // inter-frame silence
+	if _, err := line.Write(rtuFrame(1, req.Bytes())); err != nil {
+		t.Fatalf("RTU write failed: %v", err)
+	}
+	resp := make([]byte, 7)
+	if _, err := io.ReadFull(line, resp); err != nil {
+		t.Fatalf("RTU read failed: %v", err)
+	}
+	if resp[0] != 1 || resp[3] != 0 || resp[4] != 100 {
+		t.Errorf("Unexpected RTU response % X", resp)
+	}
+}
+
+func TestRTUServerFraming(t *testing.T) {
+	handler := NewServerRequestHandler(NewDefaultDataStore(10, 10, 10, 10))
+	serverSide, line := net.Pipe()
+	defer line.Close()
+	rtuServer := transport.NewRTUServer(nil, 1, handler)
+	if err := rtuServer.Serve(serverSide); err != nil {
+		t.Fatalf("Failed to start RTU server: %v", err)
+	}
+	defer func() { _ = rtuServer.Stop() }()
+
+	// An unsupported function code is answered with IllegalFunction
+	if _, err := line.Write(rtuFrame(1, []byte{0x55, 0x01, 0x02})); err != nil {
+		t.Fatalf("RTU write failed: %v", err)
+	}
+	resp := make([]byte, 5)
+	if _, err := io.ReadFull(line, resp); err != nil {
+		t.Fatalf("RTU read failed: %v", err)
+	}
+	if want := rtuFrame(1, []byte{0xD5, byte(modbus.ExceptionCodeIllegalFunction)}); !bytes.Equal(resp, want) {
+		t.Errorf("Expected % X, got % X", want, resp)
+	}
+
+	// A frame with a bad CRC is dropped whole, and the next frame is answered
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+	corrupt := rtuFrame(1, req.Bytes())
+	corrupt[len(corrupt)-1] ^= 0xFF
+	if _, err := line.Write(corrupt); err != nil {
+		t.Fatalf("RTU write failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond) // inter-frame silence
+	if _, err := line.Write(rtuFrame(1, req.Bytes())); err != nil {
+		t.Fatalf("RTU write failed: %v", err)
+	}
+	resp = make([]byte, 7)
+	if _, err := io.ReadFull(line, resp); err != nil {
+		t.Fatalf("RTU read failed: %v", err)
+	}
+	if want := rtuFrame(1, []byte{0x03, 0x02, 0x00, 0x00}); !bytes.Equal(resp, want) {
+		t.Errorf("Expected % X, got % X", want, resp)
+	}
+}
+
+func TestServerUnitIDFilter(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	server := transport.NewTCPServer("localhost:15516", NewServerRequestHandler(dataStore))
+	server.SetAllowedUnitIDs(1)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15516")
+	client.SetRetryCount(0)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Errorf("Expected unit ID 1 to be served, got %v", err)
+	}
+
+	client.SetSlaveID(2)
+	if _, err := client.ReadHoldingRegisters(0, 1); !IsGatewayError(err) {
+		t.Errorf("Expected gateway exception for unit ID 2, got %v", err)
+	}
+
+	server.SetDropUnknownUnitIDs(true)
+	if _, err := client.ReadHoldingRegisters(0, 1); err == nil {
+		t.Error("Expected dropped connection for unit ID 2")
+	}
+
+	server.SetAllowedUnitIDs()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Errorf("Expected all unit IDs to be served after clearing filter, got %v", err)
+	}
+}
+
+func TestServerReadFIFOQueueLimits(t *testing.T) {
+	var queue []uint16
+	handler := NewServerRequestHandler(&CallbackDataStore{
+		ReadFIFOQueueFunc: func(address modbus.Address) ([]uint16, error) {
+			return queue, nil
+		},
+	})
+	req := pdu.NewRequest(modbus.FuncCodeReadFIFOQueue, pdu.EncodeUint16(0))
+
+	queue = make([]uint16, modbus.MaxFIFOCount)
+	resp := handler.HandleRequest(1, req)
+	if resp.IsException() {
+		t.Fatalf("Unexpected exception for %d values", len(queue))
+	}
+	if resp.Size() > modbus.MaxPDUSize {
+		t.Errorf("Response size %d exceeds MaxPDUSize", resp.Size())
+	}
+
+	queue = make([]uint16, modbus.MaxFIFOCount+1)
+	resp = handler.HandleRequest(1, req)
+	if code, err := resp.GetExceptionCode(); err != nil || code != modbus.ExceptionCodeIllegalDataValue {
+		t.Errorf("Expected IllegalDataValue for %d values", len(queue))
+	}
+}
+
+func TestDataStoreLogicalSize(t *testing.T) {
+	small := NewDefaultDataStore(10, 10, 10, 10)
+	_, err := small.ReadHoldingRegisters(5, 10)
+	if err == nil || !strings.Contains(err.Error(), "beyond configured store size (0-9)") {
+		t.Errorf("Expected store size error, got %v", err)
+	}
+
+	ds, err := NewDefaultDataStoreWithInit(10, 10, 10, 10,
+		WithLogicalSize(0, 0, 60000, 0),
+		WithHoldingRegisters(map[modbus.Address]uint16{50000: 7}))
+	if err != nil {
+		t.Fatalf("Failed to create data store: %v", err)
+	}
+
+	if err := ds.WriteHoldingRegisters(8, []uint16{1, 2, 3, 4}); err != nil {
+		t.Fatalf("Write spanning the allocation failed: %v", err)
+	}
+	values, err := ds.ReadHoldingRegisters(8, 5)
+	if err != nil || !reflect.DeepEqual(values, []uint16{1, 2, 3, 4, 0}) {
+		t.Errorf("Expected [1 2 3 4 0], got %v (%v)", values, err)
+	}
+	values, err = ds.ReadHoldingRegisters(49999, 3)
+	if err != nil || !reflect.DeepEqual(values, []uint16{0, 7, 0}) {
+		t.Errorf("Expected [0 7 0], got %v (%v)", values, err)
+	}
+
+	_, err = ds.ReadHoldingRegisters(59990, 20)
+	if err == nil || !strings.Contains(err.Error(), "beyond logical address space (0-59999)") {
+		t.Errorf("Expected logical address space error, got %v", err)
+	}
+	if _, err := ds.ReadCoils(10, 1); err == nil {
+		t.Error("Expected coils to keep their allocated size")
+	}
+}
+
+func TestDataStoreMaxFIFOCount(t *testing.T) {
+	ds := NewDefaultDataStore(10, 10, 10, 10)
+	if err := ds.WriteFIFOQueue(0, make([]uint16, 8)); err != nil {
+		t.Fatalf("Failed to write queue: %v", err)
+	}
+
+	if err := ds.SetMaxFIFOCount(modbus.MaxFIFOCount + 1); err == nil {
+		t.Error("Expected error for count above MaxFIFOCount")
+	}
+	if err := ds.SetMaxFIFOCount(4); err != nil {
+		t.Fatalf("SetMaxFIFOCount failed: %v", err)
+	}
+
+	if err := ds.WriteFIFOQueue(1, make([]uint16, 5)); err == nil {
+		t.Error("Expected error writing 5 values with limit 4")
+	}
+	if err := ds.WriteFIFOQueue(1, make([]uint16, 4)); err != nil {
+		t.Errorf("Unexpected error writing 4 values: %v", err)
+	}
+
+	handler := NewServerRequestHandler(ds)
+	resp := handler.HandleRequest(1, pdu.NewRequest(modbus.FuncCodeReadFIFOQueue, pdu.EncodeUint16(0)))
+	if code, err := resp.GetExceptionCode(); err != nil || code != modbus.ExceptionCodeIllegalDataValue {
+		t.Errorf("Expected IllegalDataValue reading queue longer than limit")
+	}
+	resp = handler.HandleRequest(1, pdu.NewRequest(modbus.FuncCodeReadFIFOQueue, pdu.EncodeUint16(1)))
+	if resp.IsException() {
+		t.Errorf("Unexpected exception reading queue within limit")
+	}
+}
+
+func TestServerExceptionObserver(t *testing.T) {
+	handler := NewServerRequestHandler(NewDefaultDataStore(10, 10, 10, 10))
+
+	var gotUnit modbus.SlaveID
+	var gotFC modbus.FunctionCode
+	var gotEC modbus.ExceptionCode
+	calls := 0
+	handler.SetExceptionObserver(func(unitID modbus.SlaveID, fc modbus.FunctionCode, ec modbus.ExceptionCode) {
+		calls++
+		gotUnit, gotFC, gotEC = unitID, fc, ec
+	})
+
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+	handler.HandleRequest(1, req)
+	if calls != 0 {
+		t.Errorf("Expected no observer call for a normal response, got %d", calls)
+	}
+
+	req, _ = pdu.ReadHoldingRegistersRequest(50, 1)
+	handler.HandleRequest(7, req)
+	if calls != 1 {
+		t.Fatalf("Expected 1 observer call, got %d", calls)
+	}
+	if gotUnit != 7 || gotFC != modbus.FuncCodeReadHoldingRegisters || gotEC != modbus.ExceptionCodeIllegalDataAddress {
+		t.Errorf("Unexpected observation: unit %d, fc %v, ec %v", gotUnit, gotFC, gotEC)
+	}
+
+	handler.SetExceptionObserver(nil)
+	handler.HandleRequest(7, req)
+	if calls != 1 {
+		t.Errorf("Expected observer to be removed, got %d calls", calls)
+	}
+}
+
+func TestServerPerConnectionRateLimit(t *testing.T) {
+	dataStore := NewDefaultDataStore(100, 100, 100, 100)
+	server := transport.NewTCPServer("localhost:15517", NewServerRequestHandler(dataStore))
+	server.SetPerConnectionRateLimit(5, 2)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15517")
+	client.SetRetryCount(0)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+			t.Fatalf("Request %d within burst failed: %v", i, err)
+		}
+	}
+
+	_, err := client.ReadHoldingRegisters(0, 1)
+	var modbusErr *ModbusError
+	if !errors.As(err, &modbusErr) || modbusErr.ExceptionCode != modbus.ExceptionCodeServerDeviceBusy {
+		t.Fatalf("Expected ServerDeviceBusy over the limit, got %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Errorf("Expected request to succeed after refill, got %v", err)
+	}
+}
+
+func TestServerUnknownMEIType(t *testing.T) {
+	handler := NewServerRequestHandler(NewDefaultDataStore(10, 10, 10, 10))
+	req := pdu.NewRequest(modbus.FuncCodeEncapsulatedInterface, []byte{modbus.MEITypeCANopenGeneralReference})
+
+	if code, _ := handler.HandleRequest(1, req).GetExceptionCode(); code != modbus.ExceptionCodeIllegalFunction {
+		t.Errorf("Expected IllegalFunction by default, got %v", code)
+	}
+
+	handler.SetUnknownMEIException(modbus.ExceptionCodeIllegalDataValue)
+	if code, _ := handler.HandleRequest(1, req).GetExceptionCode(); code != modbus.ExceptionCodeIllegalDataValue {
+		t.Errorf("Expected configured IllegalDataValue, got %v", code)
+	}
+
+	empty := pdu.NewRequest(modbus.FuncCodeEncapsulatedInterface, nil)
+	if code, _ := handler.HandleRequest(1, empty).GetExceptionCode(); code != modbus.ExceptionCodeIllegalDataValue {
+		t.Errorf("Expected IllegalDataValue for empty request, got %v", code)
+	}
+}
+
+func TestServerDuplicateTransactionID(t *testing.T) {
+	handler := &slowFirstHandler{
+		handler: NewServerRequestHandler(NewDefaultDataStore(10, 10, 10, 10)),
+		delay:   200 * time.Millisecond,
+	}
+	server := transport.NewTCPServer("localhost:15528", handler)
+
+	var duplicates []uint16
+	var mu sync.Mutex
+	server.SetDuplicateTransactionHandler(func(remote net.Addr, transactionID uint16) {
+		mu.Lock()
+		duplicates = append(duplicates, transactionID)
+		mu.Unlock()
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", "localhost:15528")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// Pipeline three requests; the second reuses the first one's transaction ID
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+	var adus []byte
+	for _, txID := range []uint16{7, 7, 8} {
+		header := &transport.MBAPHeader{
+			TransactionID: txID,
+			ProtocolID:    modbus.MBAPProtocolID,
+			Length:        uint16(1 + req.Size()),
+			UnitID:        1,
+		}
+		adus = append(adus, header.EncodeMBAP()...)
+		adus = append(adus, req.Bytes()...)
+	}
+	if _, err := conn.Write(adus); err != nil {
+		t.Fatalf("Failed to write requests: %v", err)
+	}
+
+	// All three are still answered
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	responseSize := modbus.MBAPHeaderSize + 4
+	if _, err := io.ReadFull(conn, make([]byte, 3*responseSize)); err != nil {
+		t.Fatalf("Failed to read responses: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(duplicates) != 1 || duplicates[0] != 7 {
+		t.Errorf("Expected one duplicate of transaction 7, got %v", duplicates)
+	}
+}
+
+func TestCallbackDataStorePending(t *testing.T) {
+	var mutex sync.Mutex
+	var pending bool
+	registers := make([]uint16, 4)
+	done := make(chan struct{})
+
+	ds := &CallbackDataStore{
+		ReadHoldingRegistersFunc: func(address modbus.Address, quantity modbus.Quantity) ([]uint16, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if pending {
+				return nil, ErrBusy
+			}
+			return append([]uint16(nil), registers[address:int(address)+int(quantity)]...), nil
+		},
+		WriteHoldingRegistersFunc: func(address modbus.Address, values []uint16) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if pending {
+				return ErrBusy
+			}
+			pending = true
+			go func() {
+				<-done
+				mutex.Lock()
+				defer mutex.Unlock()
+				copy(registers[address:], values)
+				pending = false
+			}()
+			return fmt.Errorf("programming flash: %w", ErrPending)
+		},
+	}
+	handler := NewServerRequestHandler(ds)
+
+	req, _ := pdu.WriteSingleRegisterRequest(1, 0x1234)
+	resp := handler.HandleRequest(1, req)
+	if code, err := resp.GetExceptionCode(); err != nil || code != modbus.ExceptionCodeAcknowledge {
+		t.Fatalf("Expected Acknowledge, got % X", resp.Bytes())
+	}
+
+	read, _ := pdu.ReadHoldingRegistersRequest(1, 1)
+	resp = handler.HandleRequest(1, read)
+	if code, err := resp.GetExceptionCode(); err != nil || code != modbus.ExceptionCodeServerDeviceBusy {
+		t.Fatalf("Expected ServerDeviceBusy while pending, got % X", resp.Bytes())
+	}
+
+	close(done)
+	deadline := time.Now().Add(time.Second)
+	for {
+		resp = handler.HandleRequest(1, read)
+		if !resp.IsException() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Write never completed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	values, err := pdu.ParseReadHoldingRegistersResponse(resp, 1)
+	if err != nil || values[0] != 0x1234 {
+		t.Errorf("Expected completed write 0x1234, got %v (%v)", values, err)
+	}
+}
+
+func TestDataStoreFileRecordLimits(t *testing.T) {
+	ds := NewDefaultDataStore(0, 0, 0, 0)
+	record := func(number uint16, data ...uint16) modbus.FileRecord {
+		return modbus.FileRecord{
+			ReferenceType: modbus.FileRecordTypeExtended,
+			FileNumber:    1,
+			RecordNumber:  number,
+			RecordLength:  uint16(len(data)),
+			RecordData:    data,
+		}
+	}
+	exceptionCode := func(err error) modbus.ExceptionCode {
+		var modbusErr *modbus.ModbusError
+		if !errors.As(err, &modbusErr) {
+			t.Fatalf("Expected ModbusError, got %v", err)
+		}
+		return modbusErr.ExceptionCode
+	}
+
+	bad := record(0, 1, 2)
+	bad.RecordLength = 3
+	if err := ds.WriteFileRecords([]modbus.FileRecord{bad}); exceptionCode(err) != modbus.ExceptionCodeIllegalDataValue {
+		t.Errorf("Expected IllegalDataValue for length mismatch, got %v", err)
+	}
+
+	if err := ds.SetFileRecordLimits(2, 4); err != nil {
+		t.Fatalf("SetFileRecordLimits failed: %v", err)
+	}
+	if err := ds.WriteFileRecords([]modbus.FileRecord{record(0, 1, 2), record(1, 3)}); err != nil {
+		t.Fatalf("Write within limits failed: %v", err)
+	}
+
+	// Replacing a record only counts the difference
+	if err := ds.WriteFileRecords([]modbus.FileRecord{record(0, 1, 2, 3)}); err != nil {
+		t.Errorf("Replacing a record within limits failed: %v", err)
+	}
+	if err := ds.WriteFileRecords([]modbus.FileRecord{record(2, 1)}); exceptionCode(err) != modbus.ExceptionCodeServerDeviceFailure {
+		t.Errorf("Expected ServerDeviceFailure for too many records, got %v", err)
+	}
+	if err := ds.WriteFileRecords([]modbus.FileRecord{record(1, 1, 2)}); exceptionCode(err) != modbus.ExceptionCodeServerDeviceFailure {
+		t.Errorf("Expected ServerDeviceFailure for too many registers, got %v", err)
+	}
+
+	// A rejected write leaves the store unchanged
+	got, err := ds.ReadFileRecords([]modbus.FileRecord{record(1, 0)})
+	if err != nil || !reflect.DeepEqual(got[0].RecordData, []uint16{3}) {
+		t.Errorf("Expected record 1 unchanged, got %v (%v)", got, err)
+	}
+
+	if err := ds.SetFileRecordLimits(-1, 0); err == nil {
+		t.Error("Expected error for negative limit")
+	}
+}
+
+// sessionHandler counts the requests of one connection
+type sessionHandler struct {
+	transport.RequestHandler
+	requests atomic.Int32
+	closed   atomic.Bool
+}
+
+func (h *sessionHandler) HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response {
+	h.requests.Add(1)
+	return h.RequestHandler.HandleRequest(slaveID, req)
+}
+
+func (h *sessionHandler) Close() error {
+	h.closed.Store(true)
+	return nil
+}
+
+func TestServerHandlerFactory(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, _ := NewTCPServer("localhost:15533", dataStore)
+
+	var mu sync.Mutex
+	var sessions []*sessionHandler
+	server.SetHandlerFactory(func(remote net.Addr) transport.RequestHandler {
+		h := &sessionHandler{RequestHandler: NewServerRequestHandler(dataStore)}
+		mu.Lock()
+		sessions = append(sessions, h)
+		mu.Unlock()
+		return h
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(100 * time.Millisecond)
+
+	first := NewTCPClient("localhost:15533")
+	second := NewTCPClient("localhost:15533")
+	for _, c := range []*Client{first, second} {
+		if err := c.Connect(); err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+	}
+
+	if err := first.WriteSingleRegister(1, 42); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	// The data store is shared between sessions
+	for i := 0; i < 2; i++ {
+		if v, err := second.ReadHoldingRegister(1); err != nil || v != 42 {
+			t.Fatalf("Expected 42 from the second session, got %d (%v)", v, err)
+		}
+	}
+
+	mu.Lock()
+	if len(sessions) != 2 {
+		mu.Unlock()
+		t.Fatalf("Expected 2 sessions, got %d", len(sessions))
+	}
+	counts := []int32{sessions[0].requests.Load(), sessions[1].requests.Load()}
+	mu.Unlock()
+	if counts[0]+counts[1] != 3 || (counts[0] != 1 && counts[1] != 1) {
+		t.Errorf("Expected 1 and 2 requests per session, got %v", counts)
+	}
+
+	_ = first.Close()
+	_ = second.Close()
+	deadline := time.Now().Add(time.Second)
+	for !sessions[0].closed.Load() || !sessions[1].closed.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected session handlers to be closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDataStoreReset(t *testing.T) {
+	ds, err := NewDefaultDataStoreWithInit(10, 10, 10, 10, WithLogicalSize(100, 100, 100, 100))
+	if err != nil {
+		t.Fatalf("Failed to create data store: %v", err)
+	}
+	_ = ds.SetCoil(1, true)
+	_ = ds.SetDiscreteInput(2, true)
+	_ = ds.SetHoldingRegister(3, 0x1234)
+	_ = ds.SetHoldingRegister(50, 0x5678)
+	_ = ds.SetInputRegister(4, 0x9ABC)
+	_ = ds.WriteFIFOQueue(0, []uint16{1, 2})
+	_ = ds.WriteFileRecords([]modbus.FileRecord{{
+		ReferenceType: modbus.FileRecordTypeExtended,
+		FileNumber:    1,
+		RecordLength:  1,
+		RecordData:    []uint16{7},
+	}})
+	ds.SetExceptionStatus(0x55)
+	ds.IncrementDiagnosticCounter("ServerBusy")
+
+	ds.Reset()
+
+	if coils, _ := ds.ReadCoils(0, 10); !reflect.DeepEqual(coils, make([]bool, 10)) {
+		t.Errorf("Expected coils cleared, got %v", coils)
+	}
+	if inputs, _ := ds.ReadDiscreteInputs(0, 10); !reflect.DeepEqual(inputs, make([]bool, 10)) {
+		t.Errorf("Expected discrete inputs cleared, got %v", inputs)
+	}
+	if regs, _ := ds.ReadHoldingRegisters(0, 100); !reflect.DeepEqual(regs, make([]uint16, 100)) {
+		t.Errorf("Expected holding registers cleared, got %v", regs)
+	}
+	if regs, _ := ds.ReadInputRegisters(0, 10); !reflect.DeepEqual(regs, make([]uint16, 10)) {
+		t.Errorf("Expected input registers cleared, got %v", regs)
+	}
+	if queue, _ := ds.ReadFIFOQueue(0); len(queue) != 0 {
+		t.Errorf("Expected FIFO queue cleared, got %v", queue)
+	}
+	if _, err := ds.ReadFileRecords([]modbus.FileRecord{{ReferenceType: modbus.FileRecordTypeExtended, FileNumber: 1, RecordLength: 1}}); err == nil {
+		t.Error("Expected file records cleared")
+	}
+	if status, _ := ds.ReadExceptionStatus(); status != 0 {
+		t.Errorf("Expected exception status cleared, got 0x%02X", status)
+	}
+	if reg := ds.GetDiagnosticRegister(); reg != 0 {
+		t.Errorf("Expected diagnostic register cleared, got 0x%04X", reg)
+	}
+
+	// Sizes are kept
+	if err := ds.SetHoldingRegister(99, 1); err != nil {
+		t.Errorf("Expected logical size kept after reset: %v", err)
+	}
+}
+
+func TestDataStoreReadOnlyRanges(t *testing.T) {
+	ds := NewDefaultDataStore(10, 10, 10, 10)
+	if err := ds.SetReadOnlyHoldingRegisters(5, 2); err != nil {
+		t.Fatalf("SetReadOnlyHoldingRegisters failed: %v", err)
+	}
+	if err := ds.SetReadOnlyCoils(8, 2); err != nil {
+		t.Fatalf("SetReadOnlyCoils failed: %v", err)
+	}
+	if err := ds.SetReadOnlyHoldingRegisters(9, 2); err == nil {
+		t.Error("Expected error for read-only range beyond the store")
+	}
+
+	// A write overlapping the read-only range changes nothing
+	err := ds.WriteHoldingRegisters(3, []uint16{1, 2, 3, 4, 5})
+	var modbusErr *modbus.ModbusError
+	if !errors.As(err, &modbusErr) || modbusErr.ExceptionCode != modbus.ExceptionCodeIllegalDataAddress ||
+		!strings.Contains(err.Error(), "address 5 is read-only") {
+		t.Errorf("Expected read-only IllegalDataAddress, got %v", err)
+	}
+	if regs, _ := ds.ReadHoldingRegisters(0, 10); !reflect.DeepEqual(regs, make([]uint16, 10)) {
+		t.Errorf("Expected registers unchanged, got %v", regs)
+	}
+	if err := ds.WriteCoils(6, []bool{true, true, true}); err == nil {
+		t.Error("Expected error writing read-only coils")
+	}
+	if coils, _ := ds.ReadCoils(0, 10); !reflect.DeepEqual(coils, make([]bool, 10)) {
+		t.Errorf("Expected coils unchanged, got %v", coils)
+	}
+
+	if err := ds.WriteHoldingRegisters(0, []uint16{1, 2, 3, 4, 5}); err != nil {
+		t.Errorf("Write beside the read-only range failed: %v", err)
+	}
+	if err := ds.SetHoldingRegister(5, 42); err != nil {
+		t.Errorf("SetHoldingRegister on a read-only register failed: %v", err)
+	}
+
+	// Mask write goes through the same check
+	handler := NewServerRequestHandler(ds)
+	req, _ := pdu.MaskWriteRegisterRequest(6, 0x0000, 0xFFFF)
+	resp := handler.HandleRequest(1, req)
+	if code, err := resp.GetExceptionCode(); err != nil || code != modbus.ExceptionCodeIllegalDataAddress {
+		t.Errorf("Expected IllegalDataAddress for mask write, got % X", resp.Bytes())
+	}
+}
+
+func TestDataStoreListFileRecords(t *testing.T) {
+	ds := NewDefaultDataStore(0, 0, 0, 0)
+	if keys := ds.ListFileRecords(); len(keys) != 0 {
+		t.Errorf("Expected no records, got %v", keys)
+	}
+
+	var records []modbus.FileRecord
+	for _, key := range []FileRecordKey{{2, 1}, {1, 7}, {2, 0}, {1, 3}} {
+		records = append(records, modbus.FileRecord{
+			ReferenceType: modbus.FileRecordTypeExtended,
+			FileNumber:    key.FileNumber,
+			RecordNumber:  key.RecordNumber,
+			RecordLength:  1,
+			RecordData:    []uint16{key.FileNumber*100 + key.RecordNumber},
+		})
+	}
+	if err := ds.WriteFileRecords(records); err != nil {
+		t.Fatalf("WriteFileRecords failed: %v", err)
+	}
+
+	keys := ds.ListFileRecords()
+	want := []FileRecordKey{{1, 3}, {1, 7}, {2, 0}, {2, 1}}
+	if len(keys) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, keys)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("Key %d: expected %v, got %v", i, want[i], keys[i])
+		}
+		data, ok := ds.GetFileRecord(keys[i].FileNumber, keys[i].RecordNumber)
+		if !ok || len(data) != 1 || data[0] != want[i].FileNumber*100+want[i].RecordNumber {
+			t.Errorf("Record %v: unexpected data %v", keys[i], data)
+		}
+	}
+
+	if _, ok := ds.GetFileRecord(3, 0); ok {
+		t.Error("Expected missing record")
+	}
+}
+
+func TestServerResponseBufferPool(t *testing.T) {
+	ds := NewDefaultDataStore(100, 100, 100, 100)
+	handler := NewServerRequestHandler(ds)
+	handler.SetResponseBufferPool(true)
+
+	for i := 0; i < 16; i++ {
+		ds.SetCoil(modbus.Address(i), true)
+	}
+	_ = ds.SetHoldingRegister(0, 0x1234)
+
+	readCoils := pdu.NewRequest(modbus.FuncCodeReadCoils, []byte{0, 0, 0, 10})
+	resp := handler.HandleRequest(1, readCoils)
+	if !bytes.Equal(resp.Data, []byte{0x02, 0xFF, 0x03}) {
+		t.Fatalf("Unexpected coils response % X", resp.Data)
+	}
+	handler.ReleaseResponse(resp)
+
+	// A reused buffer must not leak the previous response's bits
+	resp = handler.HandleRequest(1, pdu.NewRequest(modbus.FuncCodeReadCoils, []byte{0, 20, 0, 10}))
+	if !bytes.Equal(resp.Data, []byte{0x02, 0x00, 0x00}) {
+		t.Errorf("Unexpected coils response % X", resp.Data)
+	}
+	handler.ReleaseResponse(resp)
+
+	resp = handler.HandleRequest(1, pdu.NewRequest(modbus.FuncCodeReadHoldingRegisters, []byte{0, 0, 0, 2}))
+	if !bytes.Equal(resp.Data, []byte{0x04, 0x12, 0x34, 0x00, 0x00}) {
+		t.Errorf("Unexpected registers response % X", resp.Data)
+	}
+	handler.ReleaseResponse(resp)
+
+	// Exception responses are not pooled and are ignored on release
+	resp = handler.HandleRequest(1, pdu.NewRequest(modbus.FuncCodeReadHoldingRegisters, []byte{0, 200, 0, 1}))
+	if !resp.IsException() {
+		t.Errorf("Expected exception response, got % X", resp.Data)
+	}
+	handler.ReleaseResponse(resp)
+}
+
+func TestServerRestartRebinds(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, _ := NewTCPServer("localhost:15540", dataStore)
+
+	var controlled atomic.Int32
+	server.SetListenControl(func(network, address string, c syscall.RawConn) error {
+		controlled.Add(1)
+		return nil
+	})
+
+	client := NewTCPClient("localhost:15540")
+	client.SetAutoReconnect(true)
+	defer client.Close()
+
+	// Stopping closes the server side of the connection first, leaving it in
+	// TIME_WAIT; the restart must still bind the port
+	for run := 1; run <= 2; run++ {
+		if err := server.Start(); err != nil {
+			t.Fatalf("Run %d: failed to start server: %v", run, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+			t.Fatalf("Run %d: read failed: %v", run, err)
+		}
+		if err := server.Stop(); err != nil {
+			t.Fatalf("Run %d: failed to stop server: %v", run, err)
+		}
+	}
+
+	if controlled.Load() != 2 {
+		t.Errorf("Expected listen control to run for each start, ran %d times", controlled.Load())
+	}
+}
+
+func TestServerConcurrentLifecycle(t *testing.T) {
+	dataStore := NewDefaultDataStore(10, 10, 10, 10)
+	server, _ := NewTCPServer("localhost:15541", dataStore)
+	defer func() { _ = server.Stop() }()
+
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	if err := server.Start(); !errors.Is(err, transport.ErrServerRunning) {
+		t.Errorf("Expected ErrServerRunning, got %v", err)
+	}
+
+	// Any interleaving of Start, Stop and Restart either succeeds or reports
+	// the state that blocked it
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				var err error
+				switch (g + i) % 3 {
+				case 0:
+					err = server.Start()
+				case 1:
+					err = server.Stop()
+				case 2:
+					err = server.Restart()
+				}
+				if err != nil && !errors.Is(err, transport.ErrServerRunning) &&
+					!errors.Is(err, transport.ErrServerStarting) && !errors.Is(err, transport.ErrServerStopping) {
+					t.Errorf("Unexpected lifecycle error: %v", err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Failed to stop server: %v", err)
+	}
+	if server.IsRunning() {
+		t.Fatal("Expected server to be stopped")
+	}
+	if err := server.Restart(); err != nil {
+		t.Fatalf("Failed to restart server: %v", err)
+	}
+
+	client := NewTCPClient("localhost:15541")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Errorf("Read after restart failed: %v", err)
+	}
+}
+
+func TestEchoHandler(t *testing.T) {
+	logger := &recordingLogger{}
+	server := transport.NewTCPServer("localhost:15545", NewServerRequestHandler(&EchoDataStore{Logger: logger}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15545")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	regs, err := client.ReadHoldingRegisters(100, 3)
+	if err != nil || !reflect.DeepEqual(regs, []uint16{100, 101, 102}) {
+		t.Errorf("Expected [100 101 102], got %v, %v", regs, err)
+	}
+	regs, err = client.ReadInputRegisters(7, 1)
+	if err != nil || regs[0] != 7 {
+		t.Errorf("Expected [7], got %v, %v", regs, err)
+	}
+	coils, err := client.ReadCoils(3, 3)
+	if err != nil || !reflect.DeepEqual(coils, []bool{true, false, true}) {
+		t.Errorf("Expected [true false true], got %v, %v", coils, err)
+	}
+
+	if err := client.WriteMultipleRegisters(10, []uint16{1, 2}); err != nil {
+		t.Errorf("Expected write to be accepted, got %v", err)
+	}
+	if err := client.WriteSingleCoil(4, true); err != nil {
+		t.Errorf("Expected write to be accepted, got %v", err)
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	want := []string{"echo: write holding registers at 10: [1 2]", "echo: write coils at 4: [true]"}
+	if !reflect.DeepEqual(logger.lines, want) {
+		t.Errorf("Expected log %q, got %q", want, logger.lines)
+	}
+}
+
+func TestServerListenAndServe(t *testing.T) {
+	server := transport.NewTCPServer("localhost:15546", NewEchoHandler())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.ListenAndServe(ctx)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := NewTCPClient("localhost:15546")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Errorf("Read while serving failed: %v", err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected clean shutdown, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ListenAndServe did not return after cancel")
+	}
+	if server.IsRunning() {
+		t.Error("Expected server to be stopped")
+	}
+
+	// A direct Stop also ends ListenAndServe
+	go func() {
+		done <- server.ListenAndServe(context.Background())
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Failed to stop server: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected clean shutdown, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ListenAndServe did not return after Stop")
+	}
+
+	// Start errors are returned immediately
+	busy := transport.NewTCPServer("localhost:15546", NewEchoHandler())
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+	if err := busy.ListenAndServe(context.Background()); err == nil {
+		t.Error("Expected error listening on a bound address")
+	}
+}
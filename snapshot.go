@@ -0,0 +1,169 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+// SnapshotRange is a block of consecutive addresses. Quantity may exceed the
+// per-request limit; SnapshotAll splits it into as few reads as possible.
+type SnapshotRange struct {
+	Address  modbus.Address
+	Quantity int
+}
+
+// SnapshotSpec selects the ranges of each table read by SnapshotAll. Tables
+// with no ranges are not read.
+type SnapshotSpec struct {
+	Coils            []SnapshotRange
+	DiscreteInputs   []SnapshotRange
+	HoldingRegisters []SnapshotRange
+	InputRegisters   []SnapshotRange
+}
+
+// Snapshot holds the values read by SnapshotAll, keyed by address. Addresses
+// whose read failed are absent.
+type Snapshot struct {
+	Coils            map[modbus.Address]bool
+	DiscreteInputs   map[modbus.Address]bool
+	HoldingRegisters map[modbus.Address]uint16
+	InputRegisters   map[modbus.Address]uint16
+}
+
+// SnapshotAll reads every range in spec and returns the values collected.
+// Overlapping and adjacent ranges of a table are merged and read in chunks of
+// the protocol maximum, or the limit found by DiscoverMaxReadQuantity. A failed
+// read does not stop the snapshot: the partial snapshot is returned together
+// with all read errors joined.
+func (c *Client) SnapshotAll(spec SnapshotSpec) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		Coils:            make(map[modbus.Address]bool),
+		DiscreteInputs:   make(map[modbus.Address]bool),
+		HoldingRegisters: make(map[modbus.Address]uint16),
+		InputRegisters:   make(map[modbus.Address]uint16),
+	}
+
+	var errs []error
+	collect := func(table string, ranges []SnapshotRange, max int, read func(address modbus.Address, quantity modbus.Quantity) error) {
+		merged, err := mergeSnapshotRanges(ranges)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", table, err))
+			return
+		}
+		for _, r := range merged {
+			for offset := 0; offset < r.Quantity; offset += max {
+				quantity := r.Quantity - offset
+				if quantity > max {
+					quantity = max
+				}
+				address := modbus.Address(int(r.Address) + offset)
+				if err := read(address, modbus.Quantity(quantity)); err != nil {
+					errs = append(errs, fmt.Errorf("failed to read %d %s at address %d: %w", quantity, table, address, err))
+				}
+			}
+		}
+	}
+
+	collect("coils", spec.Coils, c.maxReadQuantity(modbus.FuncCodeReadCoils), func(address modbus.Address, quantity modbus.Quantity) error {
+		values, err := c.ReadCoils(address, quantity)
+		for i, v := range values {
+			snapshot.Coils[address+modbus.Address(i)] = v
+		}
+		return err
+	})
+	collect("discrete inputs", spec.DiscreteInputs, c.maxReadQuantity(modbus.FuncCodeReadDiscreteInputs), func(address modbus.Address, quantity modbus.Quantity) error {
+		values, err := c.ReadDiscreteInputs(address, quantity)
+		for i, v := range values {
+			snapshot.DiscreteInputs[address+modbus.Address(i)] = v
+		}
+		return err
+	})
+	collect("holding registers", spec.HoldingRegisters, c.maxReadQuantity(modbus.FuncCodeReadHoldingRegisters), func(address modbus.Address, quantity modbus.Quantity) error {
+		values, err := c.ReadHoldingRegisters(address, quantity)
+		for i, v := range values {
+			snapshot.HoldingRegisters[address+modbus.Address(i)] = v
+		}
+		return err
+	})
+	collect("input registers", spec.InputRegisters, c.maxReadQuantity(modbus.FuncCodeReadInputRegisters), func(address modbus.Address, quantity modbus.Quantity) error {
+		values, err := c.ReadInputRegisters(address, quantity)
+		for i, v := range values {
+			snapshot.InputRegisters[address+modbus.Address(i)] = v
+		}
+		return err
+	})
+
+	return snapshot, errors.Join(errs...)
+}
+
+// mergeSnapshotRanges validates ranges and merges those that overlap or touch
+func mergeSnapshotRanges(ranges []SnapshotRange) ([]SnapshotRange, error) {
+	sorted := make([]SnapshotRange, 0, len(ranges))
+	for _, r := range ranges {
+		if r.Quantity <= 0 {
+			return nil, fmt.Errorf("invalid quantity %d at address %d", r.Quantity, r.Address)
+		}
+		if int(r.Address)+r.Quantity > 65536 {
+			return nil, fmt.Errorf("range at address %d with quantity %d exceeds the address space", r.Address, r.Quantity)
+		}
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Address < sorted[j].Address })
+
+	var merged []SnapshotRange
+	for _, r := range sorted {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			lastEnd := int(last.Address) + last.Quantity
+			if int(r.Address) <= lastEnd {
+				if end := int(r.Address) + r.Quantity; end > lastEnd {
+					last.Quantity = end - int(last.Address)
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged, nil
+}
+
+// InputPoll holds the results of PollInputs
+type InputPoll struct {
+	DiscreteInputs []bool
+	InputRegisters []uint16
+}
+
+// PollInputs reads a block of discrete inputs and a block of input registers,
+// the usual poll of a device with alarms in one table and measurements in the
+// other. The two tables cannot share a request, so both reads are issued
+// concurrently; transports that answer one request at a time serialize them.
+// If either read fails the other's result is still returned, together with
+// the errors joined.
+func (c *Client) PollInputs(diAddress modbus.Address, diQuantity modbus.Quantity, irAddress modbus.Address, irQuantity modbus.Quantity) (*InputPoll, error) {
+	poll := &InputPoll{}
+	var diErr, irErr error
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		poll.DiscreteInputs, diErr = c.ReadDiscreteInputs(diAddress, diQuantity)
+	}()
+	go func() {
+		defer wg.Done()
+		poll.InputRegisters, irErr = c.ReadInputRegisters(irAddress, irQuantity)
+	}()
+	wg.Wait()
+
+	if diErr != nil {
+		diErr = fmt.Errorf("failed to read %d discrete inputs at address %d: %w", diQuantity, diAddress, diErr)
+	}
+	if irErr != nil {
+		irErr = fmt.Errorf("failed to read %d input registers at address %d: %w", irQuantity, irAddress, irErr)
+	}
+	return poll, errors.Join(diErr, irErr)
+}
@@ -0,0 +1,47 @@
+package modbus
+
+import (
+	"fmt"
+
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+// RegisterChunkFunc receives one chunk of registers read by a streaming read,
+// starting at address. Returning an error stops the stream.
+type RegisterChunkFunc func(address modbus.Address, values []uint16) error
+
+// StreamHoldingRegisters reads holding registers from start through end
+// (inclusive) in chunks of at most chunkSize registers, invoking callback for
+// each chunk as it arrives. If chunkSize is 0 or exceeds the read limit,
+// MaxReadHoldingRegs or the limit found by DiscoverMaxReadQuantity is used.
+// Streaming stops at the first read error or when callback returns an error,
+// which is returned unchanged.
+func (c *Client) StreamHoldingRegisters(start, end modbus.Address, chunkSize modbus.Quantity, callback RegisterChunkFunc) error {
+	if end < start {
+		return fmt.Errorf("end address %d is before start address %d", end, start)
+	}
+	if callback == nil {
+		return fmt.Errorf("callback must not be nil")
+	}
+	if limit := modbus.Quantity(c.maxReadQuantity(modbus.FuncCodeReadHoldingRegisters)); chunkSize == 0 || chunkSize > limit {
+		chunkSize = limit
+	}
+
+	for address := int(start); address <= int(end); address += int(chunkSize) {
+		quantity := chunkSize
+		if remaining := int(end) - address + 1; remaining < int(quantity) {
+			quantity = modbus.Quantity(remaining)
+		}
+
+		values, err := c.ReadHoldingRegisters(modbus.Address(address), quantity)
+		if err != nil {
+			return fmt.Errorf("failed to read %d registers at address %d: %w", quantity, address, err)
+		}
+
+		if err := callback(modbus.Address(address), values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+// SunSpec marker and end-of-chain values
+const (
+	// SunSpecMarker is the "SunS" identifier at the base address, as two registers
+	SunSpecMarker uint32 = 0x53756E53
+	// SunSpecEndModelID marks the end of the model chain
+	SunSpecEndModelID uint16 = 0xFFFF
+)
+
+// SunSpecBaseAddresses are the standard base addresses of a SunSpec map, in
+// the order DiscoverSunSpec tries them
+var SunSpecBaseAddresses = []modbus.Address{40000, 50000, 0}
+
+// ErrSunSpecNotFound is returned when no SunSpec marker is found
+var ErrSunSpecNotFound = errors.New("SunSpec marker not found")
+
+// SunSpecModel is one model in a SunSpec map. Address is the register holding
+// the model ID; SunSpec point offsets are relative to it, so the model's data
+// starts at Address+2.
+type SunSpecModel struct {
+	ID      uint16
+	Address modbus.Address
+	Length  uint16
+}
+
+// SunSpecDevice is the model chain found by DiscoverSunSpec
+type SunSpecDevice struct {
+	BaseAddress modbus.Address
+	Models      []SunSpecModel
+}
+
+// Model returns the first model with the given ID
+func (d *SunSpecDevice) Model(id uint16) (SunSpecModel, bool) {
+	for _, m := range d.Models {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return SunSpecModel{}, false
+}
+
+// DiscoverSunSpec reads the SunSpec marker at baseAddress and walks the chain
+// of model headers until the end model. A baseAddress of 0 tries each of
+// SunSpecBaseAddresses in turn; a base is skipped if the marker is absent or
+// the device answers the read with an exception.
+func DiscoverSunSpec(client *Client, baseAddress modbus.Address) (*SunSpecDevice, error) {
+	bases := []modbus.Address{baseAddress}
+	if baseAddress == 0 {
+		bases = SunSpecBaseAddresses
+	}
+
+	for _, base := range bases {
+		found, err := readSunSpecMarker(client, base)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return walkSunSpecModels(client, base)
+		}
+	}
+	return nil, ErrSunSpecNotFound
+}
+
+// readSunSpecMarker reports whether the SunSpec marker is at base. Exception
+// responses mean no marker; other errors are returned.
+func readSunSpecMarker(client *Client, base modbus.Address) (bool, error) {
+	regs, err := client.ReadHoldingRegisters(base, 2)
+	if err != nil {
+		var modbusErr *modbus.ModbusError
+		if errors.As(err, &modbusErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read SunSpec marker at address %d: %w", base, err)
+	}
+	return uint32(regs[0])<<16|uint32(regs[1]) == SunSpecMarker, nil
+}
+
+// walkSunSpecModels reads the model headers following the marker at base
+func walkSunSpecModels(client *Client, base modbus.Address) (*SunSpecDevice, error) {
+	device := &SunSpecDevice{BaseAddress: base}
+
+	address := int(base) + 2
+	for address+2 <= 65536 {
+		header, err := client.ReadHoldingRegisters(modbus.Address(address), 2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SunSpec model header at address %d: %w", address, err)
+		}
+
+		id, length := header[0], header[1]
+		if id == SunSpecEndModelID {
+			return device, nil
+		}
+
+		device.Models = append(device.Models, SunSpecModel{
+			ID:      id,
+			Address: modbus.Address(address),
+			Length:  length,
+		})
+		address += 2 + int(length)
+	}
+	return nil, fmt.Errorf("SunSpec model chain at base %d runs past the end of the address space", base)
+}
@@ -0,0 +1,228 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adibhanna/modbus-go/modbus"
+	"github.com/adibhanna/modbus-go/pdu"
+)
+
+// ErrCircuitOpen is returned without touching the device while a
+// CircuitBreakerTransport is open
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitState is the state of a CircuitBreakerTransport
+type CircuitState int
+
+const (
+	// CircuitClosed passes every request through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every request with ErrCircuitOpen until the cooldown ends
+	CircuitOpen
+	// CircuitHalfOpen lets one probe request through after the cooldown
+	CircuitHalfOpen
+)
+
+// String returns a string representation
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "Closed"
+	case CircuitOpen:
+		return "Open"
+	case CircuitHalfOpen:
+		return "HalfOpen"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitBreakerTransport wraps a transport and stops sending to a device
+// that keeps failing, so callers don't wait out a full timeout on every
+// request. After threshold consecutive failures of Connect, SendRequest or
+// SendBroadcast the circuit opens and they all fail at once with
+// ErrCircuitOpen. Once cooldown has passed, the circuit is half-open: the next
+// call is sent as a probe, reopening the circuit for another cooldown if it
+// fails. Only an answered request closes the circuit and clears the failure
+// count; a successful Connect or broadcast does not, so a device that accepts
+// connections but never answers still trips it when the client reconnects
+// automatically. Exception responses are answers from the device and count as
+// successes.
+//
+// Logger, timeout and unit ID settings are passed on to the wrapped transport
+// if it supports them.
+//
+//	client := modbus.NewClient(transport.NewCircuitBreakerTransport(tcp, 3, 10*time.Second))
+type CircuitBreakerTransport struct {
+	Transport
+	threshold int
+	cooldown  time.Duration
+
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+	mutex    sync.Mutex
+}
+
+// NewCircuitBreakerTransport creates a circuit breaker around inner that
+// opens after threshold consecutive failures (at least 1) and stays open for
+// cooldown
+func NewCircuitBreakerTransport(inner Transport, threshold int, cooldown time.Duration) *CircuitBreakerTransport {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreakerTransport{
+		Transport: inner,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Connect connects the wrapped transport unless the circuit is open
+func (t *CircuitBreakerTransport) Connect() error {
+	if err := t.allow(); err != nil {
+		return err
+	}
+	err := t.Transport.Connect()
+	t.record(err, false)
+	return err
+}
+
+// SendRequest sends the request through the wrapped transport unless the
+// circuit is open
+func (t *CircuitBreakerTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request) (*pdu.Response, error) {
+	if err := t.allow(); err != nil {
+		return nil, err
+	}
+	response, err := t.Transport.SendRequest(slaveID, request)
+	t.record(err, true)
+	return response, err
+}
+
+// SendBroadcast sends a broadcast through the wrapped transport unless the
+// circuit is open. If the wrapped transport has no broadcast support the
+// request is sent to slave 0 and any reply or timeout is ignored, as the
+// client does.
+func (t *CircuitBreakerTransport) SendBroadcast(request *pdu.Request) error {
+	if err := t.allow(); err != nil {
+		return err
+	}
+
+	var err error
+	if b, ok := t.Transport.(Broadcaster); ok {
+		err = b.SendBroadcast(request)
+	} else if _, err = t.Transport.SendRequest(modbus.BroadcastAddress, request); err != nil && !modbus.IsWriteError(err) {
+		err = nil
+	}
+	t.record(err, false)
+	return err
+}
+
+// allow returns an error wrapping ErrCircuitOpen if a call may not be made
+// now. An open circuit whose cooldown has passed turns half-open and admits
+// the caller as its single probe.
+func (t *CircuitBreakerTransport) allow() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	switch t.state {
+	case CircuitOpen:
+		if remaining := t.cooldown - time.Since(t.openedAt); remaining > 0 {
+			return fmt.Errorf("%w after %d consecutive failures, retry in %v", ErrCircuitOpen, t.failures, remaining.Round(time.Millisecond))
+		}
+		t.state = CircuitHalfOpen
+		t.probing = true
+	case CircuitHalfOpen:
+		if t.probing {
+			return fmt.Errorf("%w: probe in progress", ErrCircuitOpen)
+		}
+		t.probing = true
+	}
+	return nil
+}
+
+// record updates the circuit with the outcome of a call. A success closes the
+// circuit only if answered is true; otherwise it just ends a probe, leaving
+// the next request to decide.
+func (t *CircuitBreakerTransport) record(err error, answered bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.probing = false
+	if err == nil {
+		if answered {
+			t.state = CircuitClosed
+			t.failures = 0
+		}
+		return
+	}
+
+	t.failures++
+	if t.state == CircuitHalfOpen || t.failures >= t.threshold {
+		t.state = CircuitOpen
+		t.openedAt = time.Now()
+	}
+}
+
+// State returns the current circuit state. An open circuit reports
+// CircuitOpen until the next call after its cooldown.
+func (t *CircuitBreakerTransport) State() CircuitState {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.state
+}
+
+// Reset closes the circuit and clears the failure count
+func (t *CircuitBreakerTransport) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.state = CircuitClosed
+	t.failures = 0
+	t.probing = false
+}
+
+// SetLogger sets the wrapped transport's logger if it supports one
+func (t *CircuitBreakerTransport) SetLogger(logger Logger) {
+	if ls, ok := t.Transport.(interface{ SetLogger(Logger) }); ok {
+		ls.SetLogger(logger)
+	}
+}
+
+// GetLogger returns the wrapped transport's logger, or nil if it has none
+func (t *CircuitBreakerTransport) GetLogger() Logger {
+	if lg, ok := t.Transport.(interface{ GetLogger() Logger }); ok {
+		return lg.GetLogger()
+	}
+	return nil
+}
+
+// SetConnectTimeout sets the wrapped transport's connect timeout if it has one
+func (t *CircuitBreakerTransport) SetConnectTimeout(timeout time.Duration) {
+	if cs, ok := t.Transport.(interface{ SetConnectTimeout(time.Duration) }); ok {
+		cs.SetConnectTimeout(timeout)
+	}
+}
+
+// SetWriteTimeout sets the wrapped transport's write timeout if it has one
+func (t *CircuitBreakerTransport) SetWriteTimeout(timeout time.Duration) {
+	if ws, ok := t.Transport.(interface{ SetWriteTimeout(time.Duration) }); ok {
+		ws.SetWriteTimeout(timeout)
+	}
+}
+
+// SetValidateUnitID sets whether the wrapped transport checks response unit
+// IDs, if it has the check
+func (t *CircuitBreakerTransport) SetValidateUnitID(validate bool) {
+	if vs, ok := t.Transport.(interface{ SetValidateUnitID(bool) }); ok {
+		vs.SetValidateUnitID(validate)
+	}
+}
+
+// String returns a string representation
+func (t *CircuitBreakerTransport) String() string {
+	return fmt.Sprintf("CircuitBreaker(%s)", t.Transport.String())
+}
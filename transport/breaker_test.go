@@ -0,0 +1,140 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/adibhanna/modbus-go/modbus"
+	"github.com/adibhanna/modbus-go/pdu"
+)
+
+// flakyTransport answers every request with a fixed response or error
+type flakyTransport struct {
+	ReplayTransport
+	err   error
+	calls int
+}
+
+func (t *flakyTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request) (*pdu.Response, error) {
+	t.calls++
+	if t.err != nil {
+		return nil, t.err
+	}
+	return pdu.NewResponse(request.FunctionCode, []byte{0x02, 0x00, 0x01}), nil
+}
+
+func TestCircuitBreakerTransport(t *testing.T) {
+	inner := &flakyTransport{err: errors.New("timeout")}
+	breaker := NewCircuitBreakerTransport(inner, 2, 50*time.Millisecond)
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+
+	for i := 0; i < 2; i++ {
+		if _, err := breaker.SendRequest(1, req); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Request %d: circuit opened early", i)
+		}
+	}
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected open circuit after 2 failures, got %s", breaker.State())
+	}
+
+	// Open: requests and connects fail fast without reaching the device
+	if _, err := breaker.SendRequest(1, req); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if err := breaker.Connect(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen from Connect, got %v", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("Expected 2 requests to reach the device, got %d", inner.calls)
+	}
+
+	// A failed probe reopens the circuit for another cooldown
+	time.Sleep(60 * time.Millisecond)
+	if _, err := breaker.SendRequest(1, req); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected probe to reach the device and fail, got %v", err)
+	}
+	if breaker.State() != CircuitOpen || inner.calls != 3 {
+		t.Errorf("Expected reopened circuit after 3 calls, got %s after %d", breaker.State(), inner.calls)
+	}
+
+	// A successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	inner.err = nil
+	if _, err := breaker.SendRequest(1, req); err != nil {
+		t.Errorf("Expected probe to succeed, got %v", err)
+	}
+	if breaker.State() != CircuitClosed {
+		t.Errorf("Expected closed circuit, got %s", breaker.State())
+	}
+
+	// A success resets the consecutive failure count
+	inner.err = errors.New("timeout")
+	_, _ = breaker.SendRequest(1, req)
+	inner.err = nil
+	_, _ = breaker.SendRequest(1, req)
+	inner.err = errors.New("timeout")
+	_, _ = breaker.SendRequest(1, req)
+	if breaker.State() != CircuitClosed {
+		t.Errorf("Expected non-consecutive failures to keep the circuit closed, got %s", breaker.State())
+	}
+
+	_, _ = breaker.SendRequest(1, req)
+	breaker.Reset()
+	if _, err := breaker.SendRequest(1, req); errors.Is(err, ErrCircuitOpen) {
+		t.Error("Expected Reset to close the circuit")
+	}
+	if breaker.String() != "CircuitBreaker(Replay(0 exchanges))" {
+		t.Errorf("Unexpected string %q", breaker.String())
+	}
+}
+
+func TestCircuitBreakerConnectDoesNotClose(t *testing.T) {
+	inner := &flakyTransport{err: errors.New("timeout")}
+	breaker := NewCircuitBreakerTransport(inner, 2, time.Minute)
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+
+	// Reconnecting between failed requests must not reset the count
+	_, _ = breaker.SendRequest(1, req)
+	if err := breaker.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	_, _ = breaker.SendRequest(1, req)
+	if breaker.State() != CircuitOpen {
+		t.Errorf("Expected open circuit after 2 failed requests, got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreakerForwardsSettings(t *testing.T) {
+	inner := NewTCPTransport("localhost:502")
+	breaker := NewCircuitBreakerTransport(inner, 3, time.Second)
+
+	var _ Broadcaster = breaker
+	logger := log.New(io.Discard, "", 0)
+	breaker.SetLogger(logger)
+	breaker.SetConnectTimeout(2 * time.Second)
+	breaker.SetWriteTimeout(3 * time.Second)
+	breaker.SetValidateUnitID(false)
+
+	if breaker.GetLogger() != logger {
+		t.Error("Expected the logger to reach the wrapped transport")
+	}
+	if inner.GetConnectTimeout() != 2*time.Second {
+		t.Errorf("Expected connect timeout 2s, got %v", inner.GetConnectTimeout())
+	}
+	if inner.GetWriteTimeout() != 3*time.Second {
+		t.Errorf("Expected write timeout 3s, got %v", inner.GetWriteTimeout())
+	}
+	if !inner.skipUnitIDCheck {
+		t.Error("Expected unit ID validation to be disabled on the wrapped transport")
+	}
+
+	// Transports without the settings ignore them
+	replay := NewCircuitBreakerTransport(&flakyTransport{}, 3, time.Second)
+	replay.SetLogger(logger)
+	if replay.GetLogger() != nil {
+		t.Error("Expected no logger for a transport without logging")
+	}
+}
@@ -1,6 +1,9 @@
 package transport
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/adibhanna/modbus-go/modbus"
@@ -33,3 +36,33 @@ type Transport interface {
 	// String returns a string representation
 	String() string
 }
+
+// Broadcaster is implemented by transports that can send a broadcast request
+// (slave ID 0) without waiting for a response. Serial transports wait the
+// turnaround delay after sending so devices can process the broadcast.
+type Broadcaster interface {
+	SendBroadcast(request *pdu.Request) error
+}
+
+// ErrConnectionClosed is returned when the peer closes the connection while a
+// response is awaited, e.g. because the device rebooted. The transport is
+// marked disconnected, so IsConnected reports false and auto-reconnect can
+// take over.
+var ErrConnectionClosed = errors.New("connection closed by peer")
+
+// wrapReadError marks a failed response read as ErrConnectionClosed if the
+// peer closed the connection, or as a modbus.TimeoutError if it timed out
+func wrapReadError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %w", ErrConnectionClosed, err)
+	}
+	return wrapTimeout(err)
+}
+
+// wrapTimeout wraps err in a modbus.TimeoutError if it was caused by a timeout
+func wrapTimeout(err error) error {
+	if err != nil && modbus.IsTimeout(err) {
+		return &modbus.TimeoutError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+package transport
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// FrameDirection tells whether an observed frame was sent or received
+type FrameDirection int
+
+const (
+	// FrameSent is a frame written to the connection
+	FrameSent FrameDirection = iota
+	// FrameReceived is a frame read from the connection
+	FrameReceived
+)
+
+// String returns a string representation
+func (d FrameDirection) String() string {
+	if d == FrameSent {
+		return "TX"
+	}
+	return "RX"
+}
+
+// FrameObserver receives raw frames passing through a transport. The frame
+// must not be modified or retained after the call returns.
+type FrameObserver func(direction FrameDirection, frame []byte)
+
+// pcap file constants
+const (
+	pcapMagic     = 0xA1B2C3D4
+	pcapSnapLen   = 65535
+	pcapLinkRaw   = 101 // LINKTYPE_RAW: packets start with an IPv4 header
+	pcapIPv4Size  = 20
+	pcapTCPSize   = 20
+	pcapLocalPort = 49152
+)
+
+var (
+	pcapLocalIP  = [4]byte{10, 0, 0, 1}
+	pcapRemoteIP = [4]byte{10, 0, 0, 2}
+)
+
+// PcapWriter writes MODBUS/TCP frames to a pcap file that Wireshark opens
+// with its MODBUS/TCP dissector. Each frame is wrapped in synthetic IPv4 and
+// TCP headers between 10.0.0.1:49152 (the transport) and 10.0.0.2:502 (the
+// peer), with sequence numbers that let Wireshark follow the stream:
+//
+//	f, _ := os.Create("modbus.pcap")
+//	capture, _ := transport.NewPcapWriter(f)
+//	tcp.SetFrameObserver(capture.Observe)
+type PcapWriter struct {
+	w         io.Writer
+	localSeq  uint32
+	remoteSeq uint32
+	ipID      uint16
+	err       error
+	mutex     sync.Mutex
+}
+
+// NewPcapWriter writes the pcap file header to w and returns a writer for
+// the frames that follow
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:4], pcapMagic)
+	binary.LittleEndian.PutUint16(header[4:6], 2) // version 2.4
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:24], pcapLinkRaw)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write pcap header: %w", err)
+	}
+	return &PcapWriter{w: w, localSeq: 1, remoteSeq: 1}, nil
+}
+
+// WriteFrame writes one MODBUS/TCP ADU as a packet in the given direction
+func (p *PcapWriter) WriteFrame(direction FrameDirection, frame []byte) error {
+	return p.writeFrame(time.Now(), direction, frame)
+}
+
+// Observe writes frame like WriteFrame and matches FrameObserver, so it can be
+// passed to SetFrameObserver. The first write error is kept and reported by
+// Err; later frames are dropped.
+func (p *PcapWriter) Observe(direction FrameDirection, frame []byte) {
+	p.mutex.Lock()
+	failed := p.err != nil
+	p.mutex.Unlock()
+	if failed {
+		return
+	}
+
+	if err := p.WriteFrame(direction, frame); err != nil {
+		p.mutex.Lock()
+		if p.err == nil {
+			p.err = err
+		}
+		p.mutex.Unlock()
+	}
+}
+
+// Err returns the first error hit by Observe
+func (p *PcapWriter) Err() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err
+}
+
+// writeFrame writes frame as a packet captured at ts
+func (p *PcapWriter) writeFrame(ts time.Time, direction FrameDirection, frame []byte) error {
+	if len(frame) > pcapSnapLen-pcapIPv4Size-pcapTCPSize {
+		return fmt.Errorf("frame of %d bytes too large for pcap record", len(frame))
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	srcIP, dstIP := pcapLocalIP, pcapRemoteIP
+	srcPort, dstPort := uint16(pcapLocalPort), uint16(502)
+	seq, ack := &p.localSeq, p.remoteSeq
+	if direction == FrameReceived {
+		srcIP, dstIP = dstIP, srcIP
+		srcPort, dstPort = dstPort, srcPort
+		seq, ack = &p.remoteSeq, p.localSeq
+	}
+
+	packetLen := pcapIPv4Size + pcapTCPSize + len(frame)
+	record := make([]byte, 16+packetLen)
+
+	// Record header
+	binary.LittleEndian.PutUint32(record[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(packetLen))
+	binary.LittleEndian.PutUint32(record[12:16], uint32(packetLen))
+
+	// IPv4 header
+	ip := record[16 : 16+pcapIPv4Size]
+	ip[0] = 0x45 // version 4, 5 words
+	binary.BigEndian.PutUint16(ip[2:4], uint16(packetLen))
+	p.ipID++
+	binary.BigEndian.PutUint16(ip[4:6], p.ipID)
+	binary.BigEndian.PutUint16(ip[6:8], 0x4000) // don't fragment
+	ip[8] = 64                                  // TTL
+	ip[9] = 6                                   // TCP
+	copy(ip[12:16], srcIP[:])
+	copy(ip[16:20], dstIP[:])
+	binary.BigEndian.PutUint16(ip[10:12], ipv4Checksum(ip))
+
+	// TCP header; the checksum is left zero, which Wireshark accepts by default
+	tcp := record[16+pcapIPv4Size : 16+pcapIPv4Size+pcapTCPSize]
+	binary.BigEndian.PutUint16(tcp[0:2], srcPort)
+	binary.BigEndian.PutUint16(tcp[2:4], dstPort)
+	binary.BigEndian.PutUint32(tcp[4:8], *seq)
+	binary.BigEndian.PutUint32(tcp[8:12], ack)
+	tcp[12] = 5 << 4 // 5 words
+	tcp[13] = 0x18   // PSH, ACK
+	binary.BigEndian.PutUint16(tcp[14:16], 0xFFFF)
+
+	copy(record[16+pcapIPv4Size+pcapTCPSize:], frame)
+
+	if _, err := p.w.Write(record); err != nil {
+		return fmt.Errorf("failed to write pcap record: %w", err)
+	}
+	*seq += uint32(len(frame))
+	return nil
+}
+
+// ipv4Checksum computes the IPv4 header checksum with the checksum field zero
+func ipv4Checksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(header[i])<<8 | uint32(header[i+1])
+	}
+	for sum > 0xFFFF {
+		sum = sum&0xFFFF + sum>>16
+	}
+	return ^uint16(sum)
+}
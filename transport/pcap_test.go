@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/adibhanna/modbus-go/modbus"
+	"github.com/adibhanna/modbus-go/pdu"
+)
+
+func TestPcapCapture(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	var capture bytes.Buffer
+	writer, err := NewPcapWriter(&capture)
+	if err != nil {
+		t.Fatalf("NewPcapWriter failed: %v", err)
+	}
+
+	transport := &TCPTransport{conn: client, connected: true, timeout: time.Second, transactionID: 1}
+	transport.SetFrameObserver(writer.Observe)
+
+	response := append((&MBAPHeader{TransactionID: 1, ProtocolID: modbus.MBAPProtocolID, Length: 5, UnitID: 1}).EncodeMBAP(),
+		0x03, 0x02, 0x00, 0x2A)
+	go func() {
+		request := make([]byte, modbus.MBAPHeaderSize+5)
+		if _, err := io.ReadFull(server, request); err == nil {
+			_, _ = server.Write(response)
+		}
+	}()
+
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+	if _, err := transport.SendRequest(1, req); err != nil {
+		t.Fatalf("SendRequest failed: %v", err)
+	}
+	if err := writer.Err(); err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	data := capture.Bytes()
+	if len(data) < 24 || binary.LittleEndian.Uint32(data[0:4]) != pcapMagic ||
+		binary.LittleEndian.Uint32(data[20:24]) != pcapLinkRaw {
+		t.Fatalf("Invalid pcap header % X", data[:min(len(data), 24)])
+	}
+	data = data[24:]
+
+	request := append((&MBAPHeader{TransactionID: 1, ProtocolID: modbus.MBAPProtocolID, Length: 6, UnitID: 1}).EncodeMBAP(),
+		req.Bytes()...)
+	want := []struct {
+		srcPort, dstPort uint16
+		seq, ack         uint32
+		payload          []byte
+	}{
+		{pcapLocalPort, 502, 1, 1, request},
+		{502, pcapLocalPort, 1, 1 + uint32(len(request)), response},
+	}
+
+	for i, w := range want {
+		if len(data) < 16 {
+			t.Fatalf("Record %d missing", i)
+		}
+		length := int(binary.LittleEndian.Uint32(data[8:12]))
+		packet := data[16 : 16+length]
+		data = data[16+length:]
+
+		ip, tcp, payload := packet[:pcapIPv4Size], packet[pcapIPv4Size:pcapIPv4Size+pcapTCPSize], packet[pcapIPv4Size+pcapTCPSize:]
+		if ipv4Checksum(ip) != 0 || int(binary.BigEndian.Uint16(ip[2:4])) != length {
+			t.Errorf("Record %d: invalid IPv4 header % X", i, ip)
+		}
+		if binary.BigEndian.Uint16(tcp[0:2]) != w.srcPort || binary.BigEndian.Uint16(tcp[2:4]) != w.dstPort {
+			t.Errorf("Record %d: unexpected ports % X", i, tcp[0:4])
+		}
+		if binary.BigEndian.Uint32(tcp[4:8]) != w.seq || binary.BigEndian.Uint32(tcp[8:12]) != w.ack {
+			t.Errorf("Record %d: unexpected seq/ack % X", i, tcp[4:12])
+		}
+		if !bytes.Equal(payload, w.payload) {
+			t.Errorf("Record %d: payload % X, want % X", i, payload, w.payload)
+		}
+	}
+	if len(data) != 0 {
+		t.Errorf("Unexpected trailing %d bytes", len(data))
+	}
+}
@@ -0,0 +1,287 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/adibhanna/modbus-go/modbus"
+	"github.com/adibhanna/modbus-go/pdu"
+)
+
+// Exchange is one recorded request/response pair. PDUs are stored as hex
+// strings; Error holds the transport error text if the request failed, and
+// ErrorKind and Disconnected let a replay return the same kind of error and
+// leave the transport in the same state.
+type Exchange struct {
+	SlaveID      int       `json:"slave_id"`
+	Request      string    `json:"request"`
+	Response     string    `json:"response,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ErrorKind    ErrorKind `json:"error_kind,omitempty"`
+	Disconnected bool      `json:"disconnected,omitempty"`
+}
+
+// ErrorKind classifies a recorded error by the error types the client acts on
+type ErrorKind string
+
+// Recorded error kinds
+const (
+	ErrorKindOther       ErrorKind = ""
+	ErrorKindTimeout     ErrorKind = "timeout"
+	ErrorKindWrite       ErrorKind = "write"
+	ErrorKindClosed      ErrorKind = "closed"
+	ErrorKindCircuitOpen ErrorKind = "circuit_open"
+)
+
+// errorKindOf returns the kind of err
+func errorKindOf(err error) ErrorKind {
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return ErrorKindCircuitOpen
+	case modbus.IsWriteError(err):
+		return ErrorKindWrite
+	case errors.Is(err, ErrConnectionClosed):
+		return ErrorKindClosed
+	case modbus.IsTimeout(err):
+		return ErrorKindTimeout
+	default:
+		return ErrorKindOther
+	}
+}
+
+// replayedError is a recorded error with its original text that matches the
+// sentinel of its kind with errors.Is
+type replayedError struct {
+	text     string
+	sentinel error
+}
+
+func (e *replayedError) Error() string { return e.text }
+func (e *replayedError) Unwrap() error { return e.sentinel }
+
+// err rebuilds the recorded error with the type of its kind
+func (e Exchange) err() error {
+	switch e.ErrorKind {
+	case ErrorKindTimeout:
+		return &modbus.TimeoutError{Err: errors.New(e.Error)}
+	case ErrorKindWrite:
+		return &modbus.WriteError{Err: errors.New(e.Error)}
+	case ErrorKindClosed:
+		return &replayedError{text: e.Error, sentinel: ErrConnectionClosed}
+	case ErrorKindCircuitOpen:
+		return &replayedError{text: e.Error, sentinel: ErrCircuitOpen}
+	default:
+		return errors.New(e.Error)
+	}
+}
+
+// RecordingTransport wraps a transport and writes every exchange to w as one
+// JSON object per line, for later replay with ReplayTransport
+type RecordingTransport struct {
+	Transport
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+// NewRecordingTransport creates a transport that records exchanges sent
+// through inner to w
+func NewRecordingTransport(inner Transport, w io.Writer) *RecordingTransport {
+	return &RecordingTransport{
+		Transport: inner,
+		w:         w,
+	}
+}
+
+// SendRequest sends the request through the wrapped transport and records it
+func (t *RecordingTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request) (*pdu.Response, error) {
+	response, err := t.Transport.SendRequest(slaveID, request)
+
+	exchange := Exchange{
+		SlaveID: int(slaveID),
+		Request: hex.EncodeToString(request.Bytes()),
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+		exchange.ErrorKind = errorKindOf(err)
+		exchange.Disconnected = !t.Transport.IsConnected()
+	} else if response != nil {
+		exchange.Response = hex.EncodeToString(response.Bytes())
+	}
+
+	line, marshalErr := json.Marshal(exchange)
+	if marshalErr != nil {
+		return response, err
+	}
+
+	t.mutex.Lock()
+	_, writeErr := t.w.Write(append(line, '\n'))
+	t.mutex.Unlock()
+	if writeErr != nil && err == nil {
+		return response, fmt.Errorf("failed to record exchange: %w", writeErr)
+	}
+
+	return response, err
+}
+
+// String returns a string representation
+func (t *RecordingTransport) String() string {
+	return fmt.Sprintf("Recording(%s)", t.Transport.String())
+}
+
+// ErrReplayMismatch is returned when a replayed request differs from the
+// recorded one
+var ErrReplayMismatch = errors.New("request does not match recording")
+
+// ErrReplayExhausted is returned when more requests are sent than were recorded
+var ErrReplayExhausted = errors.New("no more recorded exchanges")
+
+// ReplayTransport serves recorded responses back in order. Each request must
+// match the next recorded request byte for byte.
+type ReplayTransport struct {
+	exchanges     []Exchange
+	next          int
+	connected     bool
+	timeout       time.Duration
+	transportType modbus.TransportType
+	mutex         sync.Mutex
+}
+
+// NewReplayTransport reads exchanges written by a RecordingTransport from r
+func NewReplayTransport(r io.Reader) (*ReplayTransport, error) {
+	var exchanges []Exchange
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(data, &exchange); err != nil {
+			return nil, fmt.Errorf("invalid recording at line %d: %w", line, err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	return &ReplayTransport{
+		exchanges:     exchanges,
+		timeout:       time.Duration(modbus.DefaultResponseTimeout) * time.Millisecond,
+		transportType: modbus.TransportTCP,
+	}, nil
+}
+
+// SetTransportType sets the transport type reported by GetTransportType, for
+// replaying sessions recorded over serial lines
+func (t *ReplayTransport) SetTransportType(transportType modbus.TransportType) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.transportType = transportType
+}
+
+// Connect marks the transport connected
+func (t *ReplayTransport) Connect() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.connected = true
+	return nil
+}
+
+// Close marks the transport disconnected
+func (t *ReplayTransport) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.connected = false
+	return nil
+}
+
+// IsConnected returns true if the transport is connected
+func (t *ReplayTransport) IsConnected() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.connected
+}
+
+// Remaining returns the number of recorded exchanges not yet replayed
+func (t *ReplayTransport) Remaining() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.exchanges) - t.next
+}
+
+// SendRequest returns the next recorded response, or the recorded error. A
+// request that dropped the connection when recorded leaves the transport
+// disconnected.
+func (t *ReplayTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request) (*pdu.Response, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.connected {
+		return nil, fmt.Errorf("transport not connected")
+	}
+	if t.next >= len(t.exchanges) {
+		return nil, ErrReplayExhausted
+	}
+
+	exchange := t.exchanges[t.next]
+	got := hex.EncodeToString(request.Bytes())
+	if int(slaveID) != exchange.SlaveID || got != exchange.Request {
+		return nil, fmt.Errorf("%w: exchange %d expected slave %d request %s, got slave %d request %s",
+			ErrReplayMismatch, t.next+1, exchange.SlaveID, exchange.Request, slaveID, got)
+	}
+	t.next++
+
+	if exchange.Error != "" {
+		if exchange.Disconnected {
+			t.connected = false
+		}
+		return nil, exchange.err()
+	}
+
+	data, err := hex.DecodeString(exchange.Response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded response: %w", err)
+	}
+	responsePDU, err := pdu.ParsePDU(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded response: %w", err)
+	}
+
+	return &pdu.Response{PDU: responsePDU}, nil
+}
+
+// SetTimeout sets the response timeout. Replayed responses are immediate.
+func (t *ReplayTransport) SetTimeout(timeout time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.timeout = timeout
+}
+
+// GetTimeout returns the current timeout
+func (t *ReplayTransport) GetTimeout() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.timeout
+}
+
+// GetTransportType returns the transport type
+func (t *ReplayTransport) GetTransportType() modbus.TransportType {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.transportType
+}
+
+// String returns a string representation
+func (t *ReplayTransport) String() string {
+	return fmt.Sprintf("Replay(%d exchanges)", len(t.exchanges))
+}
@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/adibhanna/modbus-go/modbus"
+)
+
+// ModbusRoleOID is the X.509 extension that carries the role of a MODBUS/TCP
+// Security certificate as a UTF8String
+var ModbusRoleOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 50316, 802, 1}
+
+// CertificateRole returns the role stored in cert's extension oid, and whether
+// the extension is present
+func CertificateRole(cert *x509.Certificate, oid asn1.ObjectIdentifier) (string, bool, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oid) {
+			continue
+		}
+		var role string
+		if _, err := asn1.UnmarshalWithParams(ext.Value, &role, "utf8"); err != nil {
+			return "", true, fmt.Errorf("invalid role extension: %w", err)
+		}
+		return role, true, nil
+	}
+	return "", false, nil
+}
+
+// NewSecureTCPTransport creates a TLS transport following the MODBUS/TCP
+// Security profile. The configuration is copied and adjusted:
+//
+//   - the port defaults to 802 if address has none
+//   - TLS 1.2 is the minimum version
+//   - the server name (SNI) defaults to the host in address
+//
+// The profile requires mutual authentication, so tlsConfig must hold a client
+// certificate. The role that server-side authorization is based on lives in
+// that certificate, under roleOID (ModbusRoleOID if nil); it is checked here
+// so a certificate without a role fails before connecting rather than being
+// rejected by the server. The role itself is assigned when the certificate is
+// issued and cannot be changed by the client.
+func NewSecureTCPTransport(address string, tlsConfig *tls.Config, roleOID asn1.ObjectIdentifier) (*TCPTransport, error) {
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("TLS configuration is required")
+	}
+	if roleOID == nil {
+		roleOID = ModbusRoleOID
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, strconv.Itoa(modbus.TCPSecurePort)
+	}
+
+	config := tlsConfig.Clone()
+	if config.MinVersion < tls.VersionTLS12 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+
+	if len(config.Certificates) == 0 && config.GetClientCertificate == nil {
+		return nil, fmt.Errorf("MODBUS/TCP Security requires a client certificate")
+	}
+	for i, cert := range config.Certificates {
+		leaf := cert.Leaf
+		if leaf == nil {
+			if len(cert.Certificate) == 0 {
+				return nil, fmt.Errorf("certificate %d is empty", i)
+			}
+			if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return nil, fmt.Errorf("certificate %d: %w", i, err)
+			}
+		}
+		if _, ok, err := CertificateRole(leaf, roleOID); err != nil {
+			return nil, fmt.Errorf("certificate %d: %w", i, err)
+		} else if !ok {
+			return nil, fmt.Errorf("certificate %d has no role extension %s", i, roleOID)
+		}
+	}
+
+	return NewTLSTransport(net.JoinHostPort(host, port), config), nil
+}
@@ -0,0 +1,78 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testCertificate creates a self-signed client certificate, with a role
+// extension if role is not empty
+func testCertificate(t *testing.T, role string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "modbus client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if role != "" {
+		value, err := asn1.MarshalWithParams(role, "utf8")
+		if err != nil {
+			t.Fatalf("Failed to encode role: %v", err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: ModbusRoleOID, Value: value}}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestNewSecureTCPTransport(t *testing.T) {
+	config := &tls.Config{Certificates: []tls.Certificate{testCertificate(t, "Operator")}}
+
+	transport, err := NewSecureTCPTransport("plc.example", config, nil)
+	if err != nil {
+		t.Fatalf("Failed to create transport: %v", err)
+	}
+	if transport.address != "plc.example:802" {
+		t.Errorf("Expected default port 802, got %s", transport.address)
+	}
+	if transport.tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected minimum TLS 1.2, got 0x%04X", transport.tlsConfig.MinVersion)
+	}
+	if transport.tlsConfig.ServerName != "plc.example" {
+		t.Errorf("Expected SNI plc.example, got %q", transport.tlsConfig.ServerName)
+	}
+	if config.MinVersion != 0 || config.ServerName != "" {
+		t.Error("Expected caller's configuration to be left unchanged")
+	}
+
+	leaf, _ := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if role, ok, err := CertificateRole(leaf, ModbusRoleOID); err != nil || !ok || role != "Operator" {
+		t.Errorf("Expected role Operator, got %q (%v, %v)", role, ok, err)
+	}
+
+	noRole := &tls.Config{Certificates: []tls.Certificate{testCertificate(t, "")}}
+	if _, err := NewSecureTCPTransport("plc.example:8802", noRole, nil); err == nil {
+		t.Error("Expected error for certificate without a role")
+	}
+	if _, err := NewSecureTCPTransport("plc.example", &tls.Config{}, nil); err == nil {
+		t.Error("Expected error without a client certificate")
+	}
+}
@@ -2,9 +2,13 @@ package transport
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/adibhanna/modbus-go/modbus"
@@ -22,8 +26,13 @@ type SerialConfig struct {
 	Timeout  time.Duration
 }
 
-// NewSerialConfig creates a new serial configuration
+// NewSerialConfig creates a new serial configuration.
+// RTU mode requires 8 data bits; ASCII mode allows 7 or 8.
 func NewSerialConfig(port string, baudRate int, dataBits int, stopBits int, parity string) (*SerialConfig, error) {
+	if dataBits != 7 && dataBits != 8 {
+		return nil, fmt.Errorf("invalid data bits: %d (must be 7 or 8)", dataBits)
+	}
+
 	var sb serial.StopBits
 	switch stopBits {
 	case 1:
@@ -34,16 +43,9 @@ func NewSerialConfig(port string, baudRate int, dataBits int, stopBits int, pari
 		return nil, fmt.Errorf("invalid stop bits: %d (must be 1 or 2)", stopBits)
 	}
 
-	var p serial.Parity
-	switch strings.ToUpper(parity) {
-	case "N", "NONE":
-		p = serial.NoParity
-	case "E", "EVEN":
-		p = serial.EvenParity
-	case "O", "ODD":
-		p = serial.OddParity
-	default:
-		return nil, fmt.Errorf("invalid parity: %s (must be N, E, or O)", parity)
+	p, err := ParseParity(parity)
+	if err != nil {
+		return nil, err
 	}
 
 	return &SerialConfig{
@@ -56,21 +58,134 @@ func NewSerialConfig(port string, baudRate int, dataBits int, stopBits int, pari
 	}, nil
 }
 
+// ParseParity converts "N", "E" or "O" (or "NONE", "EVEN", "ODD") to a parity
+func ParseParity(parity string) (serial.Parity, error) {
+	switch strings.ToUpper(parity) {
+	case "N", "NONE":
+		return serial.NoParity, nil
+	case "E", "EVEN":
+		return serial.EvenParity, nil
+	case "O", "ODD":
+		return serial.OddParity, nil
+	default:
+		return serial.NoParity, fmt.Errorf("invalid parity: %s (must be N, E, or O)", parity)
+	}
+}
+
+// NewASCIISerialConfig creates a serial configuration using the ASCII mode
+// default framing of 7 data bits, even parity and 1 stop bit (7E1)
+func NewASCIISerialConfig(port string, baudRate int) (*SerialConfig, error) {
+	return NewSerialConfig(port, baudRate, 7, 1, "E")
+}
+
+// serialOpen opens a serial port; tests replace it to simulate hung drivers
+var serialOpen = serial.Open
+
+// openSerialPort opens a serial port, giving up after timeout. serial.Open can
+// block indefinitely when a driver hangs; the open is left running in the
+// background and a port it eventually returns is closed. A timeout of zero
+// waits indefinitely.
+func openSerialPort(name string, mode *serial.Mode, timeout time.Duration) (serial.Port, error) {
+	if timeout <= 0 {
+		return serialOpen(name, mode)
+	}
+
+	type result struct {
+		port serial.Port
+		err  error
+	}
+	done := make(chan result, 1)
+
+	open := serialOpen
+	go func() {
+		port, err := open(name, mode)
+		done <- result{port, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.port, r.err
+	case <-timer.C:
+		go func() {
+			if r := <-done; r.err == nil {
+				_ = r.port.Close()
+			}
+		}()
+		return nil, fmt.Errorf("timed out after %v", timeout)
+	}
+}
+
 // RTUTransport implements MODBUS RTU over serial transport
 type RTUTransport struct {
-	config    *SerialConfig
-	port      serial.Port
-	connected bool
-	mutex     sync.Mutex
+	config          *SerialConfig
+	port            serial.Port
+	connected       bool
+	connectTimeout  time.Duration
+	turnaroundDelay time.Duration
+	crcByteOrder    CRCByteOrder
+	mutex           sync.Mutex
+}
+
+// CRCByteOrder is the order of the two CRC bytes ending an RTU frame
+type CRCByteOrder int
+
+const (
+	// CRCLowByteFirst is the order defined by the MODBUS serial line spec
+	CRCLowByteFirst CRCByteOrder = iota
+	// CRCHighByteFirst is used by some non-compliant devices
+	CRCHighByteFirst
+)
+
+// String returns a string representation
+func (o CRCByteOrder) String() string {
+	if o == CRCHighByteFirst {
+		return "HighByteFirst"
+	}
+	return "LowByteFirst"
 }
 
 // NewRTUTransport creates a new RTU transport
 func NewRTUTransport(config *SerialConfig) *RTUTransport {
 	return &RTUTransport{
-		config: config,
+		config:          config,
+		connectTimeout:  time.Duration(modbus.DefaultConnectTimeout) * time.Millisecond,
+		turnaroundDelay: time.Duration(modbus.DefaultTurnaroundDelay) * time.Millisecond,
 	}
 }
 
+// SetConnectTimeout sets how long Connect waits for the serial port to open
+func (t *RTUTransport) SetConnectTimeout(timeout time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.connectTimeout = timeout
+}
+
+// SetCRCByteOrder sets the order of the CRC bytes in sent and received
+// frames. The default is CRCLowByteFirst, as the standard requires; use
+// CRCHighByteFirst only for devices that get it wrong.
+func (t *RTUTransport) SetCRCByteOrder(order CRCByteOrder) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.crcByteOrder = order
+}
+
+// GetConnectTimeout returns the current connection timeout
+func (t *RTUTransport) GetConnectTimeout() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.connectTimeout
+}
+
+// SetTurnaroundDelay sets the pause after a broadcast before the next request
+func (t *RTUTransport) SetTurnaroundDelay(delay time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.turnaroundDelay = delay
+}
+
 // Connect opens the serial port
 func (t *RTUTransport) Connect() error {
 	t.mutex.Lock()
@@ -80,6 +195,16 @@ func (t *RTUTransport) Connect() error {
 		return nil
 	}
 
+	return t.open()
+}
+
+// open opens the serial port using the current configuration. The caller must
+// hold t.mutex.
+func (t *RTUTransport) open() error {
+	if t.config.DataBits != 8 {
+		return fmt.Errorf("RTU mode requires 8 data bits, got %d", t.config.DataBits)
+	}
+
 	mode := &serial.Mode{
 		BaudRate: t.config.BaudRate,
 		DataBits: t.config.DataBits,
@@ -87,7 +212,7 @@ func (t *RTUTransport) Connect() error {
 		StopBits: t.config.StopBits,
 	}
 
-	port, err := serial.Open(t.config.Port, mode)
+	port, err := openSerialPort(t.config.Port, mode, t.connectTimeout)
 	if err != nil {
 		return fmt.Errorf("failed to open serial port %s: %w", t.config.Port, err)
 	}
@@ -103,6 +228,45 @@ func (t *RTUTransport) Connect() error {
 	return nil
 }
 
+// Reconfigure replaces the serial settings. If the port is open it is closed
+// and reopened with the new settings; if reopening fails the transport is
+// left disconnected. The configuration is copied, so later changes to config
+// have no effect.
+func (t *RTUTransport) Reconfigure(config *SerialConfig) error {
+	if config == nil {
+		return fmt.Errorf("serial config is required")
+	}
+	if config.DataBits != 8 {
+		return fmt.Errorf("RTU mode requires 8 data bits, got %d", config.DataBits)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	wasConnected := t.connected
+	if t.port != nil {
+		_ = t.port.Close()
+		t.port = nil
+	}
+	t.connected = false
+
+	cfg := *config
+	t.config = &cfg
+
+	if !wasConnected {
+		return nil
+	}
+	return t.open()
+}
+
+// GetConfig returns a copy of the current serial configuration
+func (t *RTUTransport) GetConfig() *SerialConfig {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	cfg := *t.config
+	return &cfg
+}
+
 // Close closes the serial port
 func (t *RTUTransport) Close() error {
 	t.mutex.Lock()
@@ -152,19 +316,12 @@ func (t *RTUTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request)
 	}
 
 	// Create RTU ADU: SlaveID + PDU + CRC
-	pduBytes := request.Bytes()
-	adu := make([]byte, 1+len(pduBytes)+2)
-	adu[0] = byte(slaveID)
-	copy(adu[1:1+len(pduBytes)], pduBytes)
-
-	// Calculate and append CRC
-	crc := calculateCRC16(adu[:1+len(pduBytes)])
-	adu[1+len(pduBytes)] = byte(crc)
-	adu[1+len(pduBytes)+1] = byte(crc >> 8)
+	adu := buildRTUFrame(byte(slaveID), request.Bytes(), t.crcByteOrder)
 
 	// Send request
 	if _, err := t.port.Write(adu); err != nil {
-		return nil, fmt.Errorf("failed to write RTU request: %w", err)
+		t.dropIfPortLost(err)
+		return nil, fmt.Errorf("failed to write RTU request: %w", &modbus.WriteError{Err: err})
 	}
 
 	// Calculate inter-character timeout for RTU
@@ -188,7 +345,8 @@ func (t *RTUTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request)
 			if len(response) > 0 && time.Since(lastReceiveTime) >= frameTimeout {
 				break // End of frame detected
 			}
-			return nil, fmt.Errorf("failed to read RTU response: %w", err)
+			t.dropIfPortLost(err)
+			return nil, wrapReadError(fmt.Errorf("failed to read RTU response: %w", err))
 		}
 
 		if n > 0 {
@@ -206,13 +364,67 @@ func (t *RTUTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request)
 
 		// Overall timeout check
 		if time.Since(lastReceiveTime) > t.config.Timeout {
-			return nil, fmt.Errorf("response timeout")
+			return nil, &modbus.TimeoutError{Err: fmt.Errorf("response timeout")}
 		}
 	}
 
 	return t.parseRTUResponse(response, slaveID)
 }
 
+// SendBroadcast sends a request to slave ID 0 and waits the turnaround delay
+// instead of a response
+func (t *RTUTransport) SendBroadcast(request *pdu.Request) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.connected {
+		return fmt.Errorf("transport not connected")
+	}
+
+	if _, err := t.port.Write(buildRTUFrame(modbus.BroadcastAddress, request.Bytes(), t.crcByteOrder)); err != nil {
+		t.dropIfPortLost(err)
+		return fmt.Errorf("failed to write RTU broadcast: %w", &modbus.WriteError{Err: err})
+	}
+
+	time.Sleep(t.turnaroundDelay)
+	return nil
+}
+
+// buildRTUFrame builds an RTU ADU: slave ID, PDU and CRC in the given order
+func buildRTUFrame(slaveID byte, pduBytes []byte, order CRCByteOrder) []byte {
+	adu := make([]byte, 1+len(pduBytes)+2)
+	adu[0] = slaveID
+	copy(adu[1:], pduBytes)
+
+	crc := calculateCRC16(adu[:1+len(pduBytes)])
+	adu[1+len(pduBytes)], adu[2+len(pduBytes)] = byte(crc), byte(crc>>8)
+	if order == CRCHighByteFirst {
+		adu[1+len(pduBytes)], adu[2+len(pduBytes)] = byte(crc>>8), byte(crc)
+	}
+	return adu
+}
+
+// frameCRC returns the CRC in the last two bytes of an RTU frame
+func frameCRC(frame []byte, order CRCByteOrder) uint16 {
+	lo, hi := frame[len(frame)-2], frame[len(frame)-1]
+	if order == CRCHighByteFirst {
+		lo, hi = hi, lo
+	}
+	return uint16(lo) | uint16(hi)<<8
+}
+
+// dropIfPortLost closes the port and marks the transport disconnected if err
+// means the port is gone, so that auto-reconnect reopens it. The caller must
+// hold t.mutex.
+func (t *RTUTransport) dropIfPortLost(err error) {
+	if !isPortLost(err) || t.port == nil {
+		return
+	}
+	_ = t.port.Close()
+	t.port = nil
+	t.connected = false
+}
+
 // parseRTUResponse parses an RTU response
 func (t *RTUTransport) parseRTUResponse(data []byte, expectedSlaveID modbus.SlaveID) (*pdu.Response, error) {
 	if len(data) < 4 {
@@ -222,7 +434,7 @@ func (t *RTUTransport) parseRTUResponse(data []byte, expectedSlaveID modbus.Slav
 	// Extract components
 	receivedSlaveID := modbus.SlaveID(data[0])
 	pduData := data[1 : len(data)-2]
-	receivedCRC := uint16(data[len(data)-2]) | (uint16(data[len(data)-1]) << 8)
+	receivedCRC := frameCRC(data, t.crcByteOrder)
 
 	// Validate slave ID
 	if receivedSlaveID != expectedSlaveID {
@@ -256,19 +468,44 @@ func (t *RTUTransport) String() string {
 
 // ASCIITransport implements MODBUS ASCII over serial transport
 type ASCIITransport struct {
-	config    *SerialConfig
-	port      serial.Port
-	connected bool
-	mutex     sync.Mutex
+	config          *SerialConfig
+	port            serial.Port
+	connected       bool
+	connectTimeout  time.Duration
+	turnaroundDelay time.Duration
+	mutex           sync.Mutex
 }
 
 // NewASCIITransport creates a new ASCII transport
 func NewASCIITransport(config *SerialConfig) *ASCIITransport {
 	return &ASCIITransport{
-		config: config,
+		config:          config,
+		connectTimeout:  time.Duration(modbus.DefaultConnectTimeout) * time.Millisecond,
+		turnaroundDelay: time.Duration(modbus.DefaultTurnaroundDelay) * time.Millisecond,
 	}
 }
 
+// SetConnectTimeout sets how long Connect waits for the serial port to open
+func (t *ASCIITransport) SetConnectTimeout(timeout time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.connectTimeout = timeout
+}
+
+// GetConnectTimeout returns the current connection timeout
+func (t *ASCIITransport) GetConnectTimeout() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.connectTimeout
+}
+
+// SetTurnaroundDelay sets the pause after a broadcast before the next request
+func (t *ASCIITransport) SetTurnaroundDelay(delay time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.turnaroundDelay = delay
+}
+
 // Connect opens the serial port
 func (t *ASCIITransport) Connect() error {
 	t.mutex.Lock()
@@ -285,7 +522,7 @@ func (t *ASCIITransport) Connect() error {
 		StopBits: t.config.StopBits,
 	}
 
-	port, err := serial.Open(t.config.Port, mode)
+	port, err := openSerialPort(t.config.Port, mode, t.connectTimeout)
 	if err != nil {
 		return fmt.Errorf("failed to open serial port %s: %w", t.config.Port, err)
 	}
@@ -349,41 +586,90 @@ func (t *ASCIITransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Reques
 	}
 
 	// Create ASCII frame: : + SlaveID + PDU + LRC + CRLF
-	pduBytes := request.Bytes()
-	dataBytes := make([]byte, 1+len(pduBytes))
-	dataBytes[0] = byte(slaveID)
-	copy(dataBytes[1:], pduBytes)
-
-	// Calculate LRC
-	lrc := calculateLRC(dataBytes)
-	dataBytes = append(dataBytes, lrc)
-
-	// Convert to ASCII hex
-	asciiData := strings.ToUpper(hex.EncodeToString(dataBytes))
-	frame := ":" + asciiData + "\r\n"
+	frame := buildASCIIFrame(byte(slaveID), request.Bytes())
 
 	// Send request
-	if _, err := t.port.Write([]byte(frame)); err != nil {
-		return nil, fmt.Errorf("failed to write ASCII request: %w", err)
+	if _, err := t.port.Write(frame); err != nil {
+		t.dropIfPortLost(err)
+		return nil, fmt.Errorf("failed to write ASCII request: %w", &modbus.WriteError{Err: err})
 	}
 
 	// Receive response
-	response, err := t.readASCIIFrame()
+	response, err := readASCIIFrame(t.port)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read ASCII response: %w", err)
+		t.dropIfPortLost(err)
+		return nil, wrapReadError(fmt.Errorf("failed to read ASCII response: %w", err))
 	}
 
 	return t.parseASCIIResponse(response, slaveID)
 }
 
-// readASCIIFrame reads a complete ASCII frame
-func (t *ASCIITransport) readASCIIFrame() ([]byte, error) {
+// SendBroadcast sends a request to slave ID 0 and waits the turnaround delay
+// instead of a response
+func (t *ASCIITransport) SendBroadcast(request *pdu.Request) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.connected {
+		return fmt.Errorf("transport not connected")
+	}
+
+	if _, err := t.port.Write(buildASCIIFrame(modbus.BroadcastAddress, request.Bytes())); err != nil {
+		t.dropIfPortLost(err)
+		return fmt.Errorf("failed to write ASCII broadcast: %w", &modbus.WriteError{Err: err})
+	}
+
+	time.Sleep(t.turnaroundDelay)
+	return nil
+}
+
+// buildASCIIFrame builds an ASCII frame: ':', hex of slave ID, PDU and LRC, CRLF
+func buildASCIIFrame(slaveID byte, pduBytes []byte) []byte {
+	dataBytes := make([]byte, 1+len(pduBytes), 2+len(pduBytes))
+	dataBytes[0] = slaveID
+	copy(dataBytes[1:], pduBytes)
+	dataBytes = append(dataBytes, calculateLRC(dataBytes))
+
+	return []byte(":" + strings.ToUpper(hex.EncodeToString(dataBytes)) + "\r\n")
+}
+
+// dropIfPortLost closes the port and marks the transport disconnected if err
+// means the port is gone, so that auto-reconnect reopens it. The caller must
+// hold t.mutex.
+func (t *ASCIITransport) dropIfPortLost(err error) {
+	if !isPortLost(err) || t.port == nil {
+		return
+	}
+	_ = t.port.Close()
+	t.port = nil
+	t.connected = false
+}
+
+// isPortLost reports whether a serial read or write error means the port is
+// gone, e.g. because a USB adapter was unplugged. Other errors, such as
+// timeouts, framing errors or an interrupted call, leave the port usable.
+func isPortLost(err error) bool {
+	if err == nil || modbus.IsTimeout(err) {
+		return false
+	}
+	var portErr *serial.PortError
+	if errors.As(err, &portErr) && portErr.Code() == serial.PortClosed {
+		return true
+	}
+	return errors.Is(err, syscall.ENXIO) || errors.Is(err, syscall.ENODEV) ||
+		errors.Is(err, syscall.EIO) || errors.Is(err, syscall.EBADF) ||
+		errors.Is(err, os.ErrClosed)
+}
+
+// readASCIIFrame reads a complete ASCII frame and returns the characters
+// between ':' and CRLF. Frames longer than MaxASCIIFrameSize are rejected.
+func readASCIIFrame(r io.Reader) ([]byte, error) {
 	var frame []byte
 	buf := make([]byte, 1)
 
 	// Look for start character ':'
 	for {
-		n, err := t.port.Read(buf)
+		n, err := r.Read(buf)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read start character: %w", err)
 		}
@@ -392,9 +678,10 @@ func (t *ASCIITransport) readASCIIFrame() ([]byte, error) {
 		}
 	}
 
-	// Read until CRLF
+	// Read until CRLF, excluding the ':' already consumed
+	maxLength := modbus.MaxASCIIFrameSize - 1
 	for {
-		n, err := t.port.Read(buf)
+		n, err := r.Read(buf)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read frame data: %w", err)
 		}
@@ -403,6 +690,9 @@ func (t *ASCIITransport) readASCIIFrame() ([]byte, error) {
 			if len(frame) >= 2 && frame[len(frame)-2] == '\r' && frame[len(frame)-1] == '\n' {
 				break
 			}
+			if len(frame) >= maxLength {
+				return nil, fmt.Errorf("ASCII frame exceeds maximum length of %d characters", modbus.MaxASCIIFrameSize)
+			}
 		}
 	}
 
@@ -0,0 +1,259 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/adibhanna/modbus-go/modbus"
+	"github.com/adibhanna/modbus-go/pdu"
+	"go.bug.st/serial"
+)
+
+// rtuServerPollInterval is how long the RTU server waits for a frame to start
+// before checking for shutdown
+const rtuServerPollInterval = 50 * time.Millisecond
+
+var (
+	// errRTUIdle is returned when no frame has started
+	errRTUIdle = errors.New("no data received")
+	// errRTUBadFrame is returned when a frame is too short or too long
+	errRTUBadFrame = errors.New("invalid RTU frame")
+)
+
+// RTUServer serves MODBUS RTU requests on a serial line. It answers requests
+// addressed to its slave ID and processes broadcasts without replying.
+//
+// The handler may be shared with a TCPServer serving the same data store;
+// ServerRequestHandler and DefaultDataStore are safe for concurrent use.
+type RTUServer struct {
+	config  *SerialConfig
+	slaveID modbus.SlaveID
+	silence time.Duration
+	handler RequestHandler
+	port    io.ReadWriteCloser
+	running bool
+	mutex   sync.RWMutex
+	wg      sync.WaitGroup
+}
+
+// NewRTUServer creates a new RTU server answering as slaveID
+func NewRTUServer(config *SerialConfig, slaveID modbus.SlaveID, handler RequestHandler) *RTUServer {
+	return &RTUServer{
+		config:  config,
+		slaveID: slaveID,
+		silence: rtuFrameSilence(config),
+		handler: handler,
+	}
+}
+
+// Start opens the configured serial port and starts serving requests
+func (s *RTUServer) Start() error {
+	if s.config == nil {
+		return fmt.Errorf("no serial configuration")
+	}
+	if s.config.DataBits != 8 {
+		return fmt.Errorf("RTU mode requires 8 data bits, got %d", s.config.DataBits)
+	}
+
+	mode := &serial.Mode{
+		BaudRate: s.config.BaudRate,
+		DataBits: s.config.DataBits,
+		Parity:   s.config.Parity,
+		StopBits: s.config.StopBits,
+	}
+
+	port, err := serial.Open(s.config.Port, mode)
+	if err != nil {
+		return fmt.Errorf("failed to open serial port %s: %w", s.config.Port, err)
+	}
+
+	if err := s.Serve(port); err != nil {
+		_ = port.Close()
+		return err
+	}
+	return nil
+}
+
+// Serve starts serving requests on an already open port, such as a
+// pseudo-terminal or an in-memory pipe. The server takes ownership of the
+// port and closes it on Stop. Frames are delimited by silence using the
+// port's SetReadTimeout or SetReadDeadline method; a port with neither must
+// deliver exactly one frame per read.
+func (s *RTUServer) Serve(port io.ReadWriteCloser) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running {
+		return fmt.Errorf("server already running")
+	}
+
+	s.port = port
+	s.running = true
+
+	s.wg.Add(1)
+	go s.serveLoop(port)
+
+	return nil
+}
+
+// Stop stops the server and closes the serial port
+func (s *RTUServer) Stop() error {
+	s.mutex.Lock()
+	if !s.running {
+		s.mutex.Unlock()
+		return nil
+	}
+
+	s.running = false
+	err := s.port.Close()
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("failed to close serial port: %w", err)
+	}
+	return nil
+}
+
+// IsRunning returns true if the server is running
+func (s *RTUServer) IsRunning() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.running
+}
+
+// serveLoop reads request frames until the port is closed
+func (s *RTUServer) serveLoop(port io.ReadWriter) {
+	defer s.wg.Done()
+
+	for s.IsRunning() {
+		frame, err := readRTURequest(port, s.silence)
+		if err != nil {
+			if errors.Is(err, errRTUIdle) || errors.Is(err, errRTUBadFrame) {
+				continue
+			}
+			if s.IsRunning() {
+				fmt.Printf("RTU server receive error: %v\n", err)
+			}
+			return
+		}
+
+		response := s.handleFrame(frame)
+		if response == nil {
+			continue
+		}
+
+		if _, err := port.Write(response); err != nil {
+			if s.IsRunning() {
+				fmt.Printf("RTU server send error: %v\n", err)
+			}
+			return
+		}
+	}
+}
+
+// handleFrame validates a request frame and returns the response ADU, or nil
+// if no response should be sent. Function codes are not checked here, so the
+// handler answers unsupported ones with an IllegalFunction exception.
+func (s *RTUServer) handleFrame(frame []byte) []byte {
+	n := len(frame)
+	receivedCRC := uint16(frame[n-2]) | uint16(frame[n-1])<<8
+	if calculateCRC16(frame[:n-2]) != receivedCRC {
+		return nil
+	}
+
+	slaveID := modbus.SlaveID(frame[0])
+	if slaveID != s.slaveID && slaveID != modbus.BroadcastAddress {
+		return nil
+	}
+
+	requestPDU, err := pdu.ParsePDU(frame[1 : n-2])
+	if err != nil {
+		return nil
+	}
+
+	response := s.handler.HandleRequest(slaveID, &pdu.Request{PDU: requestPDU})
+	if response == nil || slaveID == modbus.BroadcastAddress {
+		countDiagnostic(s.handler, "ServerNoResp")
+		return nil
+	}
+
+	adu := buildRTUFrame(byte(s.slaveID), response.Bytes(), CRCLowByteFirst)
+	releaseResponse(s.handler, response)
+	return adu
+}
+
+// rtuFrameSilence returns the t3.5 silence that ends an RTU frame. Above
+// 19200 baud, and when the line settings are unknown, the fixed 1.75ms
+// recommended by the MODBUS serial line specification is used.
+func rtuFrameSilence(config *SerialConfig) time.Duration {
+	if config == nil || config.BaudRate <= 0 || config.BaudRate > 19200 {
+		return 1750 * time.Microsecond
+	}
+	charTime := calculateCharacterTime(config.BaudRate, config.DataBits, int(config.StopBits), config.Parity)
+	return time.Duration(float64(charTime) * 3.5)
+}
+
+// readRTURequest reads one RTU request frame. RTU has no frame delimiter, so a
+// frame ends when the line has been silent for silence (t3.5). The frame is
+// returned whole for the CRC to be checked over all of it.
+func readRTURequest(r io.Reader, silence time.Duration) ([]byte, error) {
+	buf := make([]byte, modbus.MaxSerialADUSize)
+	n, timed, err := readRTUWithin(r, buf, rtuServerPollInterval)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, errRTUIdle
+	}
+
+	frame := make([]byte, n, modbus.MaxSerialADUSize+1)
+	copy(frame, buf[:n])
+	for timed {
+		n, _, err = readRTUWithin(r, buf, silence)
+		if n == 0 && err == nil {
+			break
+		}
+		// Keep reading an overlong frame to its end, but not its bytes
+		if len(frame) <= modbus.MaxSerialADUSize {
+			frame = append(frame, buf[:min(n, modbus.MaxSerialADUSize+1-len(frame))]...)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(frame) < 4 || len(frame) > modbus.MaxSerialADUSize {
+		return nil, fmt.Errorf("%w: %d bytes", errRTUBadFrame, len(frame))
+	}
+	return frame, nil
+}
+
+// readRTUWithin reads into buf, waiting at most timeout for data. A timeout
+// returns no data and no error. timed reports whether r supports timeouts;
+// if not, the read blocks until data arrives.
+func readRTUWithin(r io.Reader, buf []byte, timeout time.Duration) (n int, timed bool, err error) {
+	switch port := r.(type) {
+	case interface{ SetReadTimeout(time.Duration) error }:
+		if err := port.SetReadTimeout(timeout); err != nil {
+			return 0, true, fmt.Errorf("failed to set read timeout: %w", err)
+		}
+		timed = true
+	case interface{ SetReadDeadline(time.Time) error }:
+		if err := port.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return 0, true, fmt.Errorf("failed to set read deadline: %w", err)
+		}
+		timed = true
+	}
+
+	n, err = r.Read(buf)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = nil
+	}
+	return n, timed, err
+}
@@ -0,0 +1,240 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/adibhanna/modbus-go/modbus"
+	"github.com/adibhanna/modbus-go/pdu"
+	"go.bug.st/serial"
+)
+
+func TestASCIISerialConfig(t *testing.T) {
+	config, err := NewASCIISerialConfig("/dev/ttyUSB0", 9600)
+	if err != nil {
+		t.Fatalf("Failed to create ASCII config: %v", err)
+	}
+	if config.DataBits != 7 {
+		t.Errorf("Expected 7 data bits, got %d", config.DataBits)
+	}
+	if config.Parity != serial.EvenParity {
+		t.Errorf("Expected even parity, got %v", config.Parity)
+	}
+
+	if _, err := NewSerialConfig("/dev/ttyUSB0", 9600, 6, 1, "N"); err == nil {
+		t.Error("Expected error for 6 data bits")
+	}
+
+	rtu := NewRTUTransport(config)
+	if err := rtu.Connect(); err == nil || !strings.Contains(err.Error(), "8 data bits") {
+		t.Errorf("Expected RTU to reject 7 data bits, got %v", err)
+	}
+}
+
+func TestReadASCIIFrame(t *testing.T) {
+	t.Run("ValidFrame", func(t *testing.T) {
+		frame, err := readASCIIFrame(bytes.NewReader([]byte(":010300000001FB\r\n")))
+		if err != nil {
+			t.Fatalf("Failed to read frame: %v", err)
+		}
+		if string(frame) != "010300000001FB" {
+			t.Errorf("Expected frame 010300000001FB, got %s", frame)
+		}
+	})
+
+	t.Run("FrameTooLong", func(t *testing.T) {
+		data := ":" + strings.Repeat("A", modbus.MaxASCIIFrameSize) + "\r\n"
+		if _, err := readASCIIFrame(bytes.NewReader([]byte(data))); err == nil {
+			t.Error("Expected error for oversized ASCII frame")
+		}
+	})
+}
+
+// fakePort is a serial.Port whose reads and writes fail with a fixed error
+type fakePort struct {
+	err    error
+	closed atomic.Bool
+}
+
+func (p *fakePort) SetMode(mode *serial.Mode) error                      { return nil }
+func (p *fakePort) Read(b []byte) (int, error)                           { return 0, p.err }
+func (p *fakePort) Write(b []byte) (int, error)                          { return 0, p.err }
+func (p *fakePort) Drain() error                                         { return nil }
+func (p *fakePort) ResetInputBuffer() error                              { return nil }
+func (p *fakePort) ResetOutputBuffer() error                             { return nil }
+func (p *fakePort) SetDTR(dtr bool) error                                { return nil }
+func (p *fakePort) SetRTS(rts bool) error                                { return nil }
+func (p *fakePort) GetModemStatusBits() (*serial.ModemStatusBits, error) { return nil, nil }
+func (p *fakePort) SetReadTimeout(t time.Duration) error                 { return nil }
+func (p *fakePort) Close() error                                         { p.closed.Store(true); return nil }
+func (p *fakePort) Break(d time.Duration) error                          { return nil }
+
+func TestSerialPortLostMarksDisconnected(t *testing.T) {
+	config, _ := NewSerialConfig("/dev/ttyUSB0", 9600, 8, 1, "N")
+	req := pdu.NewRequest(modbus.FuncCodeReadHoldingRegisters, []byte{0, 0, 0, 1})
+
+	port := &fakePort{err: syscall.EIO}
+	rtu := &RTUTransport{config: config, port: port, connected: true}
+	if _, err := rtu.SendRequest(1, req); err == nil {
+		t.Fatal("Expected error from dead port")
+	}
+	if rtu.IsConnected() || !port.closed.Load() {
+		t.Error("Expected RTU transport to close the port and mark itself disconnected")
+	}
+
+	port = &fakePort{err: syscall.EIO}
+	ascii := &ASCIITransport{config: config, port: port, connected: true}
+	if _, err := ascii.SendRequest(1, req); err == nil {
+		t.Fatal("Expected error from dead port")
+	}
+	if ascii.IsConnected() || !port.closed.Load() {
+		t.Error("Expected ASCII transport to close the port and mark itself disconnected")
+	}
+}
+
+func TestIsPortLost(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		lost bool
+	}{
+		{"nil", nil, false},
+		{"ENXIO", syscall.ENXIO, true},
+		{"ENODEV", syscall.ENODEV, true},
+		{"EIO", syscall.EIO, true},
+		{"EBADF", syscall.EBADF, true},
+		{"wrapped EIO", &os.PathError{Op: "read", Path: "/dev/ttyUSB0", Err: syscall.EIO}, true},
+		{"closed file", os.ErrClosed, true},
+		{"port busy", &serial.PortError{}, false},
+		{"timeout", &modbus.TimeoutError{Err: errors.New("response timeout")}, false},
+		{"EINTR", syscall.EINTR, false},
+		{"EAGAIN", syscall.EAGAIN, false},
+		{"other path error", &os.PathError{Op: "open", Path: "/dev/ttyUSB0", Err: syscall.EACCES}, false},
+		{"EOF", io.EOF, false},
+		{"framing", errors.New("invalid frame"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPortLost(tt.err); got != tt.lost {
+				t.Errorf("isPortLost(%v) = %v, want %v", tt.err, got, tt.lost)
+			}
+		})
+	}
+}
+
+func TestRTUReconfigure(t *testing.T) {
+	config, _ := NewSerialConfig("/dev/ttyUSB0", 9600, 8, 1, "N")
+	rtu := NewRTUTransport(config)
+
+	newConfig, _ := NewSerialConfig("/dev/ttyUSB1", 19200, 8, 1, "E")
+	if err := rtu.Reconfigure(newConfig); err != nil {
+		t.Fatalf("Failed to reconfigure closed transport: %v", err)
+	}
+	got := rtu.GetConfig()
+	if got.Port != "/dev/ttyUSB1" || got.BaudRate != 19200 || got.Parity != serial.EvenParity {
+		t.Errorf("Unexpected config after reconfigure: %+v", got)
+	}
+
+	newConfig.BaudRate = 38400
+	if rtu.GetConfig().BaudRate != 19200 {
+		t.Error("Expected Reconfigure to copy the config")
+	}
+
+	// An open port is closed before reopening with the new settings
+	port := &fakePort{}
+	rtu.port, rtu.connected = port, true
+	bad, _ := NewSerialConfig("/dev/nonexistent-modbus-port", 9600, 8, 1, "N")
+	if err := rtu.Reconfigure(bad); err == nil {
+		t.Error("Expected error reopening a missing port")
+	}
+	if !port.closed.Load() || rtu.IsConnected() {
+		t.Error("Expected old port closed and transport disconnected")
+	}
+
+	ascii, _ := NewASCIISerialConfig("/dev/ttyUSB0", 9600)
+	if err := rtu.Reconfigure(ascii); err == nil {
+		t.Error("Expected RTU to reject 7 data bits")
+	}
+}
+
+func TestSerialBroadcastWriteError(t *testing.T) {
+	config, _ := NewSerialConfig("/dev/ttyUSB0", 9600, 8, 1, "N")
+	rtu := &RTUTransport{config: config, port: &fakePort{err: syscall.EIO}, connected: true}
+	req := pdu.NewRequest(modbus.FuncCodeWriteSingleRegister, []byte{0, 0, 0, 1})
+
+	if err := rtu.SendBroadcast(req); !modbus.IsWriteError(err) {
+		t.Errorf("Expected write error, got %v", err)
+	}
+}
+
+func TestSerialConnectTimeout(t *testing.T) {
+	release := make(chan struct{})
+	port := &fakePort{}
+	serialOpen = func(name string, mode *serial.Mode) (serial.Port, error) {
+		<-release
+		return port, nil
+	}
+	defer func() { serialOpen = serial.Open }()
+
+	config, _ := NewSerialConfig("/dev/ttyUSB0", 9600, 8, 1, "N")
+	rtu := NewRTUTransport(config)
+	rtu.SetConnectTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	err := rtu.Connect()
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Connect took %v, expected to give up after the timeout", elapsed)
+	}
+	if rtu.IsConnected() {
+		t.Error("Expected transport to be disconnected")
+	}
+
+	// A port opened after the caller gave up is closed
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for !port.closed.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !port.closed.Load() {
+		t.Error("Expected late-opened port to be closed")
+	}
+}
+
+func TestRTUCRCByteOrder(t *testing.T) {
+	config, _ := NewSerialConfig("/dev/ttyUSB0", 9600, 8, 1, "N")
+	rtu := NewRTUTransport(config)
+
+	// Read holding register response carrying 0x002A
+	standard := buildRTUFrame(1, []byte{0x03, 0x02, 0x00, 0x2A}, CRCLowByteFirst)
+	swapped := buildRTUFrame(1, []byte{0x03, 0x02, 0x00, 0x2A}, CRCHighByteFirst)
+	n := len(standard)
+	if standard[n-2] != swapped[n-1] || standard[n-1] != swapped[n-2] {
+		t.Fatalf("Expected swapped CRC bytes, got % X and % X", standard, swapped)
+	}
+
+	if _, err := rtu.parseRTUResponse(standard, 1); err != nil {
+		t.Errorf("Expected standard frame to parse, got %v", err)
+	}
+	if _, err := rtu.parseRTUResponse(swapped, 1); err == nil {
+		t.Error("Expected CRC mismatch for high-byte-first frame")
+	}
+
+	rtu.SetCRCByteOrder(CRCHighByteFirst)
+	if _, err := rtu.parseRTUResponse(swapped, 1); err != nil {
+		t.Errorf("Expected high-byte-first frame to parse, got %v", err)
+	}
+	if _, err := rtu.parseRTUResponse(standard, 1); err == nil {
+		t.Error("Expected CRC mismatch for standard frame")
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/adibhanna/modbus-go/modbus"
@@ -57,6 +59,7 @@ type TCPTransport struct {
 	conn           net.Conn
 	transactionID  uint16
 	timeout        time.Duration
+	writeTimeout   time.Duration
 	idleTimeout    time.Duration
 	connectTimeout time.Duration
 	mutex          sync.Mutex
@@ -65,12 +68,24 @@ type TCPTransport struct {
 	tlsConfig      *tls.Config
 	logger         Logger
 	lastActivity   time.Time
+
+	// skipUnitIDCheck and skipTransactionIDCheck relax response validation
+	// for gateways that rewrite MBAP header fields
+	skipUnitIDCheck        bool
+	skipTransactionIDCheck bool
+
+	// pendingBroadcasts holds transaction IDs of broadcasts sent without
+	// waiting; responses to them from non-conforming servers are discarded
+	pendingBroadcasts map[uint16]bool
+
+	frameObserver FrameObserver
 }
 
 // TCPTransportConfig holds configuration for TCP transport
 type TCPTransportConfig struct {
 	Address        string
 	Timeout        time.Duration
+	WriteTimeout   time.Duration
 	IdleTimeout    time.Duration
 	ConnectTimeout time.Duration
 	TLSConfig      *tls.Config
@@ -82,6 +97,7 @@ func NewTCPTransport(address string) *TCPTransport {
 	return &TCPTransport{
 		address:        address,
 		timeout:        time.Duration(modbus.DefaultResponseTimeout) * time.Millisecond,
+		writeTimeout:   time.Duration(modbus.DefaultWriteTimeout) * time.Millisecond,
 		connectTimeout: time.Duration(modbus.DefaultConnectTimeout) * time.Millisecond,
 		idleTimeout:    60 * time.Second,
 		transactionID:  1,
@@ -93,6 +109,7 @@ func NewTCPTransportWithConfig(config TCPTransportConfig) *TCPTransport {
 	t := &TCPTransport{
 		address:        config.Address,
 		timeout:        config.Timeout,
+		writeTimeout:   config.WriteTimeout,
 		idleTimeout:    config.IdleTimeout,
 		connectTimeout: config.ConnectTimeout,
 		tlsConfig:      config.TLSConfig,
@@ -103,6 +120,9 @@ func NewTCPTransportWithConfig(config TCPTransportConfig) *TCPTransport {
 	if t.timeout == 0 {
 		t.timeout = time.Duration(modbus.DefaultResponseTimeout) * time.Millisecond
 	}
+	if t.writeTimeout == 0 {
+		t.writeTimeout = time.Duration(modbus.DefaultWriteTimeout) * time.Millisecond
+	}
 	if t.connectTimeout == 0 {
 		t.connectTimeout = time.Duration(modbus.DefaultConnectTimeout) * time.Millisecond
 	}
@@ -118,6 +138,7 @@ func NewTLSTransport(address string, tlsConfig *tls.Config) *TCPTransport {
 	return &TCPTransport{
 		address:        address,
 		timeout:        time.Duration(modbus.DefaultResponseTimeout) * time.Millisecond,
+		writeTimeout:   time.Duration(modbus.DefaultWriteTimeout) * time.Millisecond,
 		connectTimeout: time.Duration(modbus.DefaultConnectTimeout) * time.Millisecond,
 		idleTimeout:    60 * time.Second,
 		transactionID:  1,
@@ -132,6 +153,23 @@ func (t *TCPTransport) SetLogger(logger Logger) {
 	t.logger = logger
 }
 
+// GetLogger returns the transport's logger, or nil if none is set
+func (t *TCPTransport) GetLogger() Logger {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.logger
+}
+
+// SetFrameObserver sets a function called with every complete ADU sent or
+// received, MBAP header included, for capture and debugging. It runs on the
+// request path with the transport locked, so it must not call back into the
+// transport. Pass nil to remove it.
+func (t *TCPTransport) SetFrameObserver(observer FrameObserver) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.frameObserver = observer
+}
+
 // SetIdleTimeout sets the idle timeout for the connection
 func (t *TCPTransport) SetIdleTimeout(timeout time.Duration) {
 	t.mutex.Lock()
@@ -200,8 +238,16 @@ func (t *TCPTransport) Connect() error {
 		return fmt.Errorf("failed to connect to %s: %w", t.address, err)
 	}
 
+	// Send requests immediately rather than waiting to coalesce small writes
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.SetNoDelay(true)
+	}
+
+	// A fresh connection starts a new transaction sequence
 	t.conn = conn
 	t.connected = true
+	t.transactionID = 1
+	t.pendingBroadcasts = nil
 	t.lastActivity = time.Now()
 	t.logf("Connected to %s", t.address)
 	return nil
@@ -229,6 +275,25 @@ func (t *TCPTransport) IsConnected() bool {
 	return t.connected
 }
 
+// SetValidateUnitID sets whether responses must carry the unit ID of the
+// request (default true). Disable it only to talk through gateways that
+// rewrite the unit ID.
+func (t *TCPTransport) SetValidateUnitID(validate bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.skipUnitIDCheck = !validate
+}
+
+// SetValidateTransactionID sets whether responses must carry the transaction
+// ID of the request (default true). With the check disabled a late response
+// to an earlier request can be taken for the current one, so disable it only
+// for gateways that do not echo transaction IDs.
+func (t *TCPTransport) SetValidateTransactionID(validate bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.skipTransactionIDCheck = !validate
+}
+
 // SetTimeout sets the response timeout
 func (t *TCPTransport) SetTimeout(timeout time.Duration) {
 	t.mutex.Lock()
@@ -243,6 +308,23 @@ func (t *TCPTransport) GetTimeout() time.Duration {
 	return t.timeout
 }
 
+// SetWriteTimeout sets the deadline for writing a request to the connection.
+// SetTimeout controls how long to wait for the response, so a slow device on
+// a fast link can get a long response timeout while a stalled write still
+// fails quickly. Zero uses the response timeout for writes as well.
+func (t *TCPTransport) SetWriteTimeout(timeout time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.writeTimeout = timeout
+}
+
+// GetWriteTimeout returns the current write timeout
+func (t *TCPTransport) GetWriteTimeout() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.writeTimeout
+}
+
 // SendRequest sends a request PDU and returns the response PDU
 func (t *TCPTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request) (*pdu.Response, error) {
 	if !t.IsConnected() {
@@ -270,17 +352,25 @@ func (t *TCPTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request)
 
 	// Send request
 	if err := t.sendADU(header, pduBytes); err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		t.dropIfConnectionLost(err)
+		return nil, fmt.Errorf("failed to send request: %w", &modbus.WriteError{Err: err})
 	}
 
-	// Receive response
+	// Receive response, skipping any late responses to earlier broadcasts
 	responseHeader, responsePDU, err := t.receiveADU()
+	for err == nil && !t.skipTransactionIDCheck && responseHeader.TransactionID != txID && t.pendingBroadcasts[responseHeader.TransactionID] {
+		delete(t.pendingBroadcasts, responseHeader.TransactionID)
+		responseHeader, responsePDU, err = t.receiveADU()
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to receive response: %w", err)
+		t.dropIfConnectionLost(err)
+		return nil, wrapReadError(fmt.Errorf("failed to receive response: %w", err))
 	}
+	// Responses arrive in order, so broadcasts still pending were not answered
+	t.pendingBroadcasts = nil
 
 	// Validate response
-	if responseHeader.TransactionID != txID {
+	if responseHeader.TransactionID != txID && !t.skipTransactionIDCheck {
 		return nil, fmt.Errorf("transaction ID mismatch: expected %d, got %d",
 			txID, responseHeader.TransactionID)
 	}
@@ -290,7 +380,7 @@ func (t *TCPTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request)
 			modbus.MBAPProtocolID, responseHeader.ProtocolID)
 	}
 
-	if responseHeader.UnitID != uint8(slaveID) {
+	if responseHeader.UnitID != uint8(slaveID) && !t.skipUnitIDCheck {
 		return nil, fmt.Errorf("unit ID mismatch: expected %d, got %d",
 			slaveID, responseHeader.UnitID)
 	}
@@ -298,10 +388,63 @@ func (t *TCPTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request)
 	return &pdu.Response{PDU: responsePDU}, nil
 }
 
+// SendBroadcast sends a request to unit ID 0 and returns as soon as it is
+// written, without waiting for a response
+func (t *TCPTransport) SendBroadcast(request *pdu.Request) error {
+	if !t.IsConnected() {
+		return fmt.Errorf("transport not connected")
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	txID := t.transactionID
+	t.transactionID++
+	if t.transactionID == 0 {
+		t.transactionID = 1
+	}
+
+	pduBytes := request.Bytes()
+	header := &MBAPHeader{
+		TransactionID: txID,
+		ProtocolID:    modbus.MBAPProtocolID,
+		Length:        uint16(1 + len(pduBytes)),
+		UnitID:        modbus.BroadcastAddress,
+	}
+
+	if err := t.sendADU(header, pduBytes); err != nil {
+		t.dropIfConnectionLost(err)
+		return fmt.Errorf("failed to send broadcast: %w", &modbus.WriteError{Err: err})
+	}
+
+	if t.pendingBroadcasts == nil {
+		t.pendingBroadcasts = make(map[uint16]bool)
+	}
+	t.pendingBroadcasts[txID] = true
+	return nil
+}
+
+// dropIfConnectionLost closes the connection if err shows the peer has gone
+// away, so IsConnected reports false and callers can reconnect. Must be
+// called with t.mutex held.
+func (t *TCPTransport) dropIfConnectionLost(err error) {
+	if !isConnectionLost(err) || t.conn == nil {
+		return
+	}
+	t.logf("Connection to %s lost: %v", t.address, err)
+	_ = t.conn.Close()
+	t.conn = nil
+	t.connected = false
+}
+
 // sendADU sends an Application Data Unit (MBAP + PDU)
 func (t *TCPTransport) sendADU(header *MBAPHeader, pduBytes []byte) error {
-	// Set write timeout
-	if err := t.conn.SetWriteDeadline(time.Now().Add(t.timeout)); err != nil {
+	// Set write timeout, falling back to the response timeout if unset
+	writeTimeout := t.writeTimeout
+	if writeTimeout <= 0 {
+		writeTimeout = t.timeout
+	}
+	if err := t.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
 		return fmt.Errorf("failed to set write deadline: %w", err)
 	}
 
@@ -315,6 +458,9 @@ func (t *TCPTransport) sendADU(header *MBAPHeader, pduBytes []byte) error {
 	if _, err := t.conn.Write(adu); err != nil {
 		return fmt.Errorf("failed to write ADU: %w", err)
 	}
+	if t.frameObserver != nil {
+		t.frameObserver(FrameSent, adu)
+	}
 
 	return nil
 }
@@ -328,7 +474,10 @@ func (t *TCPTransport) receiveADU() (*MBAPHeader, *pdu.PDU, error) {
 
 	// Read MBAP header
 	headerBytes := make([]byte, modbus.MBAPHeaderSize)
-	if _, err := io.ReadFull(t.conn, headerBytes); err != nil {
+	if n, err := io.ReadFull(t.conn, headerBytes); err != nil {
+		if n > 0 {
+			return nil, nil, fmt.Errorf("failed to read MBAP header: read %d of %d expected bytes: %w", n, len(headerBytes), err)
+		}
 		return nil, nil, fmt.Errorf("failed to read MBAP header: %w", err)
 	}
 
@@ -353,8 +502,13 @@ func (t *TCPTransport) receiveADU() (*MBAPHeader, *pdu.PDU, error) {
 
 	// Read PDU (length includes UnitID which we already have in header)
 	pduBytes := make([]byte, header.Length-1)
-	if _, readErr := io.ReadFull(t.conn, pduBytes); readErr != nil {
-		return nil, nil, fmt.Errorf("failed to read PDU: %w", readErr)
+	if n, readErr := io.ReadFull(t.conn, pduBytes); readErr != nil {
+		return nil, nil, fmt.Errorf("failed to read PDU: read %d of %d expected PDU bytes (transaction %d): %w",
+			n, len(pduBytes), header.TransactionID, readErr)
+	}
+
+	if t.frameObserver != nil {
+		t.frameObserver(FrameReceived, append(headerBytes, pduBytes...))
 	}
 
 	responsePDU, err := pdu.ParsePDU(pduBytes)
@@ -409,6 +563,13 @@ func (t *RTUOverTCPTransport) SetLogger(logger Logger) {
 	t.logger = logger
 }
 
+// GetLogger returns the transport's logger, or nil if none is set
+func (t *RTUOverTCPTransport) GetLogger() Logger {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.logger
+}
+
 func (t *RTUOverTCPTransport) logf(format string, v ...interface{}) {
 	if t.logger != nil {
 		t.logger.Printf(format, v...)
@@ -506,7 +667,8 @@ func (t *RTUOverTCPTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.R
 
 	// Send frame
 	if _, err := t.conn.Write(frame); err != nil {
-		return nil, fmt.Errorf("failed to send RTU frame: %w", err)
+		t.dropIfConnectionLost(err)
+		return nil, fmt.Errorf("failed to send RTU frame: %w", &modbus.WriteError{Err: err})
 	}
 
 	t.lastActivity = time.Now()
@@ -515,7 +677,8 @@ func (t *RTUOverTCPTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.R
 	response := make([]byte, 256)
 	n, err := t.conn.Read(response)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read RTU response: %w", err)
+		t.dropIfConnectionLost(err)
+		return nil, wrapReadError(fmt.Errorf("failed to read RTU response: %w", err))
 	}
 
 	if n < 4 {
@@ -545,6 +708,18 @@ func (t *RTUOverTCPTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.R
 	return &pdu.Response{PDU: responsePDU}, nil
 }
 
+// dropIfConnectionLost closes the connection if err shows the peer has gone
+// away. Must be called with t.mutex held.
+func (t *RTUOverTCPTransport) dropIfConnectionLost(err error) {
+	if !isConnectionLost(err) || t.conn == nil {
+		return
+	}
+	t.logf("Connection to %s lost: %v", t.address, err)
+	_ = t.conn.Close()
+	t.conn = nil
+	t.connected = false
+}
+
 // GetTransportType returns the transport type
 func (t *RTUOverTCPTransport) GetTransportType() modbus.TransportType {
 	return modbus.TransportRTU
@@ -583,6 +758,13 @@ func (t *UDPTransport) SetLogger(logger Logger) {
 	t.logger = logger
 }
 
+// GetLogger returns the transport's logger, or nil if none is set
+func (t *UDPTransport) GetLogger() Logger {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.logger
+}
+
 func (t *UDPTransport) logf(format string, v ...interface{}) {
 	if t.logger != nil {
 		t.logger.Printf(format, v...)
@@ -651,6 +833,42 @@ func (t *UDPTransport) GetTimeout() time.Duration {
 	return t.timeout
 }
 
+// SendBroadcast sends a request to unit ID 0 without waiting for a response
+func (t *UDPTransport) SendBroadcast(request *pdu.Request) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.connected {
+		return fmt.Errorf("transport not connected")
+	}
+
+	txID := t.transactionID
+	t.transactionID++
+	if t.transactionID == 0 {
+		t.transactionID = 1
+	}
+
+	pduBytes := request.Bytes()
+	header := &MBAPHeader{
+		TransactionID: txID,
+		ProtocolID:    modbus.MBAPProtocolID,
+		Length:        uint16(1 + len(pduBytes)),
+		UnitID:        modbus.BroadcastAddress,
+	}
+	adu := append(header.EncodeMBAP(), pduBytes...)
+
+	if err := t.conn.SetWriteDeadline(time.Now().Add(t.timeout)); err != nil {
+		return fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	t.logf("TX UDP broadcast: % X", adu)
+
+	if _, err := t.conn.Write(adu); err != nil {
+		return fmt.Errorf("failed to send UDP broadcast: %w", &modbus.WriteError{Err: err})
+	}
+	return nil
+}
+
 // SendRequest sends a MODBUS request over UDP using MBAP framing
 func (t *UDPTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request) (*pdu.Response, error) {
 	t.mutex.Lock()
@@ -689,32 +907,39 @@ func (t *UDPTransport) SendRequest(slaveID modbus.SlaveID, request *pdu.Request)
 
 	// Send request
 	if _, err := t.conn.Write(adu); err != nil {
-		return nil, fmt.Errorf("failed to send UDP request: %w", err)
+		return nil, fmt.Errorf("failed to send UDP request: %w", &modbus.WriteError{Err: err})
 	}
 
-	// Receive response
+	// Receive response. Datagrams can be duplicated or arrive late, so
+	// datagrams that don't answer this request are discarded and reading
+	// continues until the deadline.
 	response := make([]byte, modbus.MaxTCPADUSize)
-	n, err := t.conn.Read(response)
-	if err != nil {
-		return nil, fmt.Errorf("failed to receive UDP response: %w", err)
-	}
-
-	if n < modbus.MBAPHeaderSize+1 {
-		return nil, fmt.Errorf("UDP response too short: %d bytes", n)
-	}
+	var n int
+	for {
+		var err error
+		n, err = t.conn.Read(response)
+		if err != nil {
+			return nil, wrapReadError(fmt.Errorf("failed to receive UDP response: %w", err))
+		}
 
-	t.logf("RX UDP: % X", response[:n])
+		t.logf("RX UDP: % X", response[:n])
 
-	// Parse MBAP header
-	respHeader, err := DecodeMBAP(response[:modbus.MBAPHeaderSize])
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode MBAP header: %w", err)
-	}
+		if n < modbus.MBAPHeaderSize+1 {
+			t.logf("Discarding UDP datagram: too short: %d bytes", n)
+			continue
+		}
 
-	// Validate response
-	if respHeader.TransactionID != txID {
-		return nil, fmt.Errorf("transaction ID mismatch: expected %d, got %d",
-			txID, respHeader.TransactionID)
+		respHeader, err := DecodeMBAP(response[:modbus.MBAPHeaderSize])
+		if err != nil {
+			t.logf("Discarding UDP datagram: %v", err)
+			continue
+		}
+		if respHeader.ProtocolID != modbus.MBAPProtocolID || respHeader.TransactionID != txID {
+			t.logf("Discarding UDP datagram: transaction ID %d, protocol ID %d does not match transaction %d",
+				respHeader.TransactionID, respHeader.ProtocolID, txID)
+			continue
+		}
+		break
 	}
 
 	// Parse PDU
@@ -736,6 +961,17 @@ func (t *UDPTransport) String() string {
 	return fmt.Sprintf("UDP(%s)", t.address)
 }
 
+// isConnectionLost reports whether err indicates the peer closed or reset
+// the connection, as opposed to a timeout or a malformed response
+func isConnectionLost(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
 // TCPServer implements a MODBUS TCP server
 type TCPServer struct {
 	listener       net.Listener
@@ -743,11 +979,86 @@ type TCPServer struct {
 	handler        RequestHandler
 	connections    map[net.Conn]bool
 	mutex          sync.RWMutex
-	running        bool
+	state          serverState
+	stopped        chan struct{} // closed when a Stop in progress finishes
 	stopChan       chan struct{}
 	wg             sync.WaitGroup
 	shutdownCtx    context.Context
 	shutdownCancel context.CancelFunc
+
+	allowedUnitIDs     map[modbus.SlaveID]bool
+	dropUnknownUnitIDs bool
+	rateLimit          float64
+	rateBurst          int
+	onDuplicateTxID    DuplicateTransactionFunc
+	handlerFactory     HandlerFactory
+	listenControl      ListenControlFunc
+	detachHandlers     bool
+}
+
+// ListenControlFunc is called with the server's socket before it is bound,
+// as net.ListenConfig.Control
+type ListenControlFunc func(network, address string, c syscall.RawConn) error
+
+// serverState is a TCPServer lifecycle state. A server moves from idle to
+// starting to running, and from running to stopping back to idle.
+type serverState int
+
+const (
+	serverIdle serverState = iota
+	serverStarting
+	serverRunning
+	serverStopping
+)
+
+// Server lifecycle errors for calls made in the wrong state
+var (
+	ErrServerRunning  = errors.New("server already running")
+	ErrServerStarting = errors.New("server is starting")
+	ErrServerStopping = errors.New("server is stopping")
+)
+
+// HandlerFactory returns the request handler for a new connection from
+// remote. Handlers created per connection may keep per-session state, such as
+// listen-only mode or diagnostic counters, while sharing a data store.
+type HandlerFactory func(remote net.Addr) RequestHandler
+
+// DuplicateTransactionFunc is called when a client sends a request reusing the
+// transaction ID of a request the server has received on the same connection
+// but not yet answered
+type DuplicateTransactionFunc func(remote net.Addr, transactionID uint16)
+
+// tokenBucket limits the request rate of a single connection
+type tokenBucket struct {
+	rate   float64 // tokens added per second
+	burst  float64 // bucket capacity
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full token bucket
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// allow refills the bucket and takes a token if one is available
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
 }
 
 // RequestHandler defines the interface for handling MODBUS requests
@@ -755,6 +1066,39 @@ type RequestHandler interface {
 	HandleRequest(slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response
 }
 
+// ContextRequestHandler is an optional interface for handlers that can observe
+// server shutdown. The context is cancelled when the server is stopped.
+type ContextRequestHandler interface {
+	HandleRequestContext(ctx context.Context, slaveID modbus.SlaveID, req *pdu.Request) *pdu.Response
+}
+
+// DiagnosticCounter is an optional interface for handlers that keep the serial
+// line diagnostic counters. Servers use it to count events the handler never
+// sees, such as requests rejected as busy or requests left unanswered.
+type DiagnosticCounter interface {
+	IncrementDiagnosticCounter(counter string)
+}
+
+// countDiagnostic increments counter if handler keeps diagnostic counters
+func countDiagnostic(handler RequestHandler, counter string) {
+	if c, ok := handler.(DiagnosticCounter); ok {
+		c.IncrementDiagnosticCounter(counter)
+	}
+}
+
+// ResponseReleaser is an optional interface for handlers that reuse response
+// buffers. Servers call ReleaseResponse once a response's bytes are encoded.
+type ResponseReleaser interface {
+	ReleaseResponse(response *pdu.Response)
+}
+
+// releaseResponse hands response back to handler if it reuses responses
+func releaseResponse(handler RequestHandler, response *pdu.Response) {
+	if r, ok := handler.(ResponseReleaser); ok {
+		r.ReleaseResponse(response)
+	}
+}
+
 // NewTCPServer creates a new TCP server
 func NewTCPServer(address string, handler RequestHandler) *TCPServer {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -768,49 +1112,137 @@ func NewTCPServer(address string, handler RequestHandler) *TCPServer {
 	}
 }
 
+// SetAllowedUnitIDs restricts the unit IDs the server answers. Requests for
+// any other unit ID get a gateway target failed exception, or close the
+// connection if SetDropUnknownUnitIDs is enabled. Calling it with no IDs
+// accepts every unit ID again.
+func (s *TCPServer) SetAllowedUnitIDs(ids ...modbus.SlaveID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(ids) == 0 {
+		s.allowedUnitIDs = nil
+		return
+	}
+
+	s.allowedUnitIDs = make(map[modbus.SlaveID]bool, len(ids))
+	for _, id := range ids {
+		s.allowedUnitIDs[id] = true
+	}
+}
+
+// SetDropUnknownUnitIDs sets whether requests for unit IDs outside the allowed
+// set close the connection instead of returning an exception
+func (s *TCPServer) SetDropUnknownUnitIDs(drop bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.dropUnknownUnitIDs = drop
+}
+
+// SetPerConnectionRateLimit limits each connection to rps requests per second
+// with bursts of up to burst requests. Requests over the limit get a server
+// device busy exception. A non-positive rps disables the limit. The limit
+// applies to connections accepted after the call.
+func (s *TCPServer) SetPerConnectionRateLimit(rps float64, burst int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if burst < 1 {
+		burst = 1
+	}
+	s.rateLimit = rps
+	s.rateBurst = burst
+}
+
+// newConnectionLimiter returns a token bucket for a new connection, or nil if
+// rate limiting is disabled
+func (s *TCPServer) newConnectionLimiter() *tokenBucket {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.rateLimit <= 0 {
+		return nil
+	}
+	return newTokenBucket(s.rateLimit, s.rateBurst)
+}
+
+// acceptsUnitID reports whether the server answers for unitID and, if not,
+// whether the connection should be dropped
+func (s *TCPServer) acceptsUnitID(unitID modbus.SlaveID) (accepted, drop bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.allowedUnitIDs == nil || s.allowedUnitIDs[unitID] {
+		return true, false
+	}
+	return false, s.dropUnknownUnitIDs
+}
+
 // Start starts the TCP server
 func (s *TCPServer) Start() error {
 	s.mutex.Lock()
-	if s.running {
+	switch s.state {
+	case serverStarting:
+		s.mutex.Unlock()
+		return ErrServerStarting
+	case serverRunning:
 		s.mutex.Unlock()
-		return fmt.Errorf("server already running")
+		return ErrServerRunning
+	case serverStopping:
+		s.mutex.Unlock()
+		return ErrServerStopping
 	}
+	s.state = serverStarting
 
 	// Reset shutdown context if restarting
 	s.shutdownCtx, s.shutdownCancel = context.WithCancel(context.Background())
 	s.stopChan = make(chan struct{})
+	lc := net.ListenConfig{Control: s.listenControl}
 	s.mutex.Unlock()
 
 	// Start listening
-	lc := net.ListenConfig{}
 	listener, err := lc.Listen(context.Background(), "tcp", s.address)
 	if err != nil {
+		s.mutex.Lock()
+		s.state = serverIdle
+		s.mutex.Unlock()
 		return fmt.Errorf("failed to listen on %s: %w", s.address, err)
 	}
 
 	s.mutex.Lock()
 	s.listener = listener
-	s.running = true
-	s.mutex.Unlock()
-
+	s.state = serverRunning
 	s.wg.Add(1)
-	go s.acceptLoop()
+	go s.acceptLoop(listener, s.stopChan)
+	s.mutex.Unlock()
 
 	return nil
 }
 
-// Stop stops the TCP server gracefully
+// Stop stops the TCP server gracefully. Stopping a server that is not running
+// does nothing; a Stop racing another waits for it to finish. Stop fails with
+// ErrServerStarting while Start is binding the listener.
 func (s *TCPServer) Stop() error {
 	s.mutex.Lock()
-	if !s.running {
+	switch s.state {
+	case serverIdle:
+		s.mutex.Unlock()
+		return nil
+	case serverStarting:
+		s.mutex.Unlock()
+		return ErrServerStarting
+	case serverStopping:
+		stopped := s.stopped
 		s.mutex.Unlock()
+		<-stopped
 		return nil
 	}
+	s.state = serverStopping
+	s.stopped = make(chan struct{})
 
 	// Signal shutdown
 	s.shutdownCancel()
 	close(s.stopChan)
-	s.running = false
 
 	if s.listener != nil {
 		if err := s.listener.Close(); err != nil {
@@ -829,9 +1261,50 @@ func (s *TCPServer) Stop() error {
 	// Wait for all goroutines to finish
 	s.wg.Wait()
 
+	s.mutex.Lock()
+	s.state = serverIdle
+	close(s.stopped)
+	s.mutex.Unlock()
+
 	return nil
 }
 
+// Restart stops the server, if running, and starts it again
+func (s *TCPServer) Restart() error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+	return s.Start()
+}
+
+// DefaultShutdownTimeout bounds the graceful stop done by ListenAndServe
+const DefaultShutdownTimeout = 5 * time.Second
+
+// ListenAndServe starts the server and serves until ctx is cancelled, then
+// stops it with StopWithTimeout(DefaultShutdownTimeout). It returns nil after
+// a clean shutdown, including one caused by a direct call to Stop, and
+// otherwise the Start or shutdown error. With signal.NotifyContext:
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+//	defer stop()
+//	err := server.ListenAndServe(ctx)
+func (s *TCPServer) ListenAndServe(ctx context.Context) error {
+	if err := s.Start(); err != nil {
+		return err
+	}
+
+	s.mutex.RLock()
+	stopped := s.stopChan
+	s.mutex.RUnlock()
+
+	select {
+	case <-ctx.Done():
+		return s.StopWithTimeout(DefaultShutdownTimeout)
+	case <-stopped:
+		return s.Stop()
+	}
+}
+
 // StopWithTimeout stops the server with a timeout for graceful shutdown
 func (s *TCPServer) StopWithTimeout(timeout time.Duration) error {
 	done := make(chan error, 1)
@@ -851,21 +1324,19 @@ func (s *TCPServer) StopWithTimeout(timeout time.Duration) error {
 func (s *TCPServer) IsRunning() bool {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
-	return s.running
+	return s.state == serverRunning
 }
 
-// acceptLoop accepts incoming connections
-func (s *TCPServer) acceptLoop() {
+// acceptLoop accepts incoming connections on listener until stop is closed
+func (s *TCPServer) acceptLoop(listener net.Listener, stop chan struct{}) {
 	defer s.wg.Done()
 
 	for {
 		select {
-		case <-s.stopChan:
-			return
-		case <-s.shutdownCtx.Done():
+		case <-stop:
 			return
 		default:
-			conn, err := s.listener.Accept()
+			conn, err := listener.Accept()
 			if err != nil {
 				if s.IsRunning() {
 					// Log error if server is still supposed to be running
@@ -874,7 +1345,13 @@ func (s *TCPServer) acceptLoop() {
 				continue
 			}
 
+			// A connection accepted as Stop closes the others is closed too
 			s.mutex.Lock()
+			if s.state != serverRunning {
+				s.mutex.Unlock()
+				_ = conn.Close()
+				continue
+			}
 			s.connections[conn] = true
 			s.mutex.Unlock()
 
@@ -884,6 +1361,138 @@ func (s *TCPServer) acceptLoop() {
 	}
 }
 
+// SetDetachHandlers runs handlers that do not implement ContextRequestHandler
+// in a goroutine of their own, so a handler that blocks cannot hang Stop; its
+// eventual response is discarded. This costs a goroutine and a channel per
+// request and is off by default. A ContextRequestHandler always runs on the
+// connection goroutine and should return once its context is cancelled.
+func (s *TCPServer) SetDetachHandlers(detach bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.detachHandlers = detach
+}
+
+// handleRequest runs the handler for one request. It returns false if the
+// server stopped before the handler answered, and the response must not be
+// sent. Only detached handlers are abandoned when the server stops.
+func (s *TCPServer) handleRequest(handler RequestHandler, slaveID modbus.SlaveID, req *pdu.Request) (*pdu.Response, bool) {
+	s.mutex.RLock()
+	ctx := s.shutdownCtx
+	detach := s.detachHandlers
+	s.mutex.RUnlock()
+
+	if h, ok := handler.(ContextRequestHandler); ok {
+		response := h.HandleRequestContext(ctx, slaveID, req)
+		return response, ctx.Err() == nil
+	}
+	if !detach {
+		return handler.HandleRequest(slaveID, req), true
+	}
+
+	result := make(chan *pdu.Response, 1)
+	go func() {
+		result <- handler.HandleRequest(slaveID, req)
+	}()
+
+	select {
+	case response := <-result:
+		return response, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// SetDuplicateTransactionHandler enables tracking of outstanding transaction
+// IDs per connection. Requests are then read ahead of processing, so a client
+// that pipelines several requests with the same transaction ID is reported to
+// fn. Duplicates are still answered. A nil fn disables tracking.
+func (s *TCPServer) SetDuplicateTransactionHandler(fn DuplicateTransactionFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onDuplicateTxID = fn
+}
+
+// SetListenControl sets a function that can set options on the listening
+// socket before it is bound, e.g. SO_REUSEPORT to share a port between
+// processes. It applies from the next Start; pass nil to remove it.
+//
+// On Unix, Go already sets SO_REUSEADDR on listening sockets, so a restarted
+// server binds immediately even while connections from the previous run are
+// in TIME_WAIT. Windows does not need it for that; setting SO_REUSEADDR there
+// lets other processes take over the port. The accept backlog is the
+// operating system's default (net.core.somaxconn on Linux); the net package
+// offers no way to change it per listener.
+func (s *TCPServer) SetListenControl(fn ListenControlFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.listenControl = fn
+}
+
+// SetHandlerFactory makes the server ask fn for a handler for each new
+// connection instead of using the handler it was created with. A nil handler
+// from fn falls back to the shared handler. If a handler returned by fn
+// implements io.Closer it is closed when its connection ends. The factory
+// applies to connections accepted after the call; pass nil to remove it.
+func (s *TCPServer) SetHandlerFactory(fn HandlerFactory) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.handlerFactory = fn
+}
+
+// connectionHandler returns the request handler for a new connection from
+// remote and whether it was created for that connection
+func (s *TCPServer) connectionHandler(remote net.Addr) (RequestHandler, bool) {
+	s.mutex.RLock()
+	factory := s.handlerFactory
+	s.mutex.RUnlock()
+
+	if factory != nil {
+		if handler := factory(remote); handler != nil {
+			return handler, true
+		}
+	}
+	return s.handler, false
+}
+
+// receivedADU is a request read ahead of processing
+type receivedADU struct {
+	header *MBAPHeader
+	pdu    *pdu.PDU
+	err    error
+}
+
+// readAhead reads requests from transport into a channel until a read fails
+// or done is closed, reporting transaction IDs that are already outstanding.
+// The caller removes IDs from outstanding once answered.
+func readAhead(transport *TCPTransport, remote net.Addr, fn DuplicateTransactionFunc,
+	outstanding map[uint16]int, mutex *sync.Mutex, done <-chan struct{}) <-chan receivedADU {
+	requests := make(chan receivedADU, 16)
+	go func() {
+		for {
+			header, requestPDU, err := transport.receiveADU()
+			if err == nil {
+				mutex.Lock()
+				duplicate := outstanding[header.TransactionID] > 0
+				outstanding[header.TransactionID]++
+				mutex.Unlock()
+				if duplicate {
+					fn(remote, header.TransactionID)
+				}
+			}
+
+			select {
+			case requests <- receivedADU{header, requestPDU, err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return requests
+}
+
 // handleConnection handles a single connection
 func (s *TCPServer) handleConnection(conn net.Conn) {
 	defer func() {
@@ -899,6 +1508,38 @@ func (s *TCPServer) handleConnection(conn net.Conn) {
 		connected: true,
 		timeout:   time.Duration(modbus.DefaultResponseTimeout) * time.Millisecond,
 	}
+	limiter := s.newConnectionLimiter()
+
+	handler, perConnection := s.connectionHandler(conn.RemoteAddr())
+	if closer, ok := handler.(io.Closer); ok && perConnection {
+		defer func() { _ = closer.Close() }()
+	}
+
+	s.mutex.RLock()
+	onDuplicate := s.onDuplicateTxID
+	s.mutex.RUnlock()
+
+	receive := transport.receiveADU
+	answered := func(transactionID uint16) {}
+	if onDuplicate != nil {
+		outstanding := make(map[uint16]int)
+		var outstandingMutex sync.Mutex
+		done := make(chan struct{})
+		defer close(done)
+
+		requests := readAhead(transport, conn.RemoteAddr(), onDuplicate, outstanding, &outstandingMutex, done)
+		receive = func() (*MBAPHeader, *pdu.PDU, error) {
+			r := <-requests
+			return r.header, r.pdu, r.err
+		}
+		answered = func(transactionID uint16) {
+			outstandingMutex.Lock()
+			if outstanding[transactionID]--; outstanding[transactionID] <= 0 {
+				delete(outstanding, transactionID)
+			}
+			outstandingMutex.Unlock()
+		}
+	}
 
 	for {
 		select {
@@ -908,7 +1549,7 @@ func (s *TCPServer) handleConnection(conn net.Conn) {
 			return
 		default:
 			// Receive request
-			header, requestPDU, err := transport.receiveADU()
+			header, requestPDU, err := receive()
 			if err != nil {
 				if s.IsRunning() {
 					// Log error if server is still running
@@ -919,22 +1560,43 @@ func (s *TCPServer) handleConnection(conn net.Conn) {
 
 			// Handle request
 			request := &pdu.Request{PDU: requestPDU}
-			response := s.handler.HandleRequest(modbus.SlaveID(header.UnitID), request)
+			unitID := modbus.SlaveID(header.UnitID)
+			accepted, drop := s.acceptsUnitID(unitID)
+
+			var response *pdu.Response
+			switch {
+			case !accepted && drop:
+				return
+			case !accepted:
+				response = pdu.NewExceptionResponse(request.FunctionCode, modbus.ExceptionCodeGatewayTargetFail)
+			case limiter != nil && !limiter.allow(time.Now()):
+				countDiagnostic(handler, "ServerBusy")
+				response = pdu.NewExceptionResponse(request.FunctionCode, modbus.ExceptionCodeServerDeviceBusy)
+			default:
+				var ok bool
+				response, ok = s.handleRequest(handler, unitID, request)
+				if !ok {
+					return
+				}
+			}
 
 			// Send response
+			responseBytes := response.Bytes()
+			releaseResponse(handler, response)
 			responseHeader := &MBAPHeader{
 				TransactionID: header.TransactionID,
 				ProtocolID:    modbus.MBAPProtocolID,
-				Length:        uint16(1 + response.Size()), // UnitID + PDU
+				Length:        uint16(1 + len(responseBytes)), // UnitID + PDU
 				UnitID:        header.UnitID,
 			}
 
-			if err := transport.sendADU(responseHeader, response.Bytes()); err != nil {
+			if err := transport.sendADU(responseHeader, responseBytes); err != nil {
 				if s.IsRunning() {
 					fmt.Printf("TCP server send error: %v\n", err)
 				}
 				return
 			}
+			answered(header.TransactionID)
 		}
 	}
 }
@@ -0,0 +1,162 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adibhanna/modbus-go/modbus"
+	"github.com/adibhanna/modbus-go/pdu"
+)
+
+func TestReceiveADUReportsPartialPDU(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	transport := &TCPTransport{conn: client, connected: true, timeout: 200 * time.Millisecond}
+
+	// The header announces a 5-byte PDU but only 2 bytes arrive
+	header := &MBAPHeader{TransactionID: 9, ProtocolID: modbus.MBAPProtocolID, Length: 6, UnitID: 1}
+	go func() {
+		_, _ = server.Write(header.EncodeMBAP())
+		_, _ = server.Write([]byte{0x03, 0x02})
+	}()
+
+	_, _, err := transport.receiveADU()
+	if err == nil || !strings.Contains(err.Error(), "read 2 of 5 expected PDU bytes") {
+		t.Errorf("Expected partial PDU error, got %v", err)
+	}
+}
+
+func TestTCPTransportRelaxedHeaderChecks(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	transport := &TCPTransport{conn: client, connected: true, timeout: time.Second, transactionID: 1}
+
+	// A gateway that answers as unit 9 with transaction ID 500
+	go func() {
+		request := make([]byte, modbus.MBAPHeaderSize+5)
+		for {
+			if _, err := io.ReadFull(server, request); err != nil {
+				return
+			}
+			header := &MBAPHeader{TransactionID: 500, ProtocolID: modbus.MBAPProtocolID, Length: 5, UnitID: 9}
+			_, _ = server.Write(append(header.EncodeMBAP(), 0x03, 0x02, 0x00, 0x2A))
+		}
+	}()
+
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+	if _, err := transport.SendRequest(1, req); err == nil || !strings.Contains(err.Error(), "transaction ID mismatch") {
+		t.Errorf("Expected transaction ID mismatch, got %v", err)
+	}
+
+	transport.SetValidateTransactionID(false)
+	if _, err := transport.SendRequest(1, req); err == nil || !strings.Contains(err.Error(), "unit ID mismatch") {
+		t.Errorf("Expected unit ID mismatch, got %v", err)
+	}
+
+	transport.SetValidateUnitID(false)
+	resp, err := transport.SendRequest(1, req)
+	if err != nil {
+		t.Fatalf("Expected relaxed checks to accept the response, got %v", err)
+	}
+	if values, err := pdu.ParseReadHoldingRegistersResponse(resp, 1); err != nil || values[0] != 42 {
+		t.Errorf("Expected 42, got %v (%v)", values, err)
+	}
+}
+
+func TestTCPTransportWriteTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// The peer never reads, so the write stalls
+	transport := &TCPTransport{conn: client, connected: true, timeout: 5 * time.Second, transactionID: 1}
+	transport.SetWriteTimeout(50 * time.Millisecond)
+	if transport.GetWriteTimeout() != 50*time.Millisecond {
+		t.Errorf("Expected write timeout 50ms, got %v", transport.GetWriteTimeout())
+	}
+
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+	start := time.Now()
+	if _, err := transport.SendRequest(1, req); err == nil || !modbus.IsWriteError(err) {
+		t.Errorf("Expected write error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Write took %v, expected the write timeout to apply", elapsed)
+	}
+}
+
+func TestTCPTransportConnectionClosed(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	transport := &TCPTransport{conn: client, connected: true, timeout: 5 * time.Second, transactionID: 1}
+
+	// The peer reads the request and closes the connection without answering
+	go func() {
+		request := make([]byte, modbus.MBAPHeaderSize+5)
+		_, _ = io.ReadFull(server, request)
+		_ = server.Close()
+	}()
+
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+	_, err := transport.SendRequest(1, req)
+	if !errors.Is(err, ErrConnectionClosed) || modbus.IsTimeout(err) {
+		t.Errorf("Expected ErrConnectionClosed, got %v", err)
+	}
+	if transport.IsConnected() {
+		t.Error("Expected transport to be disconnected")
+	}
+}
+
+func TestUDPTransportDiscardsStaleDatagrams(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer server.Close()
+
+	// Answer each request with a duplicate of the previous response, a
+	// truncated datagram and then the real response
+	go func() {
+		buf := make([]byte, modbus.MaxTCPADUSize)
+		for {
+			n, addr, err := server.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			request, _ := DecodeMBAP(buf[:n])
+			reply := func(txID uint16) []byte {
+				header := &MBAPHeader{TransactionID: txID, ProtocolID: modbus.MBAPProtocolID, Length: 5, UnitID: 1}
+				return append(header.EncodeMBAP(), 0x03, 0x02, 0x00, byte(txID))
+			}
+			_, _ = server.WriteToUDP(reply(request.TransactionID-1), addr)
+			_, _ = server.WriteToUDP([]byte{0x00, 0x01}, addr)
+			_, _ = server.WriteToUDP(reply(request.TransactionID), addr)
+		}
+	}()
+
+	transport := NewUDPTransport(server.LocalAddr().String())
+	if err := transport.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer transport.Close()
+
+	req, _ := pdu.ReadHoldingRegistersRequest(0, 1)
+	for txID := 1; txID <= 2; txID++ {
+		resp, err := transport.SendRequest(1, req)
+		if err != nil {
+			t.Fatalf("Request %d failed: %v", txID, err)
+		}
+		if data := resp.Data; len(data) != 3 || data[2] != byte(txID) {
+			t.Errorf("Request %d: expected its own response, got % X", txID, data)
+		}
+	}
+}
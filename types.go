@@ -12,13 +12,18 @@ type (
 	FunctionCode         = modbus.FunctionCode
 	ExceptionCode        = modbus.ExceptionCode
 	ModbusError          = modbus.ModbusError
+	TimeoutError         = modbus.TimeoutError
+	WriteError           = modbus.WriteError
 	TransportType        = modbus.TransportType
 	ClientConfig         = modbus.ClientConfig
 	ServerConfig         = modbus.ServerConfig
 	DataStore            = modbus.DataStore
+	SettableDataStore    = modbus.SettableDataStore
 	DeviceIdentification = modbus.DeviceIdentification
+	DeviceIDObject       = modbus.DeviceIDObject
 	FileRecord           = modbus.FileRecord
 	DiagnosticData       = modbus.DiagnosticData
+	ServerIDReport       = modbus.ServerIDReport
 )
 
 // Re-export constants from modbus package
@@ -51,6 +56,7 @@ const (
 	ExceptionCodeServerDeviceFailure = modbus.ExceptionCodeServerDeviceFailure
 	ExceptionCodeAcknowledge         = modbus.ExceptionCodeAcknowledge
 	ExceptionCodeServerDeviceBusy    = modbus.ExceptionCodeServerDeviceBusy
+	ExceptionCodeNegativeAcknowledge = modbus.ExceptionCodeNegativeAcknowledge
 	ExceptionCodeMemoryParityError   = modbus.ExceptionCodeMemoryParityError
 	ExceptionCodeGatewayPathUnavail  = modbus.ExceptionCodeGatewayPathUnavail
 	ExceptionCodeGatewayTargetFail   = modbus.ExceptionCodeGatewayTargetFail
@@ -84,4 +90,15 @@ var (
 	NewModbusError      = modbus.NewModbusError
 	DefaultClientConfig = modbus.DefaultClientConfig
 	DefaultServerConfig = modbus.DefaultServerConfig
+	IsGatewayError      = modbus.IsGatewayError
+	IsTimeout           = modbus.IsTimeout
+	IsWriteError        = modbus.IsWriteError
+	FromConventional    = modbus.FromConventional
+	ToConventional      = modbus.ToConventional
+
+	NewDeviceIdentification = modbus.NewDeviceIdentification
+	ConformityLevelString   = modbus.ConformityLevelString
+
+	DecodeDiagnosticRegister     = modbus.DecodeDiagnosticRegister
+	DecodeDiagnosticRegisterWith = modbus.DecodeDiagnosticRegisterWith
 )